@@ -5,6 +5,7 @@ class APIClient {
     this.baseURL = baseURL;
     this.accessToken = localStorage.getItem('accessToken');
     this.refreshToken = localStorage.getItem('refreshToken');
+    this.cartToken = localStorage.getItem('cartToken');
   }
 
   // Cambio rapdio de base URL cuando toque
@@ -23,6 +24,11 @@ class APIClient {
       headers['Authorization'] = `Bearer ${this.accessToken}`;
     }
 
+    // Token firmado del carrito de invitado, lo emite el server
+    if (this.cartToken) {
+      headers['X-Cart-Token'] = this.cartToken;
+    }
+
     return headers;
   }
 
@@ -39,6 +45,7 @@ class APIClient {
 
     try {
       const response = await fetch(url, config);
+      this.saveCartToken(response);
 
       // Si nos botan con 401, probamos refrescar token tranqui
       if (response.status === 401 && this.refreshToken) {
@@ -63,6 +70,15 @@ class APIClient {
     }
   }
 
+  // Guardamos el token del carrito cada que el server lo renueva
+  saveCartToken(response) {
+    const token = response.headers.get('X-Cart-Token');
+    if (token) {
+      this.cartToken = token;
+      localStorage.setItem('cartToken', token);
+    }
+  }
+
   // GETcito chill
   async get(endpoint, options = {}) {
     const response = await this.request(endpoint, {
@@ -275,9 +291,8 @@ class APIClient {
 
   // ENDPOINTS DE PEDIDOS
   // Crear pedido
-  async createOrder(sessionId, shippingInfo) {
+  async createOrder(shippingInfo) {
     return this.post('/api/orders', {
-      session_id: sessionId,
       shipping_name: shippingInfo.name,
       shipping_phone: shippingInfo.phone,
       shipping_email: shippingInfo.email,
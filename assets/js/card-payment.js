@@ -160,13 +160,6 @@ document.addEventListener('DOMContentLoaded', function() {
         // Si ya habia pedido, solo pagamos
         orderId = existingOrderId;
       } else {
-        // Buscamos session o creamos una
-        let sessionId = localStorage.getItem('sessionId');
-        if (!sessionId) {
-          sessionId = generateSessionId();
-          localStorage.setItem('sessionId', sessionId);
-        }
-
         // Sacamos los datos de envio
         const shippingForm = document.getElementById('shipping-form');
         const shippingData = new FormData(shippingForm);
@@ -188,7 +181,7 @@ document.addEventListener('DOMContentLoaded', function() {
         }
 
         // Creamos el pedido en la API
-        const orderResponse = await apiClient.createOrder(sessionId, shippingInfo);
+        const orderResponse = await apiClient.createOrder(shippingInfo);
         orderId = orderResponse.id;
       }
 
@@ -257,10 +250,6 @@ async function loadOrderForPayment(orderId) {
   }
 }
 
-function generateSessionId() {
-  return 'session_' + Date.now() + '_' + Math.random().toString(36).substr(2, 9);
-}
-
 // Formato de precio a la criolla
 function formatPriceColombian(price) {
   const intPrice = Math.floor(price);
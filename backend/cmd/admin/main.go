@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+const usage = `Uso:
+  admin promote --email <correo>
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "promote":
+		err = runPromote(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("admin %s: %v", os.Args[1], err)
+	}
+}
+
+// connect carga config y DB igual que la API
+func connect() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return database.Initialize(cfg)
+}
+
+// runPromote sube a admin una cuenta que ya existe; asi nadie se vuelve admin
+// solo por registrarse primero con un correo de la lista
+func runPromote(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	email := fs.String("email", "", "correo de la cuenta")
+	fs.Parse(args)
+
+	if *email == "" {
+		return fmt.Errorf("--email is required")
+	}
+	if err := connect(); err != nil {
+		return err
+	}
+
+	user, err := services.PromoteAdmin(*email)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now admin\n", *user.Email)
+	return nil
+}
@@ -32,6 +32,36 @@ func main() {
 		log.Printf("Warning: Failed to seed database: %v", err)
 	}
 
+	// Recarga del catalogo en caliente, solo si lo prenden
+	stopWatcher := func() {}
+	if cfg.CatalogWatch {
+		stopWatcher = services.StartCatalogWatcher(services.CatalogDir(), cfg.CatalogWatchInterval, cfg.CatalogWatchDebounce)
+	}
+
+	// Recomendaciones "comprados juntos" en background
+	stopRecommendations := func() {}
+	if cfg.RecommendationsInterval > 0 {
+		stopRecommendations = services.StartRecommendationWorker(cfg.RecommendationsInterval)
+	}
+
+	// Rebajas programadas: se aplican y se quitan solas
+	stopPriceScheduler := func() {}
+	if cfg.PriceScheduleInterval > 0 {
+		stopPriceScheduler = services.StartPriceScheduler(cfg.PriceScheduleInterval)
+	}
+
+	// Productos programados: se publican solos a su hora
+	stopPublisher := func() {}
+	if cfg.ProductPublishInterval > 0 {
+		stopPublisher = services.StartProductPublisher(cfg.ProductPublishInterval)
+	}
+
+	// Alertas de stock bajo con digest por correo
+	stopStockAlerts := func() {}
+	if cfg.LowStockInterval > 0 {
+		stopStockAlerts = services.StartStockAlertWorker(cfg.LowStockInterval)
+	}
+
 	// Armamos el router bacan
 	r := router.SetupRouter()
 	log.Println("✓ Router configured")
@@ -51,6 +81,11 @@ func main() {
 	<-sigChan
 
 	log.Println("Shutting down server...")
+	stopWatcher()
+	stopRecommendations()
+	stopPriceScheduler()
+	stopPublisher()
+	stopStockAlerts()
 	if err := database.Close(); err != nil {
 		log.Printf("Error closing database: %v", err)
 	}
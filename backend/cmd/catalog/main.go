@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+const usage = `Uso:
+  catalog import --store <tienda> --file <archivo.json|csv> [--dry-run] [--archive-missing]
+  catalog export --store <tienda> [--format json|csv] [--out <archivo>]
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "import":
+		err = runImport(os.Args[2:])
+	case "export":
+		err = runExport(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("catalog %s: %v", os.Args[1], err)
+	}
+}
+
+// connect carga config y DB igual que la API
+func connect() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return database.Initialize(cfg)
+}
+
+// formatFor decide el formato por flag o por extension del archivo
+func formatFor(format, path string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch format {
+	case "json", "csv":
+		return format, nil
+	case "":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("unsupported format %q (use json or csv)", format)
+	}
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	storeName := fs.String("store", "", "nombre de la tienda")
+	path := fs.String("file", "", "archivo a importar")
+	format := fs.String("format", "", "json o csv (por defecto sale de la extension)")
+	dryRun := fs.Bool("dry-run", false, "solo muestra el diff, no cambia nada")
+	archiveMissing := fs.Bool("archive-missing", false, "archiva los productos que no estan en el archivo")
+	fs.Parse(args)
+
+	if *storeName == "" || *path == "" {
+		return fmt.Errorf("--store and --file are required")
+	}
+	kind, err := formatFor(*format, *path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var records []services.CatalogRecord
+	var errs []services.CatalogError
+	if kind == "csv" {
+		records, errs = services.ReadCatalogCSV(file)
+	} else {
+		records, errs = services.ReadCatalogJSON(file)
+	}
+
+	// Errores de validacion: los mostramos todos y no tocamos la DB
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *path, e)
+		}
+		return fmt.Errorf("%d validation errors, nothing was imported", len(errs))
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	store, err := services.FindStoreByName(*storeName)
+	if err != nil {
+		return err
+	}
+
+	var plan *services.CatalogPlan
+	if *dryRun {
+		plan, err = services.PlanCatalog(store, records, *archiveMissing)
+	} else {
+		plan, err = services.ApplyCatalog(store, records, *archiveMissing)
+	}
+	if err != nil {
+		return err
+	}
+
+	printPlan(os.Stdout, plan)
+	if *dryRun {
+		fmt.Println("Dry run: no changes were made")
+	}
+	return nil
+}
+
+// printPlan muestra el diff producto por producto
+func printPlan(w io.Writer, plan *services.CatalogPlan) {
+	for _, change := range plan.Changes {
+		if change.Action == services.CatalogUnchanged {
+			continue
+		}
+		fmt.Fprintf(w, "%-7s %s\n", change.Action, change.Product)
+		for _, detail := range change.Details {
+			fmt.Fprintf(w, "        %s\n", detail)
+		}
+	}
+	fmt.Fprintf(w, "%s: %d to create, %d to update, %d to archive, %d unchanged, %d skipped\n",
+		plan.Store,
+		plan.Count(services.CatalogCreate),
+		plan.Count(services.CatalogUpdate),
+		plan.Count(services.CatalogArchive),
+		plan.Count(services.CatalogUnchanged),
+		plan.Count(services.CatalogSkip))
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	storeName := fs.String("store", "", "nombre de la tienda")
+	out := fs.String("out", "", "archivo de salida (por defecto stdout)")
+	format := fs.String("format", "", "json o csv (por defecto sale de la extension)")
+	fs.Parse(args)
+
+	if *storeName == "" {
+		return fmt.Errorf("--store is required")
+	}
+	kind, err := formatFor(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	if err := connect(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	store, err := services.FindStoreByName(*storeName)
+	if err != nil {
+		return err
+	}
+
+	products, err := services.ExportCatalog(store.ID)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if kind == "csv" {
+		err = services.WriteCatalogCSV(w, products)
+	} else {
+		err = services.WriteCatalogJSON(w, products)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+
+	log.Printf("✓ Exported %d products from %s", len(products), store.Name)
+	return nil
+}
@@ -0,0 +1,655 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa crear o actualizar producto desde el admin
+type AdminProductRequest struct {
+	StoreID           uuid.UUID                     `json:"store_id"`
+	Name              string                        `json:"name" binding:"required"`
+	NameEN            string                        `json:"name_en"`
+	Description       string                        `json:"description"`
+	DescriptionEN     string                        `json:"description_en"`
+	Category          string                        `json:"category"`
+	CategoryEN        string                        `json:"category_en"` // mismos niveles que category
+	CategoryID        *uuid.UUID                    `json:"category_id"`
+	Price             float64                       `json:"price" binding:"required,gt=0"`
+	CompareAtPrice    *float64                      `json:"compare_at_price"`
+	ImagePath         string                        `json:"image_path"`
+	Images            []services.ProductImageJSON   `json:"images"`
+	Variants          []services.ProductVariantJSON `json:"variants" binding:"required,min=1"`
+	PublishedAt       *time.Time                    `json:"published_at"`
+	Status            string                        `json:"status"`              // solo al crear; vacio = published
+	LowStockThreshold *int                          `json:"low_stock_threshold"` // nil = el de la tienda
+	Version           int                           `json:"version"`
+}
+
+// Peti que solo lleva la version (archivar/restaurar)
+type AdminVersionRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// Peti pa cambiar el estado del producto (publish_at solo pa scheduled)
+type AdminProductStatusRequest struct {
+	Status    string     `json:"status" binding:"required"`
+	PublishAt *time.Time `json:"publish_at"`
+	Version   int        `json:"version" binding:"required,min=1"`
+}
+
+// Peti pa crear o actualizar tienda
+type AdminStoreRequest struct {
+	Name               string            `json:"name" binding:"required"`
+	Slug               string            `json:"slug"` // vacio = sale del nombre
+	Description        string            `json:"description"`
+	LogoPath           string            `json:"logo_path"`
+	Banners            []string          `json:"banners"` // null = no tocar
+	ContactEmail       string            `json:"contact_email"`
+	ContactPhone       string            `json:"contact_phone"`
+	WhatsApp           string            `json:"whatsapp"`
+	SocialLinks        map[string]string `json:"social_links"` // null = no tocar
+	ShippingOriginCity string            `json:"shipping_origin_city"`
+	ShippingPolicy     string            `json:"shipping_policy"`
+	ReturnPolicy       string            `json:"return_policy"`
+	SortOrder          int               `json:"sort_order"`
+	LowStockThreshold  *int              `json:"low_stock_threshold"` // nil = el de la config
+	Version            int               `json:"version"`
+}
+
+func (req AdminStoreRequest) input() services.StoreInput {
+	return services.StoreInput{
+		Name:               req.Name,
+		Slug:               req.Slug,
+		Description:        req.Description,
+		LogoPath:           req.LogoPath,
+		Banners:            req.Banners,
+		ContactEmail:       req.ContactEmail,
+		ContactPhone:       req.ContactPhone,
+		WhatsApp:           req.WhatsApp,
+		SocialLinks:        req.SocialLinks,
+		ShippingOriginCity: req.ShippingOriginCity,
+		ShippingPolicy:     req.ShippingPolicy,
+		ReturnPolicy:       req.ReturnPolicy,
+		SortOrder:          req.SortOrder,
+		LowStockThreshold:  req.LowStockThreshold,
+	}
+}
+
+// Una foto en el orden nuevo de la galeria
+type ImageOrderItem struct {
+	ID      uuid.UUID `json:"id" binding:"required"`
+	AltES   string    `json:"alt_es"`
+	AltEN   string    `json:"alt_en"`
+	Primary bool      `json:"primary"`
+}
+
+// Peti pa reordenar la galeria
+type ReorderImagesRequest struct {
+	Images []ImageOrderItem `json:"images" binding:"required,min=1,dive"`
+}
+
+// Peti pa darle acceso a un staff
+type AssignStaffRequest struct {
+	Email   string    `json:"email" binding:"required"`
+	StoreID uuid.UUID `json:"store_id" binding:"required"`
+}
+
+// staffAccess saca el acceso que dejo el StaffMiddleware
+func staffAccess(c *gin.Context) *services.StaffAccess {
+	access, _ := c.Get("staff_access")
+	return access.(*services.StaffAccess)
+}
+
+// adminError traduce errores del servicio a status HTTP
+func adminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVersionConflict), errors.Is(err, services.ErrCategoryInUse),
+		errors.Is(err, services.ErrPromotionInUse), errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrSizeChartExists), errors.Is(err, services.ErrStoreInUse),
+		errors.Is(err, services.ErrSKUInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrImageTooLarge), errors.Is(err, utils.ErrImageDimensions):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedCurrency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case strings.HasSuffix(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case isInternalError(err):
+		// El detalle (SQL, conexion, disco) queda en el log, no le llega al cliente
+		log.Printf("admin %s %s: %v", c.Request.Method, c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// isInternalError separa las fallas nuestras de las de validacion: los servicios
+// envuelven con %w lo que viene de la DB o del disco ("failed to ...: %w") y a
+// veces devuelven el error de postgres pelado. La validacion son errores planos.
+func isInternalError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Unwrap(err) != nil || errors.As(err, &pgErr)
+}
+
+func formatAdminProduct(product *models.Product) gin.H {
+	var variants []gin.H
+	for _, variant := range product.Variants {
+		variants = append(variants, gin.H{
+			"id":      variant.ID,
+			"size":    variant.Size,
+			"color":   variant.Color,
+			"sku":     variant.SKU,
+			"barcode": variant.Barcode,
+			"stock":   variant.Stock,
+			"price":   variant.Price,
+			"active":  variant.Active,
+		})
+	}
+
+	sizes, _ := product.GetSizes()
+	return gin.H{
+		"id":                  product.ID,
+		"store_id":            product.StoreID,
+		"store_name":          product.Store.Name,
+		"name":                product.Name,
+		"name_en":             product.NameEN,
+		"slug":                product.Slug,
+		"description":         product.Description,
+		"description_en":      product.DescriptionEN,
+		"category":            product.Category,
+		"category_en":         product.CategoryEN,
+		"category_id":         product.CategoryID,
+		"price":               product.Price,
+		"compare_at_price":    product.CompareAtPrice,
+		"available_units":     product.AvailableUnits,
+		"low_stock_threshold": product.LowStockThreshold,
+		"rating_average":      product.RatingAverage,
+		"review_count":        product.ReviewCount,
+		"image_path":          product.ImagePath,
+		"image_url":           imageURL(product.ImagePath),
+		"images":              formatImages(product.Images),
+		"sizes":               sizes,
+		"variants":            variants,
+		"version":             product.Version,
+		"status":              product.Status,
+		"published_at":        product.PublishedAt,
+		"archived_at":         product.ArchivedAt,
+		"created_at":          product.CreatedAt,
+		"updated_at":          product.UpdatedAt,
+	}
+}
+
+func formatAdminStore(store *models.Store) gin.H {
+	formatted := formatStore(store)
+	formatted["logo_path"] = store.LogoPath
+	formatted["banner_paths"], _ = store.GetBanners()
+	formatted["sort_order"] = store.SortOrder
+	formatted["low_stock_threshold"] = store.LowStockThreshold
+	formatted["version"] = store.Version
+	formatted["archived_at"] = store.ArchivedAt
+	formatted["created_at"] = store.CreatedAt
+	formatted["updated_at"] = store.UpdatedAt
+	return formatted
+}
+
+func (req AdminProductRequest) input() services.ProductInput {
+	return services.ProductInput{
+		StoreID:           req.StoreID,
+		Name:              req.Name,
+		NameEN:            req.NameEN,
+		Description:       req.Description,
+		DescriptionEN:     req.DescriptionEN,
+		Category:          req.Category,
+		CategoryEN:        req.CategoryEN,
+		CategoryID:        req.CategoryID,
+		Price:             req.Price,
+		CompareAtPrice:    req.CompareAtPrice,
+		ImagePath:         req.ImagePath,
+		Images:            req.Images,
+		Variants:          req.Variants,
+		PublishedAt:       req.PublishedAt,
+		Status:            req.Status,
+		LowStockThreshold: req.LowStockThreshold,
+	}
+}
+
+// Listar productos del admin (solo tiendas con acceso)
+func AdminListProducts(c *gin.Context) {
+	page := 1
+	limit := 20
+
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var storeID *uuid.UUID
+	if sid := c.Query("store_id"); sid != "" {
+		parsed, err := uuid.Parse(sid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	includeArchived := c.Query("include_archived") == "true"
+
+	products, total, err := services.ListAdminProducts(staffAccess(c), storeID, c.Query("status"), includeArchived, page, limit)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	var formattedProducts []gin.H
+	for i := range products {
+		formattedProducts = append(formattedProducts, formatAdminProduct(&products[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+		"products": formattedProducts,
+	})
+}
+
+// Traer un producto del admin (incluye archivados)
+func AdminGetProduct(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	product, err := services.GetAdminProduct(staffAccess(c), productID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminProduct(product))
+}
+
+// Crear producto
+func AdminCreateProduct(c *gin.Context) {
+	var req AdminProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if req.StoreID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "store_id is required"})
+		return
+	}
+
+	product, err := services.CreateProduct(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatAdminProduct(product))
+}
+
+// Actualizar producto (manda la version que leyo)
+func AdminUpdateProduct(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req AdminProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if req.Version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+
+	product, err := services.UpdateProduct(staffAccess(c), productID, req.Version, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminProduct(product))
+}
+
+// Archivar producto
+func AdminArchiveProduct(c *gin.Context) {
+	adminSetProductArchived(c, services.ArchiveProduct)
+}
+
+// Restaurar producto archivado
+func AdminRestoreProduct(c *gin.Context) {
+	adminSetProductArchived(c, services.RestoreProduct)
+}
+
+// Cambiar estado: draft, scheduled (con publish_at), published o archived
+func AdminSetProductStatus(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req AdminProductStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := services.SetProductStatus(staffAccess(c), productID, req.Version, req.Status, req.PublishAt)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminProduct(product))
+}
+
+func adminSetProductArchived(c *gin.Context, action func(*services.StaffAccess, uuid.UUID, int) (*models.Product, error)) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req AdminVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+
+	product, err := action(staffAccess(c), productID, req.Version)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminProduct(product))
+}
+
+// Subir imagen del producto (multipart, campo "image")
+func AdminUploadProductImage(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	// Cortamos el body de una; el margen es pa los headers del multipart
+	maxSize := config.Get().MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+64*1024)
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			adminError(c, services.ErrImageTooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+		return
+	}
+
+	if fileHeader.Size > maxSize {
+		adminError(c, services.ErrImageTooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read image"})
+		return
+	}
+	defer file.Close()
+
+	primary := c.PostForm("primary") == "true"
+	upload, err := services.UploadProductImage(staffAccess(c), productID, file, primary)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":           upload.ID,
+		"product_id":   upload.ProductID,
+		"path":         upload.Path,
+		"image_url":    imageURL(upload.Path),
+		"content_type": upload.ContentType,
+		"size":         upload.Size,
+		"message":      "image uploaded",
+	})
+}
+
+// Reordenar galeria, cambiar textos alt y principal
+func AdminReorderProductImages(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req ReorderImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	var order []services.ImageOrderInput
+	for _, item := range req.Images {
+		order = append(order, services.ImageOrderInput{
+			ID:      item.ID,
+			AltES:   item.AltES,
+			AltEN:   item.AltEN,
+			Primary: item.Primary,
+		})
+	}
+
+	images, err := services.ReorderProductImages(staffAccess(c), productID, order)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": formatImages(images)})
+}
+
+// Borrar una foto de la galeria
+func AdminDeleteProductImage(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	imageID, err := uuid.Parse(c.Param("image_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image id"})
+		return
+	}
+
+	if err := services.DeleteProductImage(staffAccess(c), productID, imageID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "image removed"})
+}
+
+// Listar tiendas del admin
+func AdminListStores(c *gin.Context) {
+	stores, err := services.ListAdminStores(staffAccess(c))
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	var formattedStores []gin.H
+	for i := range stores {
+		formattedStores = append(formattedStores, formatAdminStore(&stores[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stores": formattedStores})
+}
+
+// Crear tienda (solo admin)
+func AdminCreateStore(c *gin.Context) {
+	var req AdminStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	store, err := services.CreateStore(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatAdminStore(store))
+}
+
+// Actualizar tienda
+func AdminUpdateStore(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+		return
+	}
+
+	var req AdminStoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if req.Version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+
+	store, err := services.UpdateStore(staffAccess(c), storeID, req.Version, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminStore(store))
+}
+
+// Archivar tienda (solo admin)
+func AdminArchiveStore(c *gin.Context) {
+	adminSetStoreArchived(c, services.ArchiveStore)
+}
+
+// Restaurar tienda (solo admin)
+func AdminRestoreStore(c *gin.Context) {
+	adminSetStoreArchived(c, services.RestoreStore)
+}
+
+func adminSetStoreArchived(c *gin.Context, action func(*services.StaffAccess, uuid.UUID, int) (*models.Store, error)) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+		return
+	}
+
+	var req AdminVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version is required"})
+		return
+	}
+
+	store, err := action(staffAccess(c), storeID, req.Version)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminStore(store))
+}
+
+// Darle acceso de staff a un user sobre una tienda (solo admin)
+func AdminAssignStaff(c *gin.Context) {
+	var req AssignStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	staff, err := services.AssignStaff(staffAccess(c), req.Email, req.StoreID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":       staff.ID,
+		"store_id": staff.StoreID,
+		"user_id":  staff.UserID,
+		"message":  "staff assigned",
+	})
+}
+
+// Estado del watcher y ultimos reportes de sync del catalogo (solo admin)
+func AdminCatalogStatus(c *gin.Context) {
+	if !staffAccess(c).IsAdmin() {
+		adminError(c, services.ErrForbidden)
+		return
+	}
+
+	c.JSON(http.StatusOK, services.GetCatalogStatus())
+}
+
+// Productos y variantes bajos de stock o agotados (?store_id= opcional)
+func AdminListLowStock(c *gin.Context) {
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	items, err := services.ListLowStock(staffAccess(c), storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"total": len(items), "items": items})
+}
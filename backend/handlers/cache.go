@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// catalogValidators saca ETag y Last-Modified de un set de productos.
+// El ETag incluye IDs y UpdatedAt de cada uno, asi si un producto sale del
+// listado (archivado) tambien cambia aunque el max UpdatedAt sea el mismo.
+// variant (idioma, moneda) entra al hash pa que las versiones no choquen entre si.
+func catalogValidators(products []models.Product, total int64, variant string) (string, time.Time) {
+	var lastModified time.Time
+	h := sha256.New()
+	fmt.Fprintf(h, "variant=%s;total=%d;", variant, total)
+
+	for _, product := range products {
+		updated := product.UpdatedAt
+		if product.Store.UpdatedAt.After(updated) {
+			updated = product.Store.UpdatedAt
+		}
+		if updated.After(lastModified) {
+			lastModified = updated
+		}
+		fmt.Fprintf(h, "%s:%d;", product.ID, updated.UnixNano())
+	}
+
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, lastModified
+}
+
+// notModified pone ETag/Last-Modified y responde 304 si el cliente ya lo tiene
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-None-Match manda sobre If-Modified-Since (RFC 9110)
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				c.Status(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if since, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(since) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,18 +3,21 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
 )
 
 // Peticion pa meter item al carrito
 type AddCartItemRequest struct {
-	ProductID uuid.UUID `json:"product_id" binding:"required"`
-	Quantity  int       `json:"quantity" binding:"required,min=1"`
-	Size      string    `json:"size"`
-	SessionID string    `json:"session_id"`
+	ProductID uuid.UUID  `json:"product_id" binding:"required"`
+	VariantID *uuid.UUID `json:"variant_id"`
+	Quantity  int        `json:"quantity" binding:"required,min=1"`
+	Size      string     `json:"size"`
+	Color     string     `json:"color"`
 }
 
 // Peticion pa actualizar item del carrito
@@ -23,25 +26,10 @@ type UpdateCartItemRequest struct {
 	Size     string `json:"size"`
 }
 
-// Traer el carrito del user o de la sesion
+// Traer el carrito del user o de la sesion; al invitado nuevo se le emite su token
 func GetCart(c *gin.Context) {
-	var userID *uuid.UUID
-	var sessionID *string
-
-	// Miramos si esta autenticado
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
-			userID = &parsed
-		}
-	}
-
-	// Session id desde query
-	if sid := c.Query("session_id"); sid != "" {
-		sessionID = &sid
-	}
-
-	if userID == nil && sessionID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or session_id required"})
+	userID, sessionID, ok := cartOwner(c, true)
+	if !ok {
 		return
 	}
 
@@ -51,67 +39,187 @@ func GetCart(c *gin.Context) {
 		return
 	}
 
+	respondCart(c, cart, userID, sessionID)
+}
+
+// respondCart arma la respuesta del carrito con precios, descuentos y recomendaciones
+// Con ?currency= los montos traen su estimado en display_*; se cobra en COP igual.
+func respondCart(c *gin.Context, cart *models.Cart, userID *uuid.UUID, sessionID *string) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
+
 	items, err := services.GetCartItems(cart.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	total, err := services.GetCartTotal(cart.ID)
+	pricing, err := services.PriceCart(cart.ID, userID, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	lines := make(map[uuid.UUID]services.PricingLine, len(pricing.Lines))
+	for _, line := range pricing.Lines {
+		lines[line.ItemID] = line
+	}
 
 	var formattedItems []gin.H
 	for _, item := range items {
-		formattedItems = append(formattedItems, gin.H{
+		line := lines[item.ID]
+		unitPrice := services.CartItemUnitPrice(item)
+		formatted := gin.H{
 			"id":           item.ID,
 			"product_id":   item.ProductID,
+			"variant_id":   item.VariantID,
 			"product_name": item.Product.Name,
 			"quantity":     item.Quantity,
 			"size":         item.Size,
-			"price":        item.Product.Price,
+			"price":        unitPrice,
+			"subtotal":     line.Subtotal,
+			"discounts":    formatDiscounts(line.Discounts),
+			"total":        line.Total,
 			"image_url":    imageURL(item.Product.ImagePath),
+		}
+		addMoney(formatted, view.Currency, "price", &unitPrice)
+		addMoney(formatted, view.Currency, "subtotal", &line.Subtotal)
+		addMoney(formatted, view.Currency, "total", &line.Total)
+		if item.Variant != nil {
+			formatted["color"] = item.Variant.Color
+			formatted["sku"] = item.Variant.SKU
+			formatted["stock"] = item.Variant.Stock
+		}
+		formattedItems = append(formattedItems, formatted)
+	}
+
+	coupons := []gin.H{}
+	for _, coupon := range pricing.Coupons {
+		formatted := gin.H{"code": coupon.Code, "applied": coupon.Applied}
+		if coupon.Reason != "" {
+			formatted["reason"] = coupon.Reason
+		}
+		coupons = append(coupons, formatted)
+	}
+
+	// Recomendaciones del carrito; si fallan no rompemos el carrito
+	recommendations := []gin.H{}
+	related, err := services.GetCartRecommendations(cart.ID, 4)
+	if err != nil {
+		log.Printf("GetCart recommendations: %v", err)
+	}
+	for i := range related {
+		recommendations = append(recommendations, formatProduct(&related[i], view))
+	}
+
+	response := gin.H{
+		"id":              cart.ID,
+		"total_items":     len(items),
+		"subtotal":        pricing.Subtotal,
+		"discount_total":  pricing.Discount,
+		"total_price":     pricing.Total,
+		"discounts":       formatDiscounts(pricing.Discounts),
+		"coupons":         coupons,
+		"items":           formattedItems,
+		"recommendations": recommendations,
+	}
+	addCurrency(response, view.Currency)
+	addMoney(response, view.Currency, "subtotal", &pricing.Subtotal)
+	addMoney(response, view.Currency, "discount_total", &pricing.Discount)
+	addMoney(response, view.Currency, "total_price", &pricing.Total)
+	c.JSON(http.StatusOK, response)
+}
+
+// formatDiscounts arma la lista de descuentos aplicados
+func formatDiscounts(discounts []services.AppliedDiscount) []gin.H {
+	formatted := []gin.H{}
+	for _, d := range discounts {
+		formatted = append(formatted, gin.H{
+			"promotion_id": d.PromotionID,
+			"name":         d.Name,
+			"code":         d.Code,
+			"amount":       d.Amount,
 		})
 	}
+	return formatted
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":          cart.ID,
-		"total_items": len(items),
-		"total_price": total,
-		"items":       formattedItems,
-	})
+// Peticion pa meter un cupon al carrito
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required"`
 }
 
-// Agregar item al carrito
-func AddItem(c *gin.Context) {
-	var req AddCartItemRequest
+// Aplicar cupon al carrito; responde el carrito con los precios nuevos
+func ApplyCoupon(c *gin.Context) {
+	var req ApplyCouponRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
 
-	// Log del request pa ver que llega
-	log.Printf("AddItem request: %+v\n", req)
+	userID, sessionID, ok := cartOwner(c, true)
+	if !ok {
+		return
+	}
 
-	var userID *uuid.UUID
-	var sessionID *string
+	cart, err := services.GetOrCreateCart(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Miramos si esta autenticado
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
-			userID = &parsed
+	if err := services.ApplyCartCoupon(cart.ID, req.Code); err != nil {
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
 		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Usamos session que manden
-	if req.SessionID != "" {
-		sessionID = &req.SessionID
+	respondCart(c, cart, userID, sessionID)
+}
+
+// Quitar cupon del carrito
+func RemoveCoupon(c *gin.Context) {
+	userID, sessionID, ok := cartOwner(c, false)
+	if !ok {
+		return
 	}
 
-	if userID == nil && sessionID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or session_id required"})
+	cart, err := services.GetOrCreateCart(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.RemoveCartCoupon(cart.ID, c.Param("code")); err != nil {
+		status := http.StatusInternalServerError
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondCart(c, cart, userID, sessionID)
+}
+
+// Agregar item al carrito
+func AddItem(c *gin.Context) {
+	var req AddCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	// Log del request pa ver que llega
+	log.Printf("AddItem request: %+v\n", req)
+
+	// Invitado sin token: aca arranca su sesion
+	userID, sessionID, ok := cartOwner(c, true)
+	if !ok {
 		return
 	}
 
@@ -121,7 +229,7 @@ func AddItem(c *gin.Context) {
 		return
 	}
 
-	item, err := services.AddItemToCart(cart.ID, req.ProductID, req.Quantity, req.Size)
+	item, err := services.AddItemToCart(cart.ID, req.ProductID, req.VariantID, req.Quantity, req.Size, req.Color)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -130,8 +238,11 @@ func AddItem(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"id":         item.ID,
 		"product_id": item.ProductID,
+		"variant_id": item.VariantID,
 		"quantity":   item.Quantity,
 		"size":       item.Size,
+		"color":      item.Variant.Color,
+		"sku":        item.Variant.SKU,
 		"message":    "item added to cart",
 	})
 }
@@ -150,20 +261,48 @@ func UpdateItem(c *gin.Context) {
 		return
 	}
 
-	item, err := services.UpdateCartItem(itemID, req.Quantity, req.Size)
+	cart, ok := callerCart(c)
+	if !ok {
+		return
+	}
+
+	item, err := services.UpdateCartItem(cart.ID, itemID, req.Quantity, req.Size)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":       item.ID,
-		"quantity": item.Quantity,
-		"size":     item.Size,
-		"message":  "cart item updated",
+		"id":         item.ID,
+		"variant_id": item.VariantID,
+		"quantity":   item.Quantity,
+		"size":       item.Size,
+		"color":      item.Variant.Color,
+		"sku":        item.Variant.SKU,
+		"message":    "cart item updated",
 	})
 }
 
+// callerCart trae el carrito de quien llama pa validar que el item sea suyo
+func callerCart(c *gin.Context) (*models.Cart, bool) {
+	userID, sessionID, ok := cartOwner(c, false)
+	if !ok {
+		return nil, false
+	}
+
+	cart, err := services.FindCart(userID, sessionID)
+	if err != nil {
+		// Sin carrito tampoco hay items suyos
+		c.JSON(http.StatusNotFound, gin.H{"error": "cart item not found"})
+		return nil, false
+	}
+	return cart, true
+}
+
 // Quitar item del carrito
 func RemoveItem(c *gin.Context) {
 	itemID, err := uuid.Parse(c.Param("item_id"))
@@ -172,8 +311,17 @@ func RemoveItem(c *gin.Context) {
 		return
 	}
 
-	if err := services.RemoveCartItem(itemID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	cart, ok := callerCart(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RemoveCartItem(cart.ID, itemID); err != nil {
+		status := http.StatusInternalServerError
+		if strings.HasSuffix(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -182,29 +330,14 @@ func RemoveItem(c *gin.Context) {
 
 // Vaciar el carrito completo
 func ClearCart(c *gin.Context) {
-	var userID *uuid.UUID
-	var sessionID *string
-
-	// Miramos si esta autenticado
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
-			userID = &parsed
-		}
-	}
-
-	// Sacamos session id
-	if sid := c.Query("session_id"); sid != "" {
-		sessionID = &sid
-	}
-
-	if userID == nil && sessionID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or session_id required"})
+	userID, sessionID, ok := cartOwner(c, false)
+	if !ok {
 		return
 	}
 
-	cart, err := services.GetOrCreateCart(userID, sessionID)
+	cart, err := services.FindCart(userID, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/utils"
+)
+
+// El token del carrito de invitado viaja en cookie (navegador) o en header (apps/otros origenes)
+const (
+	cartTokenCookie = "cart_token"
+	cartTokenHeader = "X-Cart-Token"
+)
+
+// cartTokenSession saca el session id del token firmado; un token malo o vencido cuenta como ninguno
+func cartTokenSession(c *gin.Context) string {
+	token := strings.TrimSpace(c.GetHeader(cartTokenHeader))
+	if token == "" {
+		token, _ = c.Cookie(cartTokenCookie)
+	}
+	if token == "" {
+		return ""
+	}
+
+	sessionID, err := utils.ValidateCartToken(token)
+	if err != nil {
+		return ""
+	}
+	return sessionID
+}
+
+// setCartToken firma la sesion y la manda en cookie y header; cada respuesta renueva el vencimiento
+func setCartToken(c *gin.Context, sessionID string) {
+	token, err := utils.GenerateCartToken(sessionID)
+	if err != nil {
+		log.Printf("setCartToken: %v", err)
+		return
+	}
+
+	cfg := config.Get()
+	secure := cfg.ServerEnv == "production"
+	// En produccion el frontend vive en otro origen: la cookie tiene que ser None + Secure
+	sameSite := http.SameSiteLaxMode
+	if secure {
+		sameSite = http.SameSiteNoneMode
+	}
+	c.SetSameSite(sameSite)
+	c.SetCookie(cartTokenCookie, token, int(cfg.CartTokenTTL.Seconds()), "/", "", secure, true)
+	c.Header(cartTokenHeader, token)
+}
+
+// cartOwner dice de quien es el carrito: el user logueado o la sesion del token.
+// Con issue, si es invitado sin token se le abre una sesion nueva. Si no hay a quien
+// asociar el carrito responde 400 y devuelve false.
+func cartOwner(c *gin.Context, issue bool) (*uuid.UUID, *string, bool) {
+	var userID *uuid.UUID
+	if userIDStr, exists := c.Get("user_id"); exists {
+		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
+			userID = &parsed
+		}
+	}
+
+	var sessionID *string
+	if sid := cartTokenSession(c); sid != "" {
+		sessionID = &sid
+	} else if userID == nil && issue {
+		sid := uuid.NewString()
+		sessionID = &sid
+	}
+
+	if userID == nil && sessionID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login or cart token required"})
+		return nil, nil, false
+	}
+
+	if sessionID != nil {
+		setCartToken(c, *sessionID)
+	}
+	return userID, sessionID, true
+}
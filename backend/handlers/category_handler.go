@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+// Peti pa crear o actualizar categoria
+type CategoryRequest struct {
+	StoreID   uuid.UUID  `json:"store_id"`
+	ParentID  *uuid.UUID `json:"parent_id"`
+	Name      string     `json:"name" binding:"required"`
+	NameEN    string     `json:"name_en"`
+	Slug      string     `json:"slug"`
+	SortOrder int        `json:"sort_order"`
+}
+
+func (req CategoryRequest) input() services.CategoryInput {
+	return services.CategoryInput{
+		StoreID:   req.StoreID,
+		ParentID:  req.ParentID,
+		Name:      req.Name,
+		NameEN:    req.NameEN,
+		Slug:      req.Slug,
+		SortOrder: req.SortOrder,
+	}
+}
+
+func formatCategoryNodes(nodes []services.CategoryNode, lang string) []gin.H {
+	formatted := []gin.H{}
+	for _, node := range nodes {
+		formatted = append(formatted, gin.H{
+			"id":            node.Category.ID,
+			"parent_id":     node.Category.ParentID,
+			"slug":          node.Category.Slug,
+			"name":          localized(lang, node.Category.Name, node.Category.NameEN),
+			"sort_order":    node.Category.SortOrder,
+			"product_count": node.ProductCount,
+			"children":      formatCategoryNodes(node.Children, lang),
+		})
+	}
+	return formatted
+}
+
+// Arbol de categorias pa los menus; ?store_id= pa una sola tienda
+func GetCategoryTree(c *gin.Context) {
+	lang := negotiateLang(c)
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	trees, err := services.GetCategoryTrees(storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stores := []gin.H{}
+	for _, tree := range trees {
+		stores = append(stores, gin.H{
+			"store_id":   tree.Store.ID,
+			"store_name": tree.Store.Name,
+			"categories": formatCategoryNodes(tree.Categories, lang),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stores": stores})
+}
+
+// Crear categoria (admin o staff de la tienda)
+func AdminCreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := services.CreateCategory(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// Actualizar categoria
+func AdminUpdateCategory(c *gin.Context) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+		return
+	}
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	category, err := services.UpdateCategory(staffAccess(c), categoryID, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// Borrar categoria (solo si esta vacia)
+func AdminDeleteCategory(c *gin.Context) {
+	categoryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category id"})
+		return
+	}
+
+	if err := services.DeleteCategory(staffAccess(c), categoryID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+}
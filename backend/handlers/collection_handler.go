@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa crear o actualizar coleccion
+type CollectionRequest struct {
+	StoreID     uuid.UUID   `json:"store_id"`
+	Name        string      `json:"name" binding:"required"`
+	Slug        string      `json:"slug"`
+	Description string      `json:"description"`
+	BannerPath  string      `json:"banner_path"`
+	StartsAt    *time.Time  `json:"starts_at"`
+	EndsAt      *time.Time  `json:"ends_at"`
+	SortOrder   int         `json:"sort_order"`
+	ProductIDs  []uuid.UUID `json:"product_ids"` // en orden; si no viene no se tocan
+}
+
+func (req CollectionRequest) input() services.CollectionInput {
+	return services.CollectionInput{
+		StoreID:     req.StoreID,
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		BannerPath:  req.BannerPath,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		SortOrder:   req.SortOrder,
+		ProductIDs:  req.ProductIDs,
+	}
+}
+
+func formatCollection(collection *models.Collection) gin.H {
+	formatted := gin.H{
+		"slug":        collection.Slug,
+		"name":        collection.Name,
+		"description": collection.Description,
+		"banner_url":  nil,
+		"starts_at":   collection.StartsAt,
+		"ends_at":     collection.EndsAt,
+	}
+	if collection.ID != uuid.Nil {
+		formatted["id"] = collection.ID
+	}
+	if collection.BannerPath != "" {
+		formatted["banner_url"] = imageURL(collection.BannerPath)
+	}
+	return formatted
+}
+
+func formatCollectionSummary(summary *services.CollectionSummary) gin.H {
+	formatted := formatCollection(&summary.Collection)
+	formatted["automatic"] = summary.Automatic
+	formatted["product_count"] = summary.ProductCount
+	return formatted
+}
+
+func formatAdminCollection(collection *models.Collection) gin.H {
+	formatted := formatCollection(collection)
+	formatted["store_id"] = collection.StoreID
+	formatted["store_name"] = collection.Store.Name
+	formatted["banner_path"] = collection.BannerPath
+	formatted["sort_order"] = collection.SortOrder
+	formatted["active"] = collection.ActiveAt(time.Now())
+	formatted["created_at"] = collection.CreatedAt
+	formatted["updated_at"] = collection.UpdatedAt
+
+	products := []gin.H{}
+	for _, item := range collection.Products {
+		products = append(products, gin.H{
+			"product_id":  item.ProductID,
+			"name":        item.Product.Name,
+			"position":    item.Position,
+			"image_url":   imageURL(item.Product.ImagePath),
+			"archived_at": item.Product.ArchivedAt,
+		})
+	}
+	formatted["products"] = products
+	return formatted
+}
+
+// Colecciones visibles de una tienda (?store_id=), con lo nuevo primero
+func GetCollections(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Query("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "valid store_id is required"})
+		return
+	}
+
+	summaries, err := services.ListCollections(storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range summaries {
+		formatted = append(formatted, formatCollectionSummary(&summaries[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"store_id": storeID, "collections": formatted})
+}
+
+// Una coleccion con sus productos paginados; "nuevo" es la automatica
+func GetCollection(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
+
+	storeID, err := uuid.Parse(c.Param("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	summary, products, err := services.GetCollectionProducts(storeID, c.Param("slug"), page, limit)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formattedProducts := []gin.H{}
+	for i := range products {
+		formattedProducts = append(formattedProducts, formatProduct(&products[i], view))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": formatCollectionSummary(summary),
+		"total":      summary.ProductCount,
+		"page":       page,
+		"limit":      limit,
+		"products":   formattedProducts,
+	})
+}
+
+// Listar colecciones del admin (?store_id= opcional)
+func AdminListCollections(c *gin.Context) {
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	summaries, err := services.ListAdminCollections(staffAccess(c), storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range summaries {
+		item := formatAdminCollection(&summaries[i].Collection)
+		item["product_count"] = summaries[i].ProductCount
+		delete(item, "products")
+		formatted = append(formatted, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": formatted})
+}
+
+// Ver coleccion del admin con todos sus productos
+func AdminGetCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	collection, err := services.GetAdminCollection(staffAccess(c), collectionID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminCollection(collection))
+}
+
+// Crear coleccion
+func AdminCreateCollection(c *gin.Context) {
+	var req CollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := services.CreateCollection(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatAdminCollection(collection))
+}
+
+// Actualizar coleccion (datos, fechas y orden de productos)
+func AdminUpdateCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	var req CollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection, err := services.UpdateCollection(staffAccess(c), collectionID, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminCollection(collection))
+}
+
+// Borrar coleccion
+func AdminDeleteCollection(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	if err := services.DeleteCollection(staffAccess(c), collectionID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "collection deleted"})
+}
+
+// Subir banner de la coleccion (multipart, campo "image")
+func AdminUploadCollectionBanner(c *gin.Context) {
+	collectionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid collection id"})
+		return
+	}
+
+	maxSize := config.Get().MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+64*1024)
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			adminError(c, services.ErrImageTooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		adminError(c, services.ErrImageTooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read image"})
+		return
+	}
+	defer file.Close()
+
+	collection, err := services.UploadCollectionBanner(staffAccess(c), collectionID, file)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminCollection(collection))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+// catalogView es como pidio el cliente ver el catalogo: idioma y moneda de los estimados
+type catalogView struct {
+	Lang     string
+	Currency services.DisplayCurrency
+}
+
+// catalogViewFor negocia idioma y lee ?currency=; si la moneda no sirve responde 400
+func catalogViewFor(c *gin.Context) (catalogView, bool) {
+	view := catalogView{Lang: negotiateLang(c)}
+
+	currency, ok := displayCurrency(c)
+	if !ok {
+		return view, false
+	}
+	view.Currency = currency
+	return view, true
+}
+
+// displayCurrency lee ?currency= (vacio = COP); responde 400 si no la manejamos
+func displayCurrency(c *gin.Context) (services.DisplayCurrency, bool) {
+	currency, err := services.GetDisplayCurrency(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return currency, false
+	}
+	return currency, true
+}
+
+// key separa las variantes de la misma respuesta pa el ETag (la tasa entra, asi cambia si la mueven)
+func (v catalogView) key() string {
+	return fmt.Sprintf("%s|%s@%g", v.Lang, v.Currency.Code, v.Currency.Rate)
+}
+
+// addMoney pone junto al monto en COP su estimado: display_<field> y display_<field>_formatted
+func addMoney(response gin.H, currency services.DisplayCurrency, field string, cop *float64) {
+	if cop == nil {
+		response["display_"+field] = nil
+		return
+	}
+	response["display_"+field] = currency.Convert(*cop)
+	response["display_"+field+"_formatted"] = currency.Format(*cop)
+}
+
+// addCurrency marca en que se cobra y en que vienen los display_*
+func addCurrency(response gin.H, currency services.DisplayCurrency) {
+	response["currency"] = services.SettlementCurrency.Code
+	response["display_currency"] = currency.Code
+	response["exchange_rate"] = currency.Rate
+}
+
+// Peti pa poner la tasa de una moneda (cuantos COP vale 1 unidad)
+type ExchangeRateRequest struct {
+	Rate float64 `json:"rate" binding:"required,gt=0"`
+}
+
+func formatExchangeRate(rate services.ExchangeRateInfo) gin.H {
+	return gin.H{
+		"code":       rate.Code,
+		"rate":       rate.Rate,
+		"source":     rate.Source,
+		"updated_at": rate.UpdatedAt,
+	}
+}
+
+// Monedas disponibles pa mostrar precios, con su tasa; publico pa el selector del frontend
+func GetCurrencies(c *gin.Context) {
+	rates, err := services.ListExchangeRates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currencies := []gin.H{formatExchangeRate(services.ExchangeRateInfo{
+		Code:   services.SettlementCurrency.Code,
+		Rate:   services.SettlementCurrency.Rate,
+		Source: "settlement",
+	})}
+	for _, rate := range rates {
+		currencies = append(currencies, formatExchangeRate(rate))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settlement_currency": services.SettlementCurrency.Code,
+		"currencies":          currencies,
+	})
+}
+
+// Poner o cambiar la tasa de una moneda (solo admin)
+func AdminSetExchangeRate(c *gin.Context) {
+	var req ExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := services.SetExchangeRate(staffAccess(c), c.Param("code"), req.Rate)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatExchangeRate(*rate))
+}
+
+// Quitar la tasa del admin; vuelve a la de EXCHANGE_RATES si hay
+func AdminDeleteExchangeRate(c *gin.Context) {
+	if err := services.DeleteExchangeRate(staffAccess(c), c.Param("code")); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate deleted"})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+// serveFeed sirve un documento cacheado con ETag/Last-Modified
+func serveFeed(c *gin.Context, kind string) {
+	doc, err := services.GetCatalogFeed(kind)
+	if err != nil {
+		log.Printf("feed %s: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate feed"})
+		return
+	}
+
+	if notModified(c, doc.ETag, doc.LastModified) {
+		return
+	}
+
+	c.Data(http.StatusOK, doc.ContentType, doc.Body)
+}
+
+// Sitemap con tiendas, categorias y fichas de producto
+func GetSitemap(c *gin.Context) {
+	serveFeed(c, services.FeedSitemap)
+}
+
+// Feed de productos estilo Google Merchant en RSS 2.0
+func GetProductFeedXML(c *gin.Context) {
+	serveFeed(c, services.FeedMerchantXML)
+}
+
+// El mismo feed en CSV
+func GetProductFeedCSV(c *gin.Context) {
+	c.Header("Content-Disposition", `inline; filename="products.csv"`)
+	serveFeed(c, services.FeedMerchantCSV)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	langES = "es"
+	langEN = "en"
+)
+
+// negotiateLang elige el idioma de la respuesta: ?lang= manda, luego Accept-Language.
+// Lo que no soportamos cae a espanol, que es el idioma base del catalogo.
+func negotiateLang(c *gin.Context) string {
+	c.Header("Vary", "Accept-Language")
+
+	lang := langES
+	if q := supportedLang(c.Query("lang")); q != "" {
+		lang = q
+	} else if header := c.GetHeader("Accept-Language"); header != "" {
+		if picked := pickAcceptLanguage(header); picked != "" {
+			lang = picked
+		}
+	}
+
+	c.Header("Content-Language", lang)
+	return lang
+}
+
+// supportedLang normaliza "en-US" -> "en"; "" si no lo manejamos
+func supportedLang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i != -1 {
+		tag = tag[:i]
+	}
+	switch tag {
+	case langES, langEN:
+		return tag
+	}
+	return ""
+}
+
+// pickAcceptLanguage se queda con el soportado de mayor q (en empate, el primero)
+func pickAcceptLanguage(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+		pos  int
+	}
+
+	var candidates []candidate
+	for pos, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := supportedLang(fields[0])
+		if lang == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{lang: lang, q: q, pos: pos})
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// localized devuelve la traduccion si hay, si no el texto en espanol
+func localized(lang, es, en string) string {
+	if lang == langEN && en != "" {
+		return en
+	}
+	return es
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
 	"strconv"
 
@@ -12,7 +13,6 @@ import (
 
 // Peti para crear un pedido desde el carrito
 type CreateOrderRequest struct {
-	SessionID          string `json:"session_id"`
 	ShippingName       string `json:"shipping_name"`
 	ShippingPhone      string `json:"shipping_phone"`
 	ShippingEmail      string `json:"shipping_email"`
@@ -40,30 +40,15 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	var userID *uuid.UUID
-	var sessionID *string
-
-	// Miramos si esta logueado
-	if userIDStr, exists := c.Get("user_id"); exists {
-		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
-			userID = &parsed
-		}
-	}
-
-	// Usamos session del request
-	if req.SessionID != "" {
-		sessionID = &req.SessionID
-	}
-
-	if userID == nil && sessionID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or session_id required"})
+	// El carrito sale del user logueado o del token de invitado
+	userID, sessionID, ok := cartOwner(c, false)
+	if !ok {
 		return
 	}
 
-	// Sacamos o creamos el carrito
-	cart, err := services.GetOrCreateCart(userID, sessionID)
+	cart, err := services.FindCart(userID, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -84,30 +69,81 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Items del carrito pa responder
-	items, _ := services.GetCartItems(cart.ID)
+	// Recargamos el pedido con items y desglose de descuentos
+	if saved, err := services.GetOrder(order.ID); err == nil {
+		order = saved
+	}
 
+	discounts := orderDiscountsByItem(order)
 	var formattedItems []gin.H
-	for _, item := range items {
+	for _, item := range order.OrderItems {
 		formattedItems = append(formattedItems, gin.H{
 			"product_id":   item.ProductID,
+			"variant_id":   item.VariantID,
 			"product_name": item.Product.Name,
 			"quantity":     item.Quantity,
 			"size":         item.Size,
-			"unit_price":   item.Product.Price,
+			"unit_price":   item.UnitPrice,
+			"discounts":    discountsOrEmpty(discounts[item.ID]),
 		})
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"id":             order.ID,
-		"total_amount":   order.TotalAmount,
-		"status":         order.Status,
-		"payment_status": order.PaymentStatus,
-		"items":          formattedItems,
-		"created_at":     order.CreatedAt,
+		"id":              order.ID,
+		"subtotal_amount": order.SubtotalAmount,
+		"discount_amount": order.DiscountAmount,
+		"total_amount":    order.TotalAmount,
+		"currency":        order.Currency,
+		"discounts":       formatOrderDiscounts(order),
+		"status":          order.Status,
+		"payment_status":  order.PaymentStatus,
+		"items":           formattedItems,
+		"created_at":      order.CreatedAt,
 	})
 }
 
+// orderDiscountsByItem agrupa el desglose de descuentos por item del pedido
+func orderDiscountsByItem(order *models.Order) map[uuid.UUID][]gin.H {
+	byItem := make(map[uuid.UUID][]gin.H)
+	for _, d := range order.Discounts {
+		byItem[d.OrderItemID] = append(byItem[d.OrderItemID], gin.H{
+			"promotion_id": d.PromotionID,
+			"name":         d.Name,
+			"code":         d.Code,
+			"amount":       d.Amount,
+		})
+	}
+	return byItem
+}
+
+func discountsOrEmpty(discounts []gin.H) []gin.H {
+	if discounts == nil {
+		return []gin.H{}
+	}
+	return discounts
+}
+
+// formatOrderDiscounts suma el desglose por promo, en el orden en que se guardo
+func formatOrderDiscounts(order *models.Order) []gin.H {
+	formatted := []gin.H{}
+	index := make(map[uuid.UUID]int)
+	for _, d := range order.Discounts {
+		i, ok := index[d.PromotionID]
+		if !ok {
+			i = len(formatted)
+			index[d.PromotionID] = i
+			formatted = append(formatted, gin.H{
+				"promotion_id": d.PromotionID,
+				"name":         d.Name,
+				"code":         d.Code,
+				"amount":       0.0,
+			})
+		}
+		formatted[i]["amount"] = math.Round((formatted[i]["amount"].(float64)+d.Amount)*100) / 100
+	}
+	return formatted
+}
+
 // Obtener pedido por id
 func GetOrder(c *gin.Context) {
 	orderID, err := uuid.Parse(c.Param("id"))
@@ -122,25 +158,34 @@ func GetOrder(c *gin.Context) {
 		return
 	}
 
+	discounts := orderDiscountsByItem(order)
 	var formattedItems []gin.H
 	for _, item := range order.OrderItems {
 		formattedItems = append(formattedItems, gin.H{
 			"product_id":   item.ProductID,
+			"variant_id":   item.VariantID,
 			"product_name": item.Product.Name,
 			"quantity":     item.Quantity,
 			"size":         item.Size,
+			"color":        item.Color,
+			"sku":          item.SKU,
 			"unit_price":   item.UnitPrice,
+			"discounts":    discountsOrEmpty(discounts[item.ID]),
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":             order.ID,
-		"total_amount":   order.TotalAmount,
-		"status":         order.Status,
-		"payment_status": order.PaymentStatus,
-		"items":          formattedItems,
-		"created_at":     order.CreatedAt,
-		"updated_at":     order.UpdatedAt,
+		"id":              order.ID,
+		"subtotal_amount": order.SubtotalAmount,
+		"discount_amount": order.DiscountAmount,
+		"total_amount":    order.TotalAmount,
+		"currency":        order.Currency,
+		"discounts":       formatOrderDiscounts(order),
+		"status":          order.Status,
+		"payment_status":  order.PaymentStatus,
+		"items":           formattedItems,
+		"created_at":      order.CreatedAt,
+		"updated_at":      order.UpdatedAt,
 	})
 }
 
@@ -170,10 +215,11 @@ func GetOrders(c *gin.Context) {
 		if userID, err := uuid.Parse(userIDStr.(string)); err == nil {
 			orders, total, err = services.GetOrdersByUser(userID, page, limit)
 		}
-	} else if sessionID := c.Query("session_id"); sessionID != "" {
+	} else if sessionID := cartTokenSession(c); sessionID != "" {
+		// Invitado: solo ve los pedidos de la sesion de su token
 		orders, total, err = services.GetOrdersBySession(sessionID, page, limit)
 	} else {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id or session_id required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "login or cart token required"})
 		return
 	}
 
@@ -183,25 +229,35 @@ func GetOrders(c *gin.Context) {
 	}
 
 	var formattedOrders []gin.H
-	for _, order := range orders {
+	for i := range orders {
+		order := &orders[i]
+		discounts := orderDiscountsByItem(order)
 		var items []gin.H
 		for _, item := range order.OrderItems {
 			items = append(items, gin.H{
 				"product_id":   item.ProductID,
+				"variant_id":   item.VariantID,
 				"product_name": item.Product.Name,
 				"quantity":     item.Quantity,
 				"size":         item.Size,
+				"color":        item.Color,
+				"sku":          item.SKU,
 				"unit_price":   item.UnitPrice,
+				"discounts":    discountsOrEmpty(discounts[item.ID]),
 			})
 		}
 
 		formattedOrders = append(formattedOrders, gin.H{
-			"id":             order.ID,
-			"total_amount":   order.TotalAmount,
-			"status":         order.Status,
-			"payment_status": order.PaymentStatus,
-			"items":          items,
-			"created_at":     order.CreatedAt,
+			"id":              order.ID,
+			"subtotal_amount": order.SubtotalAmount,
+			"discount_amount": order.DiscountAmount,
+			"total_amount":    order.TotalAmount,
+			"currency":        order.Currency,
+			"discounts":       formatOrderDiscounts(order),
+			"status":          order.Status,
+			"payment_status":  order.PaymentStatus,
+			"items":           items,
+			"created_at":      order.CreatedAt,
 		})
 	}
 
@@ -274,23 +330,30 @@ func GetConfirmation(c *gin.Context) {
 		return
 	}
 
+	discounts := orderDiscountsByItem(order)
 	var formattedItems []gin.H
 	for _, item := range order.OrderItems {
 		formattedItems = append(formattedItems, gin.H{
 			"product_name": item.Product.Name,
 			"quantity":     item.Quantity,
 			"size":         item.Size,
+			"color":        item.Color,
 			"unit_price":   item.UnitPrice,
+			"discounts":    discountsOrEmpty(discounts[item.ID]),
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"order_id":       order.ID,
-		"order_date":     order.CreatedAt,
-		"total_amount":   order.TotalAmount,
-		"items":          formattedItems,
-		"status":         order.Status,
-		"payment_status": order.PaymentStatus,
-		"message":        "¡Gracias por tu compra!",
+		"order_id":        order.ID,
+		"order_date":      order.CreatedAt,
+		"subtotal_amount": order.SubtotalAmount,
+		"discount_amount": order.DiscountAmount,
+		"total_amount":    order.TotalAmount,
+		"currency":        order.Currency,
+		"discounts":       formatOrderDiscounts(order),
+		"items":           formattedItems,
+		"status":          order.Status,
+		"payment_status":  order.PaymentStatus,
+		"message":         "¡Gracias por tu compra!",
 	})
 }
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa programar un precio
+type PriceScheduleRequest struct {
+	Price          float64    `json:"price" binding:"required,gt=0"`
+	CompareAtPrice *float64   `json:"compare_at_price"`
+	StartsAt       time.Time  `json:"starts_at" binding:"required"`
+	EndsAt         *time.Time `json:"ends_at"`
+}
+
+func formatPriceHistory(entry *models.PriceHistory) gin.H {
+	return gin.H{
+		"price":            entry.Price,
+		"compare_at_price": entry.CompareAtPrice,
+		"source":           entry.Source,
+		"schedule_id":      entry.ScheduleID,
+		"effective_at":     entry.EffectiveAt,
+	}
+}
+
+func formatPriceSchedule(schedule *models.PriceSchedule) gin.H {
+	return gin.H{
+		"id":               schedule.ID,
+		"product_id":       schedule.ProductID,
+		"price":            schedule.Price,
+		"compare_at_price": schedule.CompareAtPrice,
+		"starts_at":        schedule.StartsAt,
+		"ends_at":          schedule.EndsAt,
+		"status":           schedule.Status,
+		"original_price":   schedule.OriginalPrice,
+		"applied_at":       schedule.AppliedAt,
+		"ended_at":         schedule.EndedAt,
+		"created_at":       schedule.CreatedAt,
+	}
+}
+
+// Historial de precios del producto; con ?at= (RFC3339) dice cuanto costaba en esa fecha
+func AdminGetPriceHistory(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	history, err := services.GetPriceHistory(staffAccess(c), productID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range history {
+		formatted = append(formatted, formatPriceHistory(&history[i]))
+	}
+	response := gin.H{"product_id": productID, "history": formatted}
+
+	if raw := c.Query("at"); raw != "" {
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC3339 date"})
+			return
+		}
+		entry, err := services.PriceAt(productID, at)
+		if err != nil {
+			adminError(c, err)
+			return
+		}
+		priceAt := formatPriceHistory(entry)
+		priceAt["at"] = at
+		response["price_at"] = priceAt
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Listar precios programados del producto
+func AdminListPriceSchedules(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	schedules, err := services.ListPriceSchedules(staffAccess(c), productID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range schedules {
+		formatted = append(formatted, formatPriceSchedule(&schedules[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": formatted})
+}
+
+// Programar un precio (rebaja con fecha de inicio y fin)
+func AdminCreatePriceSchedule(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req PriceScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := services.CreatePriceSchedule(staffAccess(c), productID, services.PriceScheduleInput{
+		Price:          req.Price,
+		CompareAtPrice: req.CompareAtPrice,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+	})
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatPriceSchedule(schedule))
+}
+
+// Cancelar precio programado (si esta corriendo, vuelve el precio regular)
+func AdminCancelPriceSchedule(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+	scheduleID, err := uuid.Parse(c.Param("schedule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return
+	}
+
+	schedule, err := services.CancelPriceSchedule(staffAccess(c), productID, scheduleID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatPriceSchedule(schedule))
+}
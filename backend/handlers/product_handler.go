@@ -1,20 +1,84 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/config"
 	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
 )
 
+// formatProduct arma la respuesta publica de un producto (listados y detalle)
+// en el idioma pedido y con los precios estimados en la moneda pedida
+func formatProduct(product *models.Product, view catalogView) gin.H {
+	lang := view.Lang
+	sizes, _ := product.GetSizes()
+	response := gin.H{
+		"id":               product.ID,
+		"store_id":         product.StoreID,
+		"store_name":       product.Store.Name,
+		"store_slug":       product.Store.Slug,
+		"name":             localized(lang, product.Name, product.NameEN),
+		"slug":             product.Slug,
+		"description":      localized(lang, product.Description, product.DescriptionEN),
+		"category":         localized(lang, product.Category, product.CategoryEN),
+		"lang":             lang,
+		"category_id":      product.CategoryID,
+		"price":            product.Price,
+		"compare_at_price": product.CompareAtPrice,
+		"available_units":  product.AvailableUnits,
+		"rating_average":   product.RatingAverage,
+		"review_count":     product.ReviewCount,
+		"image_url":        imageURL(product.ImagePath),
+		"images":           formatLocalizedImages(product.Images, lang),
+		"sizes":            sizes,
+		"published_at":     product.PublishedAt,
+	}
+	addCurrency(response, view.Currency)
+	addMoney(response, view.Currency, "price", &product.Price)
+	addMoney(response, view.Currency, "compare_at_price", product.CompareAtPrice)
+	return response
+}
+
+// formatImages arma la galeria en orden
+func formatImages(images []models.ProductImage) []gin.H {
+	formatted := []gin.H{}
+	for _, img := range images {
+		formatted = append(formatted, gin.H{
+			"id":         img.ID,
+			"image_url":  imageURL(img.Path),
+			"position":   img.Position,
+			"alt_es":     img.AltES,
+			"alt_en":     img.AltEN,
+			"is_primary": img.IsPrimary,
+		})
+	}
+	return formatted
+}
+
+// formatLocalizedImages es formatImages mas "alt" ya resuelto en el idioma
+func formatLocalizedImages(images []models.ProductImage, lang string) []gin.H {
+	formatted := formatImages(images)
+	for i, img := range images {
+		formatted[i]["alt"] = localized(lang, img.AltES, img.AltEN)
+	}
+	return formatted
+}
+
 // Trae productos con filtros opcionales, sin tanto show
 func GetAllProducts(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
 	store := c.Query("store")
 	category := c.Query("category")
 	minPrice := 0.0
@@ -52,22 +116,14 @@ func GetAllProducts(c *gin.Context) {
 		return
 	}
 
+	if etag, lastModified := catalogValidators(products, total, view.key()); notModified(c, etag, lastModified) {
+		return
+	}
+
 	// Formateamos la respuesta pa que llegue chevere
 	var formattedProducts []gin.H
-	for _, product := range products {
-		sizes, _ := product.GetSizes()
-		formattedProducts = append(formattedProducts, gin.H{
-			"id":              product.ID,
-			"store_id":        product.StoreID,
-			"store_name":      product.Store.Name,
-			"name":            product.Name,
-			"description":     product.Description,
-			"category":        product.Category,
-			"price":           product.Price,
-			"available_units": product.AvailableUnits,
-			"image_url":       imageURL(product.ImagePath),
-			"sizes":           sizes,
-		})
+	for i := range products {
+		formattedProducts = append(formattedProducts, formatProduct(&products[i], view))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -80,6 +136,10 @@ func GetAllProducts(c *gin.Context) {
 
 // GetProductByID retrieves a product by ID
 func GetProductByID(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
 	productID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
@@ -92,24 +152,129 @@ func GetProductByID(c *gin.Context) {
 		return
 	}
 
-	sizes, _ := product.GetSizes()
-	c.JSON(http.StatusOK, gin.H{
-		"id":              product.ID,
-		"store_id":        product.StoreID,
-		"store_name":      product.Store.Name,
-		"name":            product.Name,
-		"description":     product.Description,
-		"category":        product.Category,
-		"price":           product.Price,
-		"available_units": product.AvailableUnits,
-		"image_url":       imageURL(product.ImagePath),
-		"sizes":           sizes,
-		"created_at":      product.CreatedAt,
-	})
+	respondProductDetail(c, product, view)
+}
+
+// GetRelatedProducts devuelve "comprados juntos" (o parecidos si el producto es nuevo)
+func GetRelatedProducts(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	limit := 8
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 20 {
+			limit = parsed
+		}
+	}
+
+	products, err := services.GetRelatedProducts(productID, limit)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range products {
+		formatted = append(formatted, formatProduct(&products[i], view))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"products": formatted})
+}
+
+// GetProductBySlug busca por tienda y slug; los slugs viejos responden 301 con el nuevo
+func GetProductBySlug(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
+	store := c.Param("store")
+	product, currentSlug, err := services.GetProductBySlug(store, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if currentSlug != "" {
+		location := "/api/products/slug/" + url.PathEscape(store) + "/" + url.PathEscape(currentSlug)
+		c.Header("Location", location)
+		c.JSON(http.StatusMovedPermanently, gin.H{
+			"redirect":   true,
+			"product_id": product.ID,
+			"slug":       currentSlug,
+			"location":   location,
+		})
+		return
+	}
+
+	respondProductDetail(c, product, view)
+}
+
+// respondProductDetail responde el detalle con su guia de tallas; la guia entra al ETag
+// porque se edita aparte del producto
+func respondProductDetail(c *gin.Context, product *models.Product, view catalogView) {
+	chart, err := services.ProductSizeChart(product)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	variant := view.key()
+	if chart != nil {
+		variant += fmt.Sprintf("|chart=%s@%d", chart.ID, chart.UpdatedAt.UnixNano())
+	}
+	if etag, lastModified := catalogValidators([]models.Product{*product}, 1, variant); notModified(c, etag, lastModified) {
+		return
+	}
+
+	response := formatProductDetail(product, view)
+	response["size_chart"] = formatSizeChart(chart)
+	c.JSON(http.StatusOK, response)
+}
+
+// formatProductDetail es formatProduct mas variantes, pa la pagina de producto
+func formatProductDetail(product *models.Product, view catalogView) gin.H {
+	var variants []gin.H
+	for _, variant := range product.Variants {
+		price := variant.EffectivePrice(product.Price)
+		formatted := gin.H{
+			"id":       variant.ID,
+			"size":     variant.Size,
+			"color":    variant.Color,
+			"sku":      variant.SKU,
+			"barcode":  variant.Barcode,
+			"stock":    variant.Stock,
+			"price":    price,
+			"in_stock": variant.Stock > 0,
+		}
+		addMoney(formatted, view.Currency, "price", &price)
+		variants = append(variants, formatted)
+	}
+
+	response := formatProduct(product, view)
+	response["variants"] = variants
+	response["status"] = product.Status
+	response["archived"] = product.Status == models.ProductArchived
+	response["created_at"] = product.CreatedAt
+	return response
 }
 
 // GetProductsByStore retrieves products from a specific store
 func GetProductsByStore(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
 	storeID, err := uuid.Parse(c.Param("store_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
@@ -137,21 +302,13 @@ func GetProductsByStore(c *gin.Context) {
 		return
 	}
 
+	if etag, lastModified := catalogValidators(products, total, view.key()); notModified(c, etag, lastModified) {
+		return
+	}
+
 	var formattedProducts []gin.H
-	for _, product := range products {
-		sizes, _ := product.GetSizes()
-		formattedProducts = append(formattedProducts, gin.H{
-			"id":              product.ID,
-			"store_id":        product.StoreID,
-			"store_name":      product.Store.Name,
-			"name":            product.Name,
-			"description":     product.Description,
-			"category":        product.Category,
-			"price":           product.Price,
-			"available_units": product.AvailableUnits,
-			"image_url":       imageURL(product.ImagePath),
-			"sizes":           sizes,
-		})
+	for i := range products {
+		formattedProducts = append(formattedProducts, formatProduct(&products[i], view))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -164,6 +321,10 @@ func GetProductsByStore(c *gin.Context) {
 
 // GetProductsByCategory retrieves products by category
 func GetProductsByCategory(c *gin.Context) {
+	view, ok := catalogViewFor(c)
+	if !ok {
+		return
+	}
 	category := c.Param("category")
 	page := 1
 	limit := 20
@@ -186,21 +347,13 @@ func GetProductsByCategory(c *gin.Context) {
 		return
 	}
 
+	if etag, lastModified := catalogValidators(products, total, view.key()); notModified(c, etag, lastModified) {
+		return
+	}
+
 	var formattedProducts []gin.H
-	for _, product := range products {
-		sizes, _ := product.GetSizes()
-		formattedProducts = append(formattedProducts, gin.H{
-			"id":              product.ID,
-			"store_id":        product.StoreID,
-			"store_name":      product.Store.Name,
-			"name":            product.Name,
-			"description":     product.Description,
-			"category":        product.Category,
-			"price":           product.Price,
-			"available_units": product.AvailableUnits,
-			"image_url":       imageURL(product.ImagePath),
-			"sizes":           sizes,
-		})
+	for i := range products {
+		formattedProducts = append(formattedProducts, formatProduct(&products[i], view))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -211,47 +364,96 @@ func GetProductsByCategory(c *gin.Context) {
 	})
 }
 
-// ServeImage serves a product image
+// ServeImage sirve una imagen desde UploadDir, con ?w=&h=&fmt= pa variantes
 func ServeImage(c *gin.Context) {
-	filename := c.Param("filename")
-	// Remove leading slash if present (Gin's *param includes it)
-	if len(filename) > 0 && filename[0] == '/' {
-		filename = filename[1:]
+	// Gin mete el slash inicial en *filename
+	filename := strings.TrimPrefix(c.Param("filename"), "/")
+
+	req := services.ImageRequest{Name: filename, Format: c.Query("fmt")}
+	for param, dest := range map[string]*int{"w": &req.Width, "h": &req.Height} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image size"})
+				return
+			}
+			*dest = parsed
+		}
 	}
-	cfg := config.Get()
 
-	// Prevent directory traversal
-	if filename == ".." || filename == "." {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filename"})
+	img, err := services.OpenImage(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrImageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		case errors.Is(err, services.ErrInvalidImageRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("ServeImage %s: %v", filename, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load image"})
+		}
 		return
 	}
+	defer img.Content.Close()
+
+	// Cache largo; las subidas van por hash asi que nunca cambian
+	maxAge := int(config.Get().ImageMaxAge.Seconds())
+	cacheControl := fmt.Sprintf("public, max-age=%d", maxAge)
+	if img.Immutable {
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", img.ETag)
+	if img.ContentType != "" {
+		c.Header("Content-Type", img.ContentType)
+	}
+
+	// ServeContent se encarga de Range, If-None-Match y If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, img.Name, img.ModTime, img.Content)
+}
 
-	// Log raw request
-	log.Printf("DEBUG: ServeImage requested filename: '%s'", filename)
+// Peti pa "avisame cuando vuelva"; email puede faltar si esta logueado
+type StockSubscriptionRequest struct {
+	Email string `json:"email"`
+	Size  string `json:"size"`
+}
 
-	// Construct full path
-	// Note: filename here is already "cleaned" by the router/URL construction if it came from our API
-	// But let's see what we actually get.
-	fullPath := filepath.Join(cfg.UploadDir, filename)
+// Suscribirse al aviso de reposicion de un producto agotado (o una talla)
+func SubscribeBackInStock(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
 
-	// Get absolute path for debugging
-	absPath, _ := filepath.Abs(fullPath)
-	log.Printf("DEBUG: ServeImage looking for file at: '%s' (Absolute: '%s')", fullPath, absPath)
+	var req StockSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		log.Printf("ERROR: ServeImage File NOT FOUND at '%s'", fullPath)
-		// List directory contents to see what's actually there
-		dir := filepath.Dir(fullPath)
-		files, _ := os.ReadDir(dir)
-		log.Printf("DEBUG: Listing directory '%s':", dir)
-		for _, f := range files {
-			log.Printf("  - %s", f.Name())
+	var userID *uuid.UUID
+	if userIDStr, exists := c.Get("user_id"); exists {
+		if parsed, err := uuid.Parse(userIDStr.(string)); err == nil {
+			userID = &parsed
 		}
+	}
 
-		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+	subscription, err := services.SubscribeBackInStock(productID, userID, req.Email, req.Size)
+	if err != nil {
+		if errors.Is(err, services.ErrProductInStock) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		adminError(c, err)
 		return
 	}
 
-	c.File(fullPath)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         subscription.ID,
+		"product_id": subscription.ProductID,
+		"size":       subscription.Size,
+		"email":      subscription.Email,
+		"created_at": subscription.CreatedAt,
+	})
 }
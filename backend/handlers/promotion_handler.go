@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa crear o actualizar promo o cupon
+type PromotionRequest struct {
+	StoreID          uuid.UUID  `json:"store_id"`
+	Name             string     `json:"name" binding:"required"`
+	Description      string     `json:"description"`
+	Code             string     `json:"code"` // vacio = promo automatica
+	DiscountType     string     `json:"discount_type" binding:"required"`
+	Value            float64    `json:"value" binding:"required,gt=0"`
+	Scope            string     `json:"scope"`
+	CategoryID       *uuid.UUID `json:"category_id"`
+	ProductID        *uuid.UUID `json:"product_id"`
+	MinSpend         float64    `json:"min_spend"`
+	UsageLimit       int        `json:"usage_limit"`
+	PerCustomerLimit int        `json:"per_customer_limit"`
+	Stackable        *bool      `json:"stackable"` // por defecto true
+	Priority         int        `json:"priority"`
+	StartsAt         *time.Time `json:"starts_at"`
+	EndsAt           *time.Time `json:"ends_at"`
+	Active           *bool      `json:"active"` // por defecto true
+}
+
+func (req PromotionRequest) input() services.PromotionInput {
+	return services.PromotionInput{
+		StoreID:          req.StoreID,
+		Name:             req.Name,
+		Description:      req.Description,
+		Code:             req.Code,
+		DiscountType:     req.DiscountType,
+		Value:            req.Value,
+		Scope:            req.Scope,
+		CategoryID:       req.CategoryID,
+		ProductID:        req.ProductID,
+		MinSpend:         req.MinSpend,
+		UsageLimit:       req.UsageLimit,
+		PerCustomerLimit: req.PerCustomerLimit,
+		Stackable:        req.Stackable == nil || *req.Stackable,
+		Priority:         req.Priority,
+		StartsAt:         req.StartsAt,
+		EndsAt:           req.EndsAt,
+		Active:           req.Active == nil || *req.Active,
+	}
+}
+
+func formatPromotion(promotion *models.Promotion) gin.H {
+	return gin.H{
+		"id":            promotion.ID,
+		"store_id":      promotion.StoreID,
+		"store_name":    promotion.Store.Name,
+		"name":          promotion.Name,
+		"description":   promotion.Description,
+		"discount_type": promotion.DiscountType,
+		"value":         promotion.Value,
+		"scope":         promotion.Scope,
+		"category_id":   promotion.CategoryID,
+		"product_id":    promotion.ProductID,
+		"min_spend":     promotion.MinSpend,
+		"stackable":     promotion.Stackable,
+		"starts_at":     promotion.StartsAt,
+		"ends_at":       promotion.EndsAt,
+	}
+}
+
+func formatAdminPromotion(promotion *models.Promotion) gin.H {
+	formatted := formatPromotion(promotion)
+	formatted["code"] = promotion.Code
+	formatted["usage_limit"] = promotion.UsageLimit
+	formatted["per_customer_limit"] = promotion.PerCustomerLimit
+	formatted["usage_count"] = promotion.UsageCount
+	formatted["priority"] = promotion.Priority
+	formatted["active"] = promotion.Active
+	formatted["running"] = promotion.ActiveAt(time.Now())
+	formatted["created_at"] = promotion.CreatedAt
+	formatted["updated_at"] = promotion.UpdatedAt
+	return formatted
+}
+
+// Promos automaticas vigentes (?store_id= opcional), pa la pagina de promociones
+func GetPromotions(c *gin.Context) {
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	promotions, err := services.ListActivePromotions(storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range promotions {
+		formatted = append(formatted, formatPromotion(&promotions[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotions": formatted})
+}
+
+// Listar promos y cupones del admin (?store_id= opcional)
+func AdminListPromotions(c *gin.Context) {
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	promotions, err := services.ListAdminPromotions(staffAccess(c), storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range promotions {
+		formatted = append(formatted, formatAdminPromotion(&promotions[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"promotions": formatted})
+}
+
+// Ver una promo
+func AdminGetPromotion(c *gin.Context) {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion id"})
+		return
+	}
+
+	promotion, err := services.GetAdminPromotion(staffAccess(c), promotionID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminPromotion(promotion))
+}
+
+// Crear promo o cupon
+func AdminCreatePromotion(c *gin.Context) {
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := services.CreatePromotion(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatAdminPromotion(promotion))
+}
+
+// Actualizar promo (pa apagarla: active=false)
+func AdminUpdatePromotion(c *gin.Context) {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion id"})
+		return
+	}
+
+	var req PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := services.UpdatePromotion(staffAccess(c), promotionID, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminPromotion(promotion))
+}
+
+// Borrar promo que nunca se uso
+func AdminDeletePromotion(c *gin.Context) {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion id"})
+		return
+	}
+
+	if err := services.DeletePromotion(staffAccess(c), promotionID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "promotion deleted"})
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa aprobar o rechazar una resena
+type ModerateReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// reviewerName muestra solo nombre e inicial del apellido
+func reviewerName(user models.User) string {
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + string([]rune(user.LastName)[:1]) + "."
+	}
+	return name
+}
+
+func formatReview(review *models.Review) gin.H {
+	photos := []gin.H{}
+	for _, photo := range review.Photos {
+		photos = append(photos, gin.H{
+			"id":        photo.ID,
+			"image_url": imageURL(photo.Path),
+		})
+	}
+
+	return gin.H{
+		"id":                review.ID,
+		"product_id":        review.ProductID,
+		"author":            reviewerName(review.User),
+		"rating":            review.Rating,
+		"title":             review.Title,
+		"body":              review.Body,
+		"fit":               review.Fit,
+		"size":              review.Size,
+		"verified_purchase": review.VerifiedPurchase(),
+		"photos":            photos,
+		"status":            review.Status,
+		"created_at":        review.CreatedAt,
+	}
+}
+
+// Resenas aprobadas de un producto
+func GetProductReviews(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	reviews, total, err := services.GetProductReviews(productID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range reviews {
+		formatted = append(formatted, formatReview(&reviews[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"reviews": formatted,
+	})
+}
+
+// Crear o reemplazar la resena del user (multipart: rating, title, body, fit, size, photos)
+func CreateReview(c *gin.Context) {
+	userIDStr, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	userID, err := uuid.Parse(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	// Hasta 4 fotos; cortamos el body de una pa no leer de mas
+	maxSize := config.Get().MaxUploadSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 4*maxSize+64*1024)
+
+	rating, _ := strconv.Atoi(c.PostForm("rating"))
+	in := services.ReviewInput{
+		Rating: rating,
+		Title:  c.PostForm("title"),
+		Body:   c.PostForm("body"),
+		Fit:    c.PostForm("fit"),
+		Size:   c.PostForm("size"),
+	}
+
+	var headers []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil {
+		headers = form.File["photos"]
+	} else {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			adminError(c, services.ErrImageTooLarge)
+			return
+		}
+	}
+
+	var photos []io.Reader
+	for _, header := range headers {
+		if header.Size > maxSize {
+			adminError(c, services.ErrImageTooLarge)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read photo"})
+			return
+		}
+		defer file.Close()
+		photos = append(photos, file)
+	}
+
+	review, err := services.CreateReview(userID, productID, in, photos)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	response := formatReview(review)
+	response["message"] = "review submitted for moderation"
+	c.JSON(http.StatusCreated, response)
+}
+
+// Cola de moderacion (?status=pending|approved|rejected&store_id=)
+func AdminListReviews(c *gin.Context) {
+	page := 1
+	limit := 20
+	if p := c.Query("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			page = parsed
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	reviews, total, err := services.ListReviewsForModeration(staffAccess(c), c.Query("status"), storeID, page, limit)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range reviews {
+		review := formatReview(&reviews[i])
+		review["product_name"] = reviews[i].Product.Name
+		review["user_id"] = reviews[i].UserID
+		review["moderation_note"] = reviews[i].ModerationNote
+		formatted = append(formatted, review)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+		"reviews": formatted,
+	})
+}
+
+// Aprobar resena
+func AdminApproveReview(c *gin.Context) {
+	moderateReview(c, models.ReviewApproved)
+}
+
+// Rechazar resena
+func AdminRejectReview(c *gin.Context) {
+	moderateReview(c, models.ReviewRejected)
+}
+
+func moderateReview(c *gin.Context, status string) {
+	reviewID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid review id"})
+		return
+	}
+
+	// La nota es opcional, asi que el body puede venir vacio
+	var req ModerateReviewRequest
+	_ = c.ShouldBindJSON(&req)
+
+	review, err := services.ModerateReview(staffAccess(c), reviewID, status, req.Note)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatReview(review))
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// Peti pa crear o actualizar guia de tallas; rows de la talla mas chica a la mas grande
+type SizeChartRequest struct {
+	StoreID    uuid.UUID                    `json:"store_id"`
+	CategoryID *uuid.UUID                   `json:"category_id"` // nil = toda la tienda
+	Name       string                       `json:"name" binding:"required"`
+	Notes      string                       `json:"notes"`
+	Rows       []services.SizeChartRowInput `json:"rows" binding:"required,min=1"`
+}
+
+func (req SizeChartRequest) input() services.SizeChartInput {
+	return services.SizeChartInput{
+		StoreID:    req.StoreID,
+		CategoryID: req.CategoryID,
+		Name:       req.Name,
+		Notes:      req.Notes,
+		Rows:       req.Rows,
+	}
+}
+
+// Peti de recomendacion: medidas del cliente en cm, ej {"bust": 88, "waist": 70}
+type SizeRecommendationRequest struct {
+	Measurements map[string]float64 `json:"measurements" binding:"required"`
+}
+
+// formatSizeChart arma la guia pa el publico (va dentro del detalle del producto)
+func formatSizeChart(chart *models.SizeChart) gin.H {
+	if chart == nil {
+		return nil
+	}
+
+	sizes := []gin.H{}
+	for _, row := range chart.Rows {
+		measurements, _ := row.GetMeasurements()
+		sizes = append(sizes, gin.H{
+			"size":         row.Size,
+			"measurements": measurements,
+		})
+	}
+
+	return gin.H{
+		"id":          chart.ID,
+		"category_id": chart.CategoryID,
+		"name":        chart.Name,
+		"notes":       chart.Notes,
+		"unit":        "cm",
+		"sizes":       sizes,
+	}
+}
+
+func formatAdminSizeChart(chart *models.SizeChart) gin.H {
+	formatted := formatSizeChart(chart)
+	formatted["store_id"] = chart.StoreID
+	formatted["store_name"] = chart.Store.Name
+	formatted["category_name"] = nil
+	if chart.Category != nil {
+		formatted["category_name"] = chart.Category.Name
+	}
+	formatted["created_at"] = chart.CreatedAt
+	formatted["updated_at"] = chart.UpdatedAt
+	return formatted
+}
+
+// Sugerir talla de un producto segun las medidas del cliente
+func RecommendProductSize(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	var req SizeRecommendationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recommendation, err := services.RecommendSize(productID, req.Measurements)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	scores := []gin.H{}
+	for _, score := range recommendation.Scores {
+		scores = append(scores, gin.H{
+			"size":     score.Size,
+			"distance": score.Distance,
+			"matched":  score.Matched,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"size":       recommendation.Size,
+		"chart_size": recommendation.ChartSize,
+		"adjustment": recommendation.Adjustment,
+		"fit_feedback": gin.H{
+			"small": recommendation.Fit.Small,
+			"true":  recommendation.Fit.True,
+			"large": recommendation.Fit.Large,
+			"total": recommendation.Fit.Total,
+		},
+		"scores":     scores,
+		"size_chart": formatSizeChart(recommendation.Chart),
+	})
+}
+
+// Listar guias de tallas; ?store_id= pa una sola tienda
+func AdminListSizeCharts(c *gin.Context) {
+	var storeID *uuid.UUID
+	if raw := c.Query("store_id"); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+			return
+		}
+		storeID = &parsed
+	}
+
+	charts, err := services.ListAdminSizeCharts(staffAccess(c), storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range charts {
+		formatted = append(formatted, formatAdminSizeChart(&charts[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"size_charts": formatted})
+}
+
+// Crear guia de tallas
+func AdminCreateSizeChart(c *gin.Context) {
+	var req SizeChartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chart, err := services.CreateSizeChart(staffAccess(c), req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, formatAdminSizeChart(chart))
+}
+
+// Actualizar guia de tallas (reemplaza todas las tallas)
+func AdminUpdateSizeChart(c *gin.Context) {
+	chartID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size chart id"})
+		return
+	}
+
+	var req SizeChartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chart, err := services.UpdateSizeChart(staffAccess(c), chartID, req.input())
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminSizeChart(chart))
+}
+
+// Borrar guia de tallas
+func AdminDeleteSizeChart(c *gin.Context) {
+	chartID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size chart id"})
+		return
+	}
+
+	if err := services.DeleteSizeChart(staffAccess(c), chartID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "size chart deleted"})
+}
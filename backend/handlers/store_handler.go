@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// formatStore arma la ficha publica de la tienda: branding, contacto y politicas
+func formatStore(store *models.Store) gin.H {
+	banners, _ := store.GetBanners()
+	bannerURLs := []string{}
+	for _, banner := range banners {
+		bannerURLs = append(bannerURLs, imageURL(banner))
+	}
+
+	socialLinks, _ := store.GetSocialLinks()
+
+	formatted := gin.H{
+		"id":          store.ID,
+		"slug":        store.Slug,
+		"name":        store.Name,
+		"description": store.Description,
+		"logo_url":    nil,
+		"banners":     bannerURLs,
+		"contact": gin.H{
+			"email":    store.ContactEmail,
+			"phone":    store.ContactPhone,
+			"whatsapp": store.WhatsApp,
+		},
+		"social_links":         socialLinks,
+		"shipping_origin_city": store.ShippingOriginCity,
+		"policies": gin.H{
+			"shipping": store.ShippingPolicy,
+			"returns":  store.ReturnPolicy,
+		},
+	}
+	if store.LogoPath != "" {
+		formatted["logo_url"] = imageURL(store.LogoPath)
+	}
+	return formatted
+}
+
+// Tiendas visibles en el orden de la vitrina
+func GetStores(c *gin.Context) {
+	stores, err := services.ListStores()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	formatted := []gin.H{}
+	for i := range stores {
+		formatted = append(formatted, formatStore(&stores[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stores": formatted})
+}
+
+// Ficha de una tienda por slug
+func GetStore(c *gin.Context) {
+	store, err := services.GetStoreBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, formatStore(store))
+}
+
+// Ver tienda del admin (incluye archivadas)
+func AdminGetStore(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+		return
+	}
+
+	store, err := services.GetAdminStore(staffAccess(c), storeID)
+	if err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, formatAdminStore(store))
+}
+
+// Borrar tienda vacia (solo admin); con productos toca archivarla
+func AdminDeleteStore(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store id"})
+		return
+	}
+
+	if err := services.DeleteStore(staffAccess(c), storeID); err != nil {
+		adminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "store deleted"})
+}
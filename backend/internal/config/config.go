@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -30,6 +32,10 @@ type Config struct {
 	JWTExpiration          time.Duration
 	RefreshTokenExpiration time.Duration
 
+	// Carrito de invitado: token firmado que emite el server (cookie o header)
+	CartTokenSecret string // vacio = se usa JWT_SECRET
+	CartTokenTTL    time.Duration
+
 	// Email, para mandar correitos
 	SMTPHost string
 	SMTPPort int
@@ -40,9 +46,45 @@ type Config struct {
 	// Frontend, URL del cliente
 	FrontendURL string
 
+	// URL publica del API, pa armar links absolutos de imagenes en feeds
+	PublicAPIURL string
+
 	// File Upload, rutas y tamanos
 	UploadDir     string
 	MaxUploadSize int64
+
+	// Imagenes, cache de variantes redimensionadas y cuanto las guarda el navegador
+	ImageCacheDir string
+	ImageMaxAge   time.Duration
+
+	// Cache HTTP del catalogo
+	CatalogCacheMaxAge time.Duration
+	CatalogCacheSWR    time.Duration
+
+	// Watcher del catalogo: recarga assets/products/*.json sin reiniciar
+	CatalogWatch         bool
+	CatalogWatchInterval time.Duration
+	CatalogWatchDebounce time.Duration
+
+	// Recomendaciones: cada cuanto se recalculan las afinidades (0 = apagado)
+	RecommendationsInterval time.Duration
+
+	// Coleccion automatica de lo nuevo: cuantos dias cuenta un producto como nuevo
+	NewArrivalsDays int
+
+	// Precios programados: cada cuanto se revisan (0 = apagado)
+	PriceScheduleInterval time.Duration
+
+	// Productos programados: cada cuanto se pasan a published (0 = apagado)
+	ProductPublishInterval time.Duration
+
+	// Alertas de stock bajo: umbral por defecto y cada cuanto se revisan (0 = apagado)
+	LowStockThreshold int
+	LowStockInterval  time.Duration
+
+	// Monedas de referencia: cuantos COP vale 1 unidad ("USD=4000,EUR=4350").
+	// El admin las puede pisar desde el API; los pedidos se cobran siempre en COP.
+	ExchangeRates map[string]float64
 }
 
 var cfg *Config
@@ -73,6 +115,10 @@ func Load() (*Config, error) {
 		JWTExpiration:          parseDuration(getEnv("JWT_EXPIRATION", "24h")),
 		RefreshTokenExpiration: parseDuration(getEnv("REFRESH_TOKEN_EXPIRATION", "7d")),
 
+		// Carrito de invitado
+		CartTokenSecret: getEnv("CART_TOKEN_SECRET", ""),
+		CartTokenTTL:    parseDuration(getEnv("CART_TOKEN_TTL", "720h")), // 30 dias
+
 		// Email
 		SMTPHost: getEnv("SMTP_HOST", "smtp.gmail.com"),
 		SMTPPort: getEnvInt("SMTP_PORT", 587),
@@ -83,9 +129,44 @@ func Load() (*Config, error) {
 		// Frontend
 		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
 
+		// API publica
+		PublicAPIURL: getEnv("PUBLIC_API_URL", "http://localhost:8080"),
+
 		// File Upload
 		UploadDir:     resolveUploadDir(getEnv("UPLOAD_DIR", "../assets/images")),
 		MaxUploadSize: getEnvInt64("MAX_UPLOAD_SIZE", 5242880), // 5MB
+
+		// Imagenes
+		ImageCacheDir: getEnv("IMAGE_CACHE_DIR", filepath.Join(os.TempDir(), "celestexmewave-image-cache")),
+		ImageMaxAge:   parseDuration(getEnv("IMAGE_MAX_AGE", "720h")), // 30 dias
+
+		// Cache HTTP
+		CatalogCacheMaxAge: parseDuration(getEnv("CATALOG_CACHE_MAX_AGE", "60s")),
+		CatalogCacheSWR:    parseDuration(getEnv("CATALOG_CACHE_SWR", "5m")),
+
+		// Watcher del catalogo
+		CatalogWatch:         getEnvBool("CATALOG_WATCH", false),
+		CatalogWatchInterval: parseDuration(getEnv("CATALOG_WATCH_INTERVAL", "2s")),
+		CatalogWatchDebounce: parseDuration(getEnv("CATALOG_WATCH_DEBOUNCE", "3s")),
+
+		// Recomendaciones
+		RecommendationsInterval: parseDuration(getEnv("RECOMMENDATIONS_INTERVAL", "1h")),
+
+		// Lo nuevo
+		NewArrivalsDays: getEnvInt("NEW_ARRIVALS_DAYS", 30),
+
+		// Precios programados
+		PriceScheduleInterval: parseDuration(getEnv("PRICE_SCHEDULE_INTERVAL", "1m")),
+
+		// Productos programados
+		ProductPublishInterval: parseDuration(getEnv("PRODUCT_PUBLISH_INTERVAL", "1m")),
+
+		// Stock bajo
+		LowStockThreshold: getEnvInt("LOW_STOCK_THRESHOLD", 5),
+		LowStockInterval:  parseDuration(getEnv("LOW_STOCK_INTERVAL", "15m")),
+
+		// Monedas
+		ExchangeRates: getEnvRates("EXCHANGE_RATES"),
 	}
 
 	cfg = config
@@ -149,6 +230,40 @@ func getEnvInt64(key string, defaultVal int64) int64 {
 	return defaultVal
 }
 
+func getEnvBool(key string, defaultVal bool) bool {
+	if val, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return val
+	}
+	return defaultVal
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, part := range strings.Split(getEnv(key, ""), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// getEnvRates lee pares CODIGO=tasa separados por coma; los que no parsean se ignoran
+func getEnvRates(key string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, pair := range getEnvList(key) {
+		code, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || rate <= 0 {
+			continue
+		}
+		rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	return rates
+}
+
 func parseDuration(s string) time.Duration {
 	duration, err := time.ParseDuration(s)
 	if err != nil {
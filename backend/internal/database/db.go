@@ -5,7 +5,9 @@ import (
 	"log"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/utils"
 	"github.com/leunameek/celestexmewave/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -60,16 +62,244 @@ func Initialize(cfg *config.Config) error {
 
 // Migrate corre todas las migras
 func Migrate() error {
-	return DB.AutoMigrate(
+	// Antes de AutoMigrate: los slugs tienen que existir pa poder crear el indice unico
+	if err := backfillProductSlugs(); err != nil {
+		return err
+	}
+	if err := backfillStoreSlugs(); err != nil {
+		return err
+	}
+	// Se mira antes de AutoMigrate pa llenar imported_stock y manual_override una sola vez, cuando nacen
+	migrator := DB.Migrator()
+	importedStockMissing := migrator.HasTable(&models.ProductVariant{}) &&
+		!migrator.HasColumn(&models.ProductVariant{}, "ImportedStock")
+	manualOverrideMissing := migrator.HasTable(&models.PriceSchedule{}) &&
+		!migrator.HasColumn(&models.PriceSchedule{}, "ManualOverride")
+
+	if err := DB.AutoMigrate(
 		&models.Store{},
+		&models.Category{},
 		&models.Product{},
+		&models.ProductSlug{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
+		&models.ProductUpload{},
 		&models.User{},
 		&models.PasswordReset{},
+		&models.StoreStaff{},
 		&models.Cart{},
 		&models.CartItem{},
 		&models.Order{},
 		&models.OrderItem{},
-	)
+		&models.Review{},
+		&models.ReviewPhoto{},
+		&models.ProductAffinity{},
+		&models.Collection{},
+		&models.CollectionProduct{},
+		&models.Promotion{},
+		&models.CartCoupon{},
+		&models.PromotionRedemption{},
+		&models.OrderDiscount{},
+		&models.PriceHistory{},
+		&models.PriceSchedule{},
+		&models.StockAlert{},
+		&models.StockSubscription{},
+		&models.RestockWatermark{},
+		&models.ExchangeRate{},
+		&models.SizeChart{},
+		&models.SizeChartRow{},
+	); err != nil {
+		return err
+	}
+
+	if err := backfillProductStatus(); err != nil {
+		return err
+	}
+	if err := backfillPublishedAt(); err != nil {
+		return err
+	}
+	if err := backfillOrderSubtotals(); err != nil {
+		return err
+	}
+	if importedStockMissing {
+		if err := backfillImportedStock(); err != nil {
+			return err
+		}
+	}
+	if manualOverrideMissing {
+		if err := backfillManualOverrides(); err != nil {
+			return err
+		}
+	}
+	return backfillPriceHistory()
+}
+
+// backfillManualOverrides: las rebajas que ya corrian se marcan como antes, si el
+// precio del producto ya no es el de la rebaja
+func backfillManualOverrides() error {
+	result := DB.Model(&models.PriceSchedule{}).
+		Where("status = ? AND price <> (SELECT price FROM products WHERE products.id = price_schedules.product_id)", models.PriceScheduleActive).
+		Update("manual_override", true)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill manual overrides: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Backfilled manual overrides for %d price schedules", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillImportedStock: pa los productos que vienen del catalogo asumimos que el
+// archivo ya estaba aplicado, asi el primer sync no resetea el stock vendido
+func backfillImportedStock() error {
+	result := DB.Model(&models.ProductVariant{}).
+		Where("product_id IN (SELECT id FROM products WHERE external_key IS NOT NULL)").
+		Update("imported_stock", gorm.Expr("stock"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill imported stock: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Backfilled imported stock for %d variants", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillProductStatus: la columna nace en published, los archivados de antes pasan a archived
+func backfillProductStatus() error {
+	result := DB.Model(&models.Product{}).
+		Where("archived_at IS NOT NULL AND status <> ?", models.ProductArchived).
+		Update("status", models.ProductArchived)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill product status: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Backfilled archived status for %d products", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillPublishedAt toma la fecha de creacion como publicacion pa los productos viejos
+// (los borradores no tienen fecha hasta que se publiquen)
+func backfillPublishedAt() error {
+	result := DB.Model(&models.Product{}).
+		Where("published_at IS NULL AND status <> ?", models.ProductDraft).
+		Update("published_at", gorm.Expr("created_at"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill published_at: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Backfilled published_at for %d products", result.RowsAffected)
+	}
+	return nil
+}
+
+// backfillProductSlugs agrega la columna slug y la llena pa los productos viejos
+func backfillProductSlugs() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.Product{}) || migrator.HasColumn(&models.Product{}, "Slug") {
+		return nil
+	}
+
+	if err := DB.Exec("ALTER TABLE products ADD COLUMN slug varchar(255)").Error; err != nil {
+		return fmt.Errorf("failed to add product slug column: %w", err)
+	}
+
+	var products []struct {
+		ID      uuid.UUID
+		StoreID uuid.UUID
+		Name    string
+	}
+	if err := DB.Table("products").Select("id, store_id, name").Order("created_at ASC").Scan(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products for slugs: %w", err)
+	}
+
+	// Mismo criterio que services: nombre en slug y si se repite en la tienda, -2, -3...
+	used := make(map[string]bool)
+	for _, p := range products {
+		base := utils.Slugify(p.Name)
+		if base == "" {
+			base = "producto"
+		}
+		slug := base
+		for n := 2; used[p.StoreID.String()+"/"+slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[p.StoreID.String()+"/"+slug] = true
+
+		if err := DB.Table("products").Where("id = ?", p.ID).Update("slug", slug).Error; err != nil {
+			return fmt.Errorf("failed to backfill product slug: %w", err)
+		}
+	}
+
+	log.Printf("✓ Backfilled slugs for %d products", len(products))
+	return nil
+}
+
+// backfillStoreSlugs: igual que los productos, las tiendas viejas no tenian slug
+func backfillStoreSlugs() error {
+	migrator := DB.Migrator()
+	if !migrator.HasTable(&models.Store{}) || migrator.HasColumn(&models.Store{}, "Slug") {
+		return nil
+	}
+
+	if err := DB.Exec("ALTER TABLE stores ADD COLUMN slug varchar(100)").Error; err != nil {
+		return fmt.Errorf("failed to add store slug column: %w", err)
+	}
+
+	var stores []struct {
+		ID   uuid.UUID
+		Name string
+	}
+	if err := DB.Table("stores").Select("id, name").Order("created_at ASC").Scan(&stores).Error; err != nil {
+		return fmt.Errorf("failed to fetch stores for slugs: %w", err)
+	}
+
+	used := make(map[string]bool)
+	for _, s := range stores {
+		base := utils.Slugify(s.Name)
+		if base == "" {
+			base = "tienda"
+		}
+		slug := base
+		for n := 2; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[slug] = true
+
+		if err := DB.Table("stores").Where("id = ?", s.ID).Update("slug", slug).Error; err != nil {
+			return fmt.Errorf("failed to backfill store slug: %w", err)
+		}
+	}
+
+	log.Printf("✓ Backfilled slugs for %d stores", len(stores))
+	return nil
+}
+
+// backfillOrderSubtotals: los pedidos de antes de las promos no tenian descuento
+func backfillOrderSubtotals() error {
+	if err := DB.Model(&models.Order{}).
+		Where("subtotal_amount = 0 AND discount_amount = 0 AND total_amount > 0").
+		Update("subtotal_amount", gorm.Expr("total_amount")).Error; err != nil {
+		return fmt.Errorf("failed to backfill order subtotals: %w", err)
+	}
+	return nil
+}
+
+// backfillPriceHistory deja el precio actual como primer registro de los productos sin historial
+func backfillPriceHistory() error {
+	result := DB.Exec(`
+		INSERT INTO price_histories (id, product_id, price, compare_at_price, source, effective_at, created_at)
+		SELECT gen_random_uuid(), products.id, products.price, products.compare_at_price, ?, products.created_at, NOW()
+		FROM products
+		WHERE NOT EXISTS (SELECT 1 FROM price_histories WHERE price_histories.product_id = products.id)`,
+		models.PriceSourceInitial)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill price history: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✓ Backfilled price history for %d products", result.RowsAffected)
+	}
+	return nil
 }
 
 // GetDB devuelve la instancia
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CachePolicy describe el Cache-Control de un grupo de rutas
+type CachePolicy struct {
+	Public               bool
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+	NoStore              bool
+}
+
+// String arma el header, ej: "public, max-age=60, stale-while-revalidate=300"
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	parts := []string{"private"}
+	if p.Public {
+		parts[0] = "public"
+	}
+	parts = append(parts, fmt.Sprintf("max-age=%d", int(p.MaxAge.Seconds())))
+	if p.StaleWhileRevalidate > 0 {
+		parts = append(parts, fmt.Sprintf("stale-while-revalidate=%d", int(p.StaleWhileRevalidate.Seconds())))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CacheControl pone la politica en los GET/HEAD; el handler la puede pisar si quiere
+func CacheControl(policy CachePolicy) gin.HandlerFunc {
+	value := policy.String()
+	return func(c *gin.Context) {
+		if c.Request.Method == "GET" || c.Request.Method == "HEAD" {
+			c.Header("Cache-Control", value)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/services"
+)
+
+// StaffMiddleware deja pasar solo a staff o admin; va despues de AuthMiddleware
+func StaffMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDStr, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		userID, err := uuid.Parse(userIDStr.(string))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user id"})
+			c.Abort()
+			return
+		}
+
+		// El rol lo leemos de la DB, asi un cambio de rol aplica de una
+		access, err := services.GetStaffAccess(userID)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "staff access required"})
+			c.Abort()
+			return
+		}
+
+		c.Set("staff_access", access)
+		c.Next()
+	}
+}
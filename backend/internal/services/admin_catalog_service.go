@@ -0,0 +1,566 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict sale cuando alguien mas edito el registro primero
+var ErrVersionConflict = errors.New("record was modified by someone else, reload and try again")
+
+// ProductInput son los campos editables de un producto desde el admin
+type ProductInput struct {
+	StoreID           uuid.UUID
+	Name              string
+	NameEN            string // vacio = sin traduccion
+	Description       string
+	DescriptionEN     string
+	Category          string // nombre o path ("Vestidos > Largos"); se usa si no viene CategoryID
+	CategoryEN        string // path en ingles con los mismos niveles; traduce la categoria
+	CategoryID        *uuid.UUID
+	Price             float64
+	CompareAtPrice    *float64 // "antes $X"; nil = sin precio tachado
+	ImagePath         string
+	Images            []ProductImageJSON // si viene, reemplaza la galeria completa
+	Variants          []ProductVariantJSON
+	PublishedAt       *time.Time // nil = ahora al crear, sin cambios al editar
+	Status            string     // solo al crear: draft, scheduled o published (default)
+	LowStockThreshold *int       // nil = el de la tienda
+}
+
+// images devuelve la galeria pedida; nil significa "no tocar la galeria"
+func (in ProductInput) images() []ProductImageJSON {
+	if len(in.Images) > 0 {
+		return in.Images
+	}
+	if in.ImagePath != "" {
+		return []ProductImageJSON{{Path: in.ImagePath, AltES: in.Name, Primary: true}}
+	}
+	return nil
+}
+
+// StoreInput son los campos editables de una tienda
+type StoreInput struct {
+	Name               string
+	Slug               string // vacio = sale del nombre
+	Description        string
+	LogoPath           string
+	Banners            []string // nil = no tocar
+	ContactEmail       string
+	ContactPhone       string
+	WhatsApp           string
+	SocialLinks        map[string]string // nil = no tocar
+	ShippingOriginCity string
+	ShippingPolicy     string
+	ReturnPolicy       string
+	SortOrder          int
+	LowStockThreshold  *int // nil = el de la config
+}
+
+// validateProductInput revisa campos antes de tocar la DB
+func validateProductInput(in *ProductInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.NameEN = strings.TrimSpace(in.NameEN)
+	in.Category = strings.TrimSpace(in.Category)
+	in.CategoryEN = strings.TrimSpace(in.CategoryEN)
+
+	if in.Name == "" || len(in.Name) > 255 {
+		return fmt.Errorf("name is required and must be at most 255 characters")
+	}
+	if len(in.NameEN) > 255 {
+		return fmt.Errorf("name_en must be at most 255 characters")
+	}
+	if len(in.CategoryEN) > 100 {
+		return fmt.Errorf("category_en must be at most 100 characters")
+	}
+	if in.CategoryEN != "" && in.Category != "" && len(splitCategoryPath(in.CategoryEN)) != len(splitCategoryPath(in.Category)) {
+		return fmt.Errorf("category_en must have the same levels as category")
+	}
+	if in.LowStockThreshold != nil && *in.LowStockThreshold < 0 {
+		return fmt.Errorf("low_stock_threshold must be zero or positive")
+	}
+	if len(in.Category) > 100 {
+		return fmt.Errorf("category must be at most 100 characters")
+	}
+	if in.Price <= 0 {
+		return fmt.Errorf("price must be greater than 0")
+	}
+	if err := validateCompareAt(in.Price, in.CompareAtPrice); err != nil {
+		return err
+	}
+	if len(in.ImagePath) > 255 {
+		return fmt.Errorf("image path must be at most 255 characters")
+	}
+	for _, img := range in.Images {
+		if strings.TrimSpace(img.Path) == "" || len(img.Path) > 255 {
+			return fmt.Errorf("image path is required and must be at most 255 characters")
+		}
+	}
+	if len(in.Variants) == 0 {
+		return fmt.Errorf("at least one variant is required")
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range in.Variants {
+		if len(v.Size) > 10 {
+			return fmt.Errorf("variant size %q must be at most 10 characters", v.Size)
+		}
+		if len(v.Color) > 50 {
+			return fmt.Errorf("variant color %q must be at most 50 characters", v.Color)
+		}
+		if v.Stock < 0 {
+			return fmt.Errorf("variant stock cannot be negative")
+		}
+		if v.Price != nil && *v.Price <= 0 {
+			return fmt.Errorf("variant price must be greater than 0")
+		}
+		key := v.Size + "|" + v.Color
+		if seen[key] {
+			return fmt.Errorf("duplicated variant %s %s", v.Size, v.Color)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// ListAdminProducts lista productos de las tiendas a las que el staff tiene acceso
+// status filtra por estado; vacio = todo menos archivados (salvo includeArchived)
+func ListAdminProducts(access *StaffAccess, storeID *uuid.UUID, status string, includeArchived bool, page, limit int) ([]models.Product, int64, error) {
+	var products []models.Product
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.Product{})
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, 0, ErrForbidden
+		}
+		query = query.Where("store_id = ?", *storeID)
+	} else if !access.IsAdmin() {
+		query = query.Where("store_id IN ?", access.StoreIDs)
+	}
+
+	switch {
+	case status != "":
+		if !models.ValidProductStatus(status) {
+			return nil, 0, fmt.Errorf("status must be draft, scheduled, published or archived")
+		}
+		query = query.Where("status = ?", status)
+	case !includeArchived:
+		query = query.Where("status <> ?", models.ProductArchived)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	if err := query.
+		Preload("Store").
+		Preload("Variants").
+		Preload("Images", orderedImages).
+		Order("name ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&products).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	return products, total, nil
+}
+
+// GetAdminProduct trae un producto (archivado o no) si el staff tiene acceso
+func GetAdminProduct(access *StaffAccess, productID uuid.UUID) (*models.Product, error) {
+	var product models.Product
+	if err := database.DB.
+		Preload("Store").
+		Preload("Variants").
+		Preload("Images", orderedImages).
+		First(&product, "id = ?", productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	if !access.CanManageStore(product.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	return &product, nil
+}
+
+// CreateProduct crea un producto con sus variantes
+func CreateProduct(access *StaffAccess, in ProductInput) (*models.Product, error) {
+	if err := validateProductInput(&in); err != nil {
+		return nil, err
+	}
+
+	if err := validateNewProductStatus(&in, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if !access.CanManageStore(in.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	var store models.Store
+	if err := database.DB.First(&store, "id = ? AND archived_at IS NULL", in.StoreID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+
+	product := &models.Product{
+		ID:                uuid.New(),
+		StoreID:           store.ID,
+		Name:              in.Name,
+		NameEN:            in.NameEN,
+		Description:       in.Description,
+		DescriptionEN:     in.DescriptionEN,
+		Price:             in.Price,
+		CompareAtPrice:    in.CompareAtPrice,
+		Sizes:             []byte("[]"),
+		Status:            in.Status,
+		PublishedAt:       in.PublishedAt,
+		LowStockThreshold: in.LowStockThreshold,
+		Version:           1,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// El seeder casa productos por (tienda, nombre), asi que no dejamos repetir
+		var count int64
+		if err := tx.Model(&models.Product{}).
+			Where("store_id = ? AND name = ?", store.ID, in.Name).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("a product named %q already exists in %s", in.Name, store.Name)
+		}
+
+		slug, err := uniqueProductSlug(tx, store.ID, product.ID, product.Name)
+		if err != nil {
+			return err
+		}
+		product.Slug = slug
+		if err := applyProductCategory(tx, product, in.CategoryID, in.Category); err != nil {
+			return err
+		}
+		if err := applyCategoryTranslation(tx, product, in.CategoryEN); err != nil {
+			return err
+		}
+
+		if err := tx.Create(product).Error; err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+		if err := recordPrice(tx, product.ID, product.Price, product.CompareAtPrice, models.PriceSourceAdmin, nil, product.CreatedAt); err != nil {
+			return err
+		}
+		if err := syncProductImages(tx, product.ID, in.images()); err != nil {
+			return err
+		}
+		return syncProductVariants(tx, product, store.Name, in.Variants)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminProduct(access, product.ID)
+}
+
+// UpdateProduct actualiza un producto si la version que mandan es la actual
+func UpdateProduct(access *StaffAccess, productID uuid.UUID, version int, in ProductInput) (*models.Product, error) {
+	if err := validateProductInput(&in); err != nil {
+		return nil, err
+	}
+
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Product{}).
+			Where("store_id = ? AND name = ? AND id <> ?", product.StoreID, in.Name, product.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("a product named %q already exists in %s", in.Name, product.Store.Name)
+		}
+
+		if err := applyProductCategory(tx, product, in.CategoryID, in.Category); err != nil {
+			return err
+		}
+		if err := applyCategoryTranslation(tx, product, in.CategoryEN); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"name":                in.Name,
+			"description":         in.Description,
+			"name_en":             in.NameEN,
+			"description_en":      in.DescriptionEN,
+			"category":            product.Category,
+			"category_en":         product.CategoryEN,
+			"category_id":         product.CategoryID,
+			"price":               in.Price,
+			"compare_at_price":    in.CompareAtPrice,
+			"low_stock_threshold": in.LowStockThreshold,
+			"version":             gorm.Expr("version + 1"),
+			"updated_at":          time.Now(),
+		}
+		// La fecha de un borrador se pone al publicar; la de un programado tiene que ser futura
+		if in.PublishedAt != nil && product.Status != models.ProductDraft {
+			if product.Status == models.ProductScheduled && !in.PublishedAt.After(time.Now()) {
+				return fmt.Errorf("published_at must be in the future for a scheduled product")
+			}
+			updates["published_at"] = *in.PublishedAt
+		}
+
+		// Solo pasa si nadie cambio la version mientras tanto
+		result := tx.Model(&models.Product{}).
+			Where("id = ? AND version = ?", product.ID, version).
+			Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update product: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		// Cambio a mano durante una rebaja (precio o tachado): se queda lo que pusieron,
+		// la rebaja queda marcada y al terminar ya no restaura
+		if product.Price != in.Price || !samePrice(product.CompareAtPrice, in.CompareAtPrice) {
+			if err := recordPrice(tx, product.ID, in.Price, in.CompareAtPrice, models.PriceSourceAdmin, nil, time.Now()); err != nil {
+				return err
+			}
+			if err := overridePriceSchedule(tx, product.ID); err != nil {
+				return err
+			}
+		}
+
+		if images := in.images(); images != nil {
+			if err := syncProductImages(tx, product.ID, images); err != nil {
+				return err
+			}
+		}
+
+		product.Name = in.Name
+		if err := renameProductSlug(tx, product); err != nil {
+			return err
+		}
+		return syncProductVariants(tx, product, product.Store.Name, in.Variants)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := GetAdminProduct(access, product.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Solo si alguna talla quedo con mas stock le avisamos a quien estaba esperando
+	if stockIncreased(product, updated) {
+		notifyBackInStockAsync()
+	}
+	return updated, nil
+}
+
+// ArchiveProduct saca el producto del catalogo publico sin borrarlo
+func ArchiveProduct(access *StaffAccess, productID uuid.UUID, version int) (*models.Product, error) {
+	product, err := SetProductStatus(access, productID, version, models.ProductArchived, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aprovechamos pa botar las fotos subidas que quedaron huerfanas
+	if err := CleanupProductUploads(product.ID); err != nil {
+		log.Printf("Warning: failed to clean uploads for product %s: %v", product.ID, err)
+	}
+
+	return product, nil
+}
+
+// RestoreProduct vuelve a publicar un producto archivado
+func RestoreProduct(access *StaffAccess, productID uuid.UUID, version int) (*models.Product, error) {
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+	// Restaurar es solo pa archivados; borradores y programados se publican por status
+	if product.Status != models.ProductArchived {
+		return nil, fmt.Errorf("%w: only archived products can be restored", ErrInvalidTransition)
+	}
+	return SetProductStatus(access, productID, version, models.ProductPublished, nil)
+}
+
+// ListAdminStores lista las tiendas que el staff puede ver
+func ListAdminStores(access *StaffAccess) ([]models.Store, error) {
+	var stores []models.Store
+	query := database.DB.Order("name ASC")
+	if !access.IsAdmin() {
+		query = query.Where("id IN ?", access.StoreIDs)
+	}
+	if err := query.Find(&stores).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stores: %w", err)
+	}
+	return stores, nil
+}
+
+// CreateStore crea una tienda nueva (solo admin)
+func CreateStore(access *StaffAccess, in StoreInput) (*models.Store, error) {
+	if !access.IsAdmin() {
+		return nil, ErrForbidden
+	}
+	if err := validateStoreInput(&in); err != nil {
+		return nil, err
+	}
+
+	store := &models.Store{
+		ID:                 uuid.New(),
+		Name:               in.Name,
+		Description:        in.Description,
+		LogoPath:           in.LogoPath,
+		ContactEmail:       in.ContactEmail,
+		ContactPhone:       in.ContactPhone,
+		WhatsApp:           in.WhatsApp,
+		ShippingOriginCity: in.ShippingOriginCity,
+		ShippingPolicy:     in.ShippingPolicy,
+		ReturnPolicy:       in.ReturnPolicy,
+		SortOrder:          in.SortOrder,
+		LowStockThreshold:  in.LowStockThreshold,
+		Version:            1,
+	}
+	if err := store.SetBanners(nonNilStrings(in.Banners)); err != nil {
+		return nil, fmt.Errorf("failed to encode banners: %w", err)
+	}
+	if in.SocialLinks == nil {
+		in.SocialLinks = map[string]string{}
+	}
+	if err := store.SetSocialLinks(in.SocialLinks); err != nil {
+		return nil, fmt.Errorf("failed to encode social links: %w", err)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkStoreName(tx, store.ID, in.Name); err != nil {
+			return err
+		}
+		slug, err := uniqueStoreSlug(tx, store.ID, firstNonEmpty(in.Slug, in.Name))
+		if err != nil {
+			return err
+		}
+		store.Slug = slug
+
+		if err := tx.Create(store).Error; err != nil {
+			return fmt.Errorf("failed to create store: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// UpdateStore actualiza una tienda con control de version
+func UpdateStore(access *StaffAccess, storeID uuid.UUID, version int, in StoreInput) (*models.Store, error) {
+	if !access.CanManageStore(storeID) {
+		return nil, ErrForbidden
+	}
+	if err := validateStoreInput(&in); err != nil {
+		return nil, err
+	}
+
+	if err := checkStoreName(database.DB, storeID, in.Name); err != nil {
+		return nil, err
+	}
+	slug, err := uniqueStoreSlug(database.DB, storeID, firstNonEmpty(in.Slug, in.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"name":                 in.Name,
+		"slug":                 slug,
+		"description":          in.Description,
+		"logo_path":            in.LogoPath,
+		"contact_email":        in.ContactEmail,
+		"contact_phone":        in.ContactPhone,
+		"whatsapp":             in.WhatsApp,
+		"shipping_origin_city": in.ShippingOriginCity,
+		"shipping_policy":      in.ShippingPolicy,
+		"return_policy":        in.ReturnPolicy,
+		"sort_order":           in.SortOrder,
+		"low_stock_threshold":  in.LowStockThreshold,
+	}
+	var encoded models.Store
+	if in.Banners != nil {
+		if err := encoded.SetBanners(in.Banners); err != nil {
+			return nil, fmt.Errorf("failed to encode banners: %w", err)
+		}
+		updates["banners"] = encoded.Banners
+	}
+	if in.SocialLinks != nil {
+		if err := encoded.SetSocialLinks(in.SocialLinks); err != nil {
+			return nil, fmt.Errorf("failed to encode social links: %w", err)
+		}
+		updates["social_links"] = encoded.SocialLinks
+	}
+
+	return updateStoreFields(storeID, version, updates)
+}
+
+// ArchiveStore esconde una tienda y todo su catalogo (solo admin)
+func ArchiveStore(access *StaffAccess, storeID uuid.UUID, version int) (*models.Store, error) {
+	if !access.IsAdmin() {
+		return nil, ErrForbidden
+	}
+	return updateStoreFields(storeID, version, map[string]interface{}{"archived_at": time.Now()})
+}
+
+// RestoreStore vuelve a mostrar una tienda archivada (solo admin)
+func RestoreStore(access *StaffAccess, storeID uuid.UUID, version int) (*models.Store, error) {
+	if !access.IsAdmin() {
+		return nil, ErrForbidden
+	}
+	return updateStoreFields(storeID, version, map[string]interface{}{"archived_at": nil})
+}
+
+func updateStoreFields(storeID uuid.UUID, version int, updates map[string]interface{}) (*models.Store, error) {
+	var store models.Store
+	if err := database.DB.First(&store, "id = ?", storeID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+
+	updates["version"] = gorm.Expr("version + 1")
+	updates["updated_at"] = time.Now()
+
+	result := database.DB.Model(&models.Store{}).
+		Where("id = ? AND version = ?", storeID, version).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update store: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	if err := database.DB.First(&store, "id = ?", storeID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+	return &store, nil
+}
@@ -6,32 +6,52 @@ import (
 	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/database"
 	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
 )
 
-// GetOrCreateCart trae o crea un carrito para user o sesion, sin misterio
-func GetOrCreateCart(userID *uuid.UUID, sessionID *string) (*models.Cart, error) {
+// cartOwnerQuery filtra por el user o, si es invitado, por la sesion del token
+func cartOwnerQuery(userID *uuid.UUID, sessionID *string) (*gorm.DB, error) {
+	switch {
+	case userID != nil:
+		return database.DB.Where("user_id = ?", userID), nil
+	case sessionID != nil:
+		return database.DB.Where("session_id = ? AND user_id IS NULL", sessionID), nil
+	}
+	return nil, fmt.Errorf("user_id or session_id required")
+}
+
+// FindCart trae el carrito del user o de la sesion sin crearlo
+func FindCart(userID *uuid.UUID, sessionID *string) (*models.Cart, error) {
+	query, err := cartOwnerQuery(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	var cart models.Cart
+	if err := query.First(&cart).Error; err != nil {
+		return nil, fmt.Errorf("cart not found")
+	}
+	return &cart, nil
+}
 
-	// Intentamos encontrar el carrito ya creado
-	query := database.DB
-	if userID != nil {
-		query = query.Where("user_id = ?", userID)
-	} else if sessionID != nil {
-		query = query.Where("session_id = ?", sessionID)
-	} else {
+// GetOrCreateCart trae o crea un carrito para user o sesion, sin misterio
+func GetOrCreateCart(userID *uuid.UUID, sessionID *string) (*models.Cart, error) {
+	if userID == nil && sessionID == nil {
 		return nil, fmt.Errorf("user_id or session_id required")
 	}
 
-	err := query.First(&cart).Error
-	if err == nil {
-		return &cart, nil
+	// Intentamos encontrar el carrito ya creado
+	if cart, err := FindCart(userID, sessionID); err == nil {
+		return cart, nil
 	}
 
-	// Crear carrito nuevo
-	cart = models.Cart{
-		ID:        uuid.New(),
-		UserID:    userID,
-		SessionID: sessionID,
+	// Crear carrito nuevo; con user no guardamos la sesion pa no cruzar carritos
+	cart := models.Cart{
+		ID:     uuid.New(),
+		UserID: userID,
+	}
+	if userID == nil {
+		cart.SessionID = sessionID
 	}
 
 	if err := database.DB.Create(&cart).Error; err != nil {
@@ -41,78 +61,107 @@ func GetOrCreateCart(userID *uuid.UUID, sessionID *string) (*models.Cart, error)
 	return &cart, nil
 }
 
-// AddItemToCart mete un item al carrito
-func AddItemToCart(cartID, productID uuid.UUID, quantity int, size string) (*models.CartItem, error) {
-	// Revisamos que el producto exista y tenga stock
+// AddItemToCart mete un item al carrito, validando stock de la variante
+func AddItemToCart(cartID, productID uuid.UUID, variantID *uuid.UUID, quantity int, size, color string) (*models.CartItem, error) {
+	// Revisamos que el producto exista
 	var product models.Product
-	if err := database.DB.First(&product, "id = ?", productID).Error; err != nil {
+	if err := database.DB.Scopes(visibleProducts).First(&product, "id = ?", productID).Error; err != nil {
 		return nil, fmt.Errorf("product not found")
 	}
 
-	if product.AvailableUnits < quantity {
-		return nil, fmt.Errorf("insufficient stock")
+	// Sacamos la variante (talla/color) que pidieron
+	variant, err := resolveVariant(database.DB, productID, variantID, size, color)
+	if err != nil {
+		return nil, err
 	}
 
 	// Si ya esta en el carrito, solo sumamos cantidad
 	var existingItem models.CartItem
-	if err := database.DB.Where("cart_id = ? AND product_id = ? AND size = ?", cartID, productID, size).First(&existingItem).Error; err == nil {
-		// Update quantity
+	if err := database.DB.Where("cart_id = ? AND variant_id = ?", cartID, variant.ID).First(&existingItem).Error; err == nil {
+		if variant.Stock < existingItem.Quantity+quantity {
+			return nil, fmt.Errorf("insufficient stock")
+		}
+
 		existingItem.Quantity += quantity
 		if err := database.DB.Save(&existingItem).Error; err != nil {
 			return nil, fmt.Errorf("failed to update cart item: %w", err)
 		}
+		existingItem.Variant = variant
 		return &existingItem, nil
 	}
 
+	if variant.Stock < quantity {
+		return nil, fmt.Errorf("insufficient stock")
+	}
+
 	// Item nuevo en el carrito
 	cartItem := &models.CartItem{
 		ID:        uuid.New(),
 		CartID:    cartID,
 		ProductID: productID,
+		VariantID: &variant.ID,
 		Quantity:  quantity,
-		Size:      size,
+		Size:      variant.Size,
 	}
 
 	if err := database.DB.Create(cartItem).Error; err != nil {
 		return nil, fmt.Errorf("failed to add item to cart: %w", err)
 	}
 
+	cartItem.Variant = variant
 	return cartItem, nil
 }
 
-// UpdateCartItem actualiza cantidad o talla
-func UpdateCartItem(itemID uuid.UUID, quantity int, size string) (*models.CartItem, error) {
+// UpdateCartItem actualiza cantidad o talla, revisando el stock de la variante.
+// El item tiene que ser del carrito de quien llama; si no, es como si no existiera.
+func UpdateCartItem(cartID, itemID uuid.UUID, quantity int, size string) (*models.CartItem, error) {
 	var item models.CartItem
-	if err := database.DB.First(&item, "id = ?", itemID).Error; err != nil {
+	if err := database.DB.Preload("Variant").First(&item, "id = ? AND cart_id = ?", itemID, cartID).Error; err != nil {
 		return nil, fmt.Errorf("cart item not found")
 	}
 
-	// Revisamos stock
-	var product models.Product
-	if err := database.DB.First(&product, "id = ?", item.ProductID).Error; err != nil {
-		return nil, fmt.Errorf("product not found")
+	// Si cambian la talla (o el item es viejo sin variante) buscamos la variante de nuevo
+	variant := item.Variant
+	if variant == nil || (size != "" && size != variant.Size) {
+		if size == "" {
+			size = item.Size
+		}
+		color := ""
+		if variant != nil {
+			color = variant.Color
+		}
+
+		resolved, err := resolveVariant(database.DB, item.ProductID, nil, size, color)
+		if err != nil {
+			return nil, err
+		}
+		variant = resolved
 	}
 
-	if product.AvailableUnits < quantity {
+	if variant.Stock < quantity {
 		return nil, fmt.Errorf("insufficient stock")
 	}
 
 	item.Quantity = quantity
-	if size != "" {
-		item.Size = size
-	}
+	item.VariantID = &variant.ID
+	item.Variant = variant
+	item.Size = variant.Size
 
-	if err := database.DB.Save(&item).Error; err != nil {
+	if err := database.DB.Omit("Variant").Save(&item).Error; err != nil {
 		return nil, fmt.Errorf("failed to update cart item: %w", err)
 	}
 
 	return &item, nil
 }
 
-// RemoveCartItem quita un item del carrito
-func RemoveCartItem(itemID uuid.UUID) error {
-	if err := database.DB.Delete(&models.CartItem{}, "id = ?", itemID).Error; err != nil {
-		return fmt.Errorf("failed to remove cart item: %w", err)
+// RemoveCartItem quita un item del carrito de quien llama
+func RemoveCartItem(cartID, itemID uuid.UUID) error {
+	result := database.DB.Delete(&models.CartItem{}, "id = ? AND cart_id = ?", itemID, cartID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove cart item: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("cart item not found")
 	}
 	return nil
 }
@@ -131,23 +180,9 @@ func GetCartItems(cartID uuid.UUID) ([]models.CartItem, error) {
 	if err := database.DB.
 		Where("cart_id = ?", cartID).
 		Preload("Product").
+		Preload("Variant").
 		Find(&items).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch cart items: %w", err)
 	}
 	return items, nil
 }
-
-// GetCartTotal calcula el total del carrito
-func GetCartTotal(cartID uuid.UUID) (float64, error) {
-	var total float64
-	if err := database.DB.
-		Table("cart_items").
-		Joins("JOIN products ON cart_items.product_id = products.id").
-		Where("cart_items.cart_id = ?", cartID).
-		Select("COALESCE(SUM(cart_items.quantity * products.price), 0)").
-		Row().
-		Scan(&total); err != nil {
-		return 0, fmt.Errorf("failed to calculate total: %w", err)
-	}
-	return total, nil
-}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// catalogCSVHeader son las columnas del CSV: una fila por variante
+var catalogCSVHeader = []string{
+	"key", "name", "category", "description", "price", "image",
+	"size", "color", "sku", "barcode", "stock", "variant_price",
+	"name_en", "category_en", "description_en",
+}
+
+// CatalogRecord es un producto leido del archivo con la linea donde empieza
+type CatalogRecord struct {
+	Line    int
+	Product ProductJSON
+}
+
+// CatalogError es un error de validacion apuntando a la linea del archivo
+type CatalogError struct {
+	Line    int
+	Product string
+	Message string
+}
+
+func (e CatalogError) Error() string {
+	if e.Product != "" {
+		return fmt.Sprintf("line %d (%s): %s", e.Line, e.Product, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ReadCatalogJSON lee el formato de assets/products/*.json
+func ReadCatalogJSON(r io.Reader) ([]CatalogRecord, []CatalogError) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, []CatalogError{{Line: 0, Message: err.Error()}}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, []CatalogError{{Line: 1, Message: "catalog must be a JSON array of products"}}
+	}
+
+	var records []CatalogRecord
+	var errs []CatalogError
+	for dec.More() {
+		line := lineAt(data, dec.InputOffset())
+
+		var p ProductJSON
+		if err := dec.Decode(&p); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &syntaxErr):
+				return records, append(errs, CatalogError{Line: lineAt(data, syntaxErr.Offset), Message: syntaxErr.Error()})
+			case errors.As(err, &typeErr):
+				errs = append(errs, CatalogError{Line: lineAt(data, typeErr.Offset), Message: fmt.Sprintf("field %s has the wrong type", typeErr.Field)})
+				continue
+			default:
+				return records, append(errs, CatalogError{Line: line, Message: err.Error()})
+			}
+		}
+
+		records = append(records, CatalogRecord{Line: line, Product: p})
+	}
+
+	// Un archivo cortado a la mitad no trae el "]": no aplicamos medio catalogo
+	if tok, err := dec.Token(); err != nil || tok != json.Delim(']') {
+		return records, append(errs, CatalogError{Line: lineAt(data, dec.InputOffset()), Message: "catalog array is not closed, the file looks truncated"})
+	}
+
+	return records, append(errs, validateCatalog(records)...)
+}
+
+// lineAt pasa un offset en bytes a numero de linea (desde 1), saltando espacios
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+		offset++
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// ReadCatalogCSV lee el CSV de las hojas de calculo. Las filas con la misma
+// key (o el mismo nombre si no hay columna key) son variantes del mismo producto; los datos del producto salen de la
+// primera fila. Varias fotos van en la columna image separadas por "|".
+func ReadCatalogCSV(r io.Reader) ([]CatalogRecord, []CatalogError) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, []CatalogError{{Line: 1, Message: "missing CSV header"}}
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	// Sin columna stock cada fila quedaria en 0 y se vaciaria el inventario de la tienda
+	for _, required := range []string{"name", "price", "stock"} {
+		if _, ok := columns[required]; !ok {
+			return nil, []CatalogError{{Line: 1, Message: fmt.Sprintf("missing required column %q", required)}}
+		}
+	}
+
+	var records []CatalogRecord
+	var errs []CatalogError
+	byKey := make(map[string]int)
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			errs = append(errs, CatalogError{Line: line, Message: err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		name := field("name")
+		if name == "" {
+			errs = append(errs, CatalogError{Line: line, Message: "name is required"})
+			continue
+		}
+
+		rowErr := func(format string, args ...interface{}) {
+			errs = append(errs, CatalogError{Line: line, Product: name, Message: fmt.Sprintf(format, args...)})
+		}
+
+		price, err := strconv.ParseFloat(field("price"), 64)
+		if err != nil {
+			rowErr("invalid price %q", field("price"))
+			continue
+		}
+
+		variant := ProductVariantJSON{
+			Size:    field("size"),
+			Color:   field("color"),
+			SKU:     field("sku"),
+			Barcode: field("barcode"),
+		}
+		if raw := field("stock"); raw != "" {
+			stock, err := strconv.Atoi(raw)
+			if err != nil {
+				rowErr("invalid stock %q", raw)
+				continue
+			}
+			variant.Stock = stock
+		}
+		if raw := field("variant_price"); raw != "" {
+			vp, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				rowErr("invalid variant_price %q", raw)
+				continue
+			}
+			variant.Price = &vp
+		}
+
+		group := field("key")
+		if group == "" {
+			group = name
+		}
+
+		idx, seen := byKey[group]
+		if !seen {
+			product := ProductJSON{
+				Key:         field("key"),
+				Name:        name,
+				Category:    field("category"),
+				Description: field("description"),
+				Price:       price,
+
+				NameEN:        field("name_en"),
+				CategoryEN:    field("category_en"),
+				DescriptionEN: field("description_en"),
+			}
+			for i, path := range strings.Split(field("image"), "|") {
+				if path = strings.TrimSpace(path); path != "" {
+					product.Images = append(product.Images, ProductImageJSON{Path: path, AltES: name, AltEN: field("name_en"), Primary: i == 0})
+				}
+			}
+			product.Image = primaryImagePath(product.Images)
+
+			byKey[group] = len(records)
+			idx = len(records)
+			records = append(records, CatalogRecord{Line: line, Product: product})
+		} else {
+			// Filas siguientes: si traen datos del producto tienen que cuadrar con la primera
+			first := records[idx].Product
+			if name != first.Name {
+				rowErr("name differs from the first row (%q)", first.Name)
+				continue
+			}
+			if price != first.Price {
+				rowErr("price %v differs from the first row (%v)", price, first.Price)
+				continue
+			}
+			if c := field("category"); c != "" && c != first.Category {
+				rowErr("category %q differs from the first row (%q)", c, first.Category)
+				continue
+			}
+		}
+
+		records[idx].Product.Variants = append(records[idx].Product.Variants, variant)
+	}
+
+	return records, append(errs, validateCatalog(records)...)
+}
+
+// validateCatalog corre las mismas reglas del admin sobre cada producto
+func validateCatalog(records []CatalogRecord) []CatalogError {
+	var errs []CatalogError
+	seen := make(map[string]int)
+
+	for i := range records {
+		record := &records[i]
+		p := &record.Product
+		p.Name = strings.TrimSpace(p.Name)
+		p.Key = strings.TrimSpace(p.Key)
+
+		key := catalogKey(*p)
+		if key == "" && p.Name != "" {
+			errs = append(errs, CatalogError{Line: record.Line, Product: p.Name, Message: "key or name must contain at least one letter or digit"})
+			continue
+		}
+		if len(key) > 100 {
+			errs = append(errs, CatalogError{Line: record.Line, Product: p.Name, Message: "key must be at most 100 characters"})
+			continue
+		}
+		if first, ok := seen[key]; ok && key != "" {
+			errs = append(errs, CatalogError{Line: record.Line, Product: p.Name, Message: fmt.Sprintf("duplicated product key %q, first defined on line %d", key, first)})
+			continue
+		}
+		seen[key] = record.Line
+
+		in := ProductInput{
+			Name:        p.Name,
+			NameEN:      p.NameEN,
+			Description: p.Description,
+			Category:    p.Category,
+			CategoryEN:  p.CategoryEN,
+			Price:       p.Price,
+			Images:      imagesFromJSON(*p),
+			Variants:    variantsFromJSON(*p),
+		}
+		if err := validateProductInput(&in); err != nil {
+			errs = append(errs, CatalogError{Line: record.Line, Product: p.Name, Message: err.Error()})
+		}
+	}
+
+	return errs
+}
+
+// WriteCatalogJSON escribe en el mismo formato que assets/products/*.json
+func WriteCatalogJSON(w io.Writer, products []ProductJSON) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(products)
+}
+
+// WriteCatalogCSV escribe una fila por variante
+func WriteCatalogCSV(w io.Writer, products []ProductJSON) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(catalogCSVHeader); err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		paths := make([]string, 0, len(p.Images))
+		for _, img := range p.Images {
+			paths = append(paths, img.Path)
+		}
+		if len(paths) == 0 && p.Image != "" {
+			paths = append(paths, p.Image)
+		}
+
+		for _, v := range variantsFromJSON(p) {
+			variantPrice := ""
+			if v.Price != nil {
+				variantPrice = strconv.FormatFloat(*v.Price, 'f', -1, 64)
+			}
+			row := []string{
+				catalogKey(p),
+				p.Name,
+				p.Category,
+				p.Description,
+				strconv.FormatFloat(p.Price, 'f', -1, 64),
+				strings.Join(paths, "|"),
+				v.Size,
+				v.Color,
+				v.SKU,
+				v.Barcode,
+				strconv.Itoa(v.Stock),
+				variantPrice,
+				p.NameEN,
+				p.CategoryEN,
+				p.DescriptionEN,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
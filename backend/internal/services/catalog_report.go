@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// SyncReport es el resultado de sincronizar un archivo del catalogo
+type SyncReport struct {
+	File       string          `json:"file"`
+	Store      string          `json:"store"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Created    int             `json:"created"`
+	Updated    int             `json:"updated"`
+	Archived   int             `json:"archived"`
+	Unchanged  int             `json:"unchanged"`
+	Skipped    int             `json:"skipped"`
+	Changes    []CatalogChange `json:"changes"`
+	Errors     []string        `json:"errors"`
+}
+
+// OK dice si el archivo se aplico sin errores
+func (r *SyncReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+var (
+	syncReportsMu sync.RWMutex
+	syncReports   = make(map[string]SyncReport)
+
+	// catalogSyncMu evita que el seeder y el watcher sincronicen al mismo tiempo
+	catalogSyncMu sync.Mutex
+)
+
+// LastSyncReports devuelve el ultimo reporte de cada archivo
+func LastSyncReports() []SyncReport {
+	syncReportsMu.RLock()
+	defer syncReportsMu.RUnlock()
+
+	reports := make([]SyncReport, 0, len(syncReports))
+	for _, report := range syncReports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].File < reports[j].File })
+	return reports
+}
+
+// storeNameFromFile saca el nombre de la tienda del archivo: celeste.json -> Celeste
+func storeNameFromFile(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	runes := []rune(name)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// findOrCreateStore busca la tienda del archivo y si no existe la crea
+func findOrCreateStore(name string) (*models.Store, error) {
+	store, err := FindStoreByName(name)
+	if err == nil {
+		return store, nil
+	}
+
+	store, err = createStoreRecord(name, "")
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("✓ Store %s created from catalog file", name)
+	return store, nil
+}
+
+// SyncCatalogFile deja la tienda igual al archivo: crea, actualiza y archiva lo
+// que falte. Si el archivo tiene cualquier error no se toca nada.
+func SyncCatalogFile(path string) SyncReport {
+	catalogSyncMu.Lock()
+	defer catalogSyncMu.Unlock()
+
+	report := SyncReport{
+		File:      filepath.Base(path),
+		Store:     storeNameFromFile(path),
+		StartedAt: time.Now(),
+	}
+	defer func() {
+		report.FinishedAt = time.Now()
+		syncReportsMu.Lock()
+		syncReports[report.File] = report
+		syncReportsMu.Unlock()
+		logSyncReport(report)
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	records, errs := ReadCatalogJSON(file)
+	file.Close()
+	for _, e := range errs {
+		report.Errors = append(report.Errors, e.Error())
+	}
+	if len(errs) > 0 {
+		return report
+	}
+
+	store, err := findOrCreateStore(report.Store)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	report.Store = store.Name
+
+	plan, err := ApplyCatalog(store, records, true)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+
+	report.Changes = plan.Changes
+	report.Created = plan.Count(CatalogCreate)
+	report.Updated = plan.Count(CatalogUpdate)
+	report.Archived = plan.Count(CatalogArchive)
+	report.Unchanged = plan.Count(CatalogUnchanged)
+	report.Skipped = plan.Count(CatalogSkip)
+	return report
+}
+
+// SyncCatalogDir sincroniza cada assets/products/*.json; cada archivo es una tienda
+func SyncCatalogDir(dir string) ([]SyncReport, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog files: %w", err)
+	}
+	sort.Strings(files)
+
+	reports := make([]SyncReport, 0, len(files))
+	for _, path := range files {
+		reports = append(reports, SyncCatalogFile(path))
+	}
+	return reports, nil
+}
+
+func logSyncReport(report SyncReport) {
+	if !report.OK() {
+		log.Printf("Warning: catalog %s rejected, previous catalog kept:", report.File)
+		for _, e := range report.Errors {
+			log.Printf("  %s", e)
+		}
+		return
+	}
+
+	log.Printf("✓ Catalog %s synced into %s: %d created, %d updated, %d archived, %d unchanged, %d skipped",
+		report.File, report.Store, report.Created, report.Updated, report.Archived, report.Unchanged, report.Skipped)
+	for _, change := range report.Changes {
+		if change.Action == CatalogUnchanged {
+			continue
+		}
+		log.Printf("  %s %s %s", change.Action, change.Product, strings.Join(change.Details, "; "))
+	}
+}
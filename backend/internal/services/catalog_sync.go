@@ -0,0 +1,577 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// Acciones que puede traer un plan de catalogo
+const (
+	CatalogCreate    = "create"
+	CatalogUpdate    = "update"
+	CatalogArchive   = "archive"
+	CatalogUnchanged = "unchanged"
+	CatalogSkip      = "skip"
+)
+
+// CatalogChange es lo que le va a pasar a un producto
+type CatalogChange struct {
+	Action  string   `json:"action"`
+	Key     string   `json:"key"`
+	Product string   `json:"product"`
+	Line    int      `json:"line,omitempty"`    // linea en el archivo (0 pa archivados, que no estan en el archivo)
+	Details []string `json:"details,omitempty"` // campo por campo, ej: "price: 75000 -> 80000"
+}
+
+// CatalogPlan es el diff completo de un import
+type CatalogPlan struct {
+	Store   string          `json:"store"`
+	Changes []CatalogChange `json:"changes"`
+}
+
+// Count cuenta cambios por accion
+func (p *CatalogPlan) Count(action string) int {
+	n := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// catalogKey es la key estable del producto; si el archivo no la trae sale del nombre
+func catalogKey(p ProductJSON) string {
+	if key := strings.TrimSpace(p.Key); key != "" {
+		return key
+	}
+	return utils.Slugify(p.Name)
+}
+
+// FindStoreByName busca la tienda sin importar mayusculas
+func FindStoreByName(name string) (*models.Store, error) {
+	var store models.Store
+	if err := database.DB.Where("LOWER(name) = ?", strings.ToLower(strings.TrimSpace(name))).First(&store).Error; err != nil {
+		return nil, fmt.Errorf("store %q not found", name)
+	}
+	return &store, nil
+}
+
+// storeCatalog son los productos de una tienda indexados pa casarlos con el archivo
+type storeCatalog struct {
+	byKey      map[string]*models.Product
+	byName     map[string]*models.Product // solo los que todavia no tienen key (data vieja)
+	categories map[uuid.UUID]models.Category
+	sales      map[uuid.UUID]*models.PriceSchedule // rebajas corriendo, por producto
+}
+
+// regularPrice es el precio sin rebaja: el archivo se compara contra este, no contra el vivo
+func (sc *storeCatalog) regularPrice(product *models.Product) float64 {
+	if sale := sc.sales[product.ID]; sale != nil && sale.OriginalPrice != nil {
+		return *sale.OriginalPrice
+	}
+	return product.Price
+}
+
+// categoryPath arma "Vestidos > Largos" desde la categoria del producto
+func (sc *storeCatalog) categoryPath(product *models.Product) string {
+	return categoryPath(sc.categories, product)
+}
+
+func categoryPath(categories map[uuid.UUID]models.Category, product *models.Product) string {
+	if product.CategoryID == nil {
+		return product.Category
+	}
+
+	var parts []string
+	for id := product.CategoryID; id != nil; {
+		category, ok := categories[*id]
+		if !ok {
+			break
+		}
+		parts = append([]string{category.Name}, parts...)
+		id = category.ParentID
+	}
+	return strings.Join(parts, " "+categoryPathSeparator+" ")
+}
+
+// categoryPathEN es categoryPath con los nombres en ingles; "" si algun nivel no tiene traduccion
+func categoryPathEN(categories map[uuid.UUID]models.Category, product *models.Product) string {
+	var parts []string
+	for id := product.CategoryID; id != nil; {
+		category, ok := categories[*id]
+		if !ok || category.NameEN == "" {
+			return ""
+		}
+		parts = append([]string{category.NameEN}, parts...)
+		id = category.ParentID
+	}
+	return strings.Join(parts, " "+categoryPathSeparator+" ")
+}
+
+// sameCategoryPath compara nivel por nivel con el mismo criterio que resolveCategoryPath
+func sameCategoryPath(a, b string) bool {
+	pa, pb := splitCategoryPath(a), splitCategoryPath(b)
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if categoryMatchKey(pa[i]) != categoryMatchKey(pb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// match busca por key y, si no, por nombre entre los productos sin key
+func (sc *storeCatalog) match(p ProductJSON) *models.Product {
+	if product, ok := sc.byKey[catalogKey(p)]; ok {
+		return product
+	}
+	return sc.byName[p.Name]
+}
+
+// loadStoreCatalog trae los productos de la tienda
+func loadStoreCatalog(db *gorm.DB, storeID uuid.UUID) (*storeCatalog, error) {
+	var products []models.Product
+	if err := db.
+		Preload("Variants").
+		Preload("Images", orderedImages).
+		Where("store_id = ?", storeID).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	var categories []models.Category
+	if err := db.Where("store_id = ?", storeID).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	sales, err := storeActiveSales(db, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &storeCatalog{
+		byKey:      make(map[string]*models.Product),
+		byName:     make(map[string]*models.Product),
+		categories: make(map[uuid.UUID]models.Category, len(categories)),
+		sales:      sales,
+	}
+	for _, category := range categories {
+		sc.categories[category.ID] = category
+	}
+	for i := range products {
+		if products[i].ExternalKey != nil {
+			sc.byKey[*products[i].ExternalKey] = &products[i]
+		} else {
+			sc.byName[products[i].Name] = &products[i]
+		}
+	}
+	return sc, nil
+}
+
+// planCatalog arma el diff contra lo que hay en la DB
+// Solo se archivan productos que vinieron de un archivo (con key); los creados
+// desde el admin no se tocan.
+func planCatalog(existing *storeCatalog, store *models.Store, records []CatalogRecord, archiveMissing bool) *CatalogPlan {
+	plan := &CatalogPlan{Store: store.Name}
+	matched := make(map[uuid.UUID]bool)
+	for _, record := range records {
+		p := record.Product
+		key := catalogKey(p)
+
+		current := existing.match(p)
+		if current == nil {
+			plan.Changes = append(plan.Changes, CatalogChange{Action: CatalogCreate, Key: key, Product: p.Name, Line: record.Line})
+			continue
+		}
+		matched[current.ID] = true
+
+		// Archivado por el staff: el archivo no lo revive, solo lo reportamos
+		if current.ArchivedAt != nil && !current.ArchivedBySync {
+			plan.Changes = append(plan.Changes, CatalogChange{
+				Action:  CatalogSkip,
+				Key:     key,
+				Product: p.Name,
+				Line:    record.Line,
+				Details: []string{"archived by staff"},
+			})
+			continue
+		}
+
+		change := CatalogChange{Action: CatalogUnchanged, Key: key, Product: p.Name, Line: record.Line}
+		if change.Details = diffProduct(existing, current, p, store.Name); len(change.Details) > 0 {
+			change.Action = CatalogUpdate
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if archiveMissing {
+		var archived []CatalogChange
+		for key, product := range existing.byKey {
+			if matched[product.ID] || product.ArchivedAt != nil {
+				continue
+			}
+			archived = append(archived, CatalogChange{Action: CatalogArchive, Key: key, Product: product.Name})
+		}
+		sort.Slice(archived, func(i, j int) bool { return archived[i].Product < archived[j].Product })
+		plan.Changes = append(plan.Changes, archived...)
+	}
+
+	return plan
+}
+
+// diffProduct lista lo que cambia entre la DB y el archivo
+func diffProduct(existing *storeCatalog, current *models.Product, p ProductJSON, storeName string) []string {
+	var details []string
+	if current.ExternalKey == nil || *current.ExternalKey != catalogKey(p) {
+		details = append(details, fmt.Sprintf("key: %s", catalogKey(p)))
+	}
+	if current.Name != p.Name {
+		details = append(details, fmt.Sprintf("name: %q -> %q", current.Name, p.Name))
+	}
+	if path := existing.categoryPath(current); !sameCategoryPath(path, p.Category) {
+		details = append(details, fmt.Sprintf("category: %q -> %q", path, p.Category))
+	}
+	if current.Description != p.Description {
+		details = append(details, "description changed")
+	}
+	if price := existing.regularPrice(current); price != p.Price {
+		details = append(details, fmt.Sprintf("price: %v -> %v", price, p.Price))
+	}
+	if p.NameEN != "" && current.NameEN != p.NameEN {
+		details = append(details, fmt.Sprintf("name_en: %q -> %q", current.NameEN, p.NameEN))
+	}
+	if p.DescriptionEN != "" && current.DescriptionEN != p.DescriptionEN {
+		details = append(details, "description_en changed")
+	}
+	if p.CategoryEN != "" {
+		if path := categoryPathEN(existing.categories, current); path != p.CategoryEN {
+			details = append(details, fmt.Sprintf("category_en: %q -> %q", path, p.CategoryEN))
+		}
+	}
+	if current.ArchivedAt != nil {
+		details = append(details, "restored from archive")
+	}
+
+	var currentPaths, newPaths []string
+	for _, img := range current.Images {
+		currentPaths = append(currentPaths, img.Path)
+	}
+	for _, img := range imagesFromJSON(p) {
+		newPaths = append(newPaths, strings.TrimSpace(img.Path))
+	}
+	if strings.Join(currentPaths, "|") != strings.Join(newPaths, "|") {
+		details = append(details, fmt.Sprintf("images: %d -> %d", len(currentPaths), len(newPaths)))
+	}
+
+	matched := make(map[uuid.UUID]bool)
+	for _, v := range variantsFromJSON(p) {
+		sku := strings.TrimSpace(v.SKU)
+		if sku == "" {
+			sku = generateSKU(storeName, p.Name, v.Size, v.Color)
+		}
+		label := strings.TrimSpace(v.Size + " " + v.Color)
+		if label == "" {
+			label = sku
+		}
+
+		var match *models.ProductVariant
+		for i := range current.Variants {
+			cv := &current.Variants[i]
+			if cv.SKU == sku || (v.SKU == "" && cv.Size == v.Size && cv.Color == v.Color) {
+				match = cv
+				break
+			}
+		}
+		if match == nil {
+			details = append(details, fmt.Sprintf("+ variant %s (stock %d)", label, v.Stock))
+			continue
+		}
+
+		matched[match.ID] = true
+		if !match.Active {
+			details = append(details, fmt.Sprintf("variant %s reactivated", label))
+		}
+		// El archivo con el mismo stock del ultimo sync no pisa las ventas
+		if match.Stock != v.Stock && catalogStockChanged(match, v.Stock) {
+			details = append(details, fmt.Sprintf("variant %s stock: %d -> %d", label, match.Stock, v.Stock))
+		}
+		if !samePrice(match.Price, v.Price) {
+			details = append(details, fmt.Sprintf("variant %s price changed", label))
+		}
+		if match.Barcode != v.Barcode {
+			details = append(details, fmt.Sprintf("variant %s barcode: %q -> %q", label, match.Barcode, v.Barcode))
+		}
+	}
+
+	for _, cv := range current.Variants {
+		if cv.Active && !matched[cv.ID] {
+			details = append(details, fmt.Sprintf("- variant %s", cv.SKU))
+		}
+	}
+
+	return details
+}
+
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// upsertCatalogProduct deja el producto igual al archivo: todos los campos,
+// galeria y variantes. Si lo habia archivado el sync y volvio al archivo, se restaura.
+// Con una rebaja corriendo, el precio del archivo queda como el regular pa cuando termine.
+func upsertCatalogProduct(tx *gorm.DB, store *models.Store, p ProductJSON, current *models.Product, sale *models.PriceSchedule) error {
+	sizesJSON, _ := json.Marshal(p.Sizes)
+	images := imagesFromJSON(p)
+	key := catalogKey(p)
+
+	var product models.Product
+	if current != nil {
+		product = *current
+		product.Variants = nil
+		product.Images = nil
+
+		product.ExternalKey = &key
+		product.Name = p.Name
+		product.Description = p.Description
+		// Traducciones: solo las pisa el archivo que las trae, asi un JSON viejo no borra las del admin
+		if p.NameEN != "" {
+			product.NameEN = p.NameEN
+		}
+		if p.DescriptionEN != "" {
+			product.DescriptionEN = p.DescriptionEN
+		}
+		priceChanged := false
+		if sale != nil {
+			if sale.OriginalPrice == nil || *sale.OriginalPrice != p.Price {
+				if err := tx.Model(sale).Update("original_price", p.Price).Error; err != nil {
+					return fmt.Errorf("failed to update sale regular price: %w", err)
+				}
+			}
+		} else if product.Price != p.Price {
+			product.Price = p.Price
+			product.CompareAtPrice = nil
+			priceChanged = true
+		}
+		product.ImagePath = primaryImagePath(images)
+		// Si vuelve al archivo se republica; borradores y programados se quedan como estan
+		if product.Status == models.ProductArchived {
+			product.Status = models.ProductPublished
+			if err := resetRestockWatermarks(tx, []uuid.UUID{product.ID}); err != nil {
+				return err
+			}
+		}
+		product.ArchivedAt = nil
+		product.ArchivedBySync = false
+		product.Version++
+		if err := applyProductCategory(tx, &product, nil, p.Category); err != nil {
+			return err
+		}
+		if err := applyCategoryTranslation(tx, &product, p.CategoryEN); err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Variants", "Images", "Store").Save(&product).Error; err != nil {
+			return err
+		}
+		if priceChanged {
+			if err := recordPrice(tx, product.ID, product.Price, nil, models.PriceSourceCatalog, nil, time.Now()); err != nil {
+				return err
+			}
+		}
+		if err := renameProductSlug(tx, &product); err != nil {
+			return err
+		}
+	} else {
+		product = models.Product{
+			ID:             uuid.New(),
+			StoreID:        store.ID,
+			ExternalKey:    &key,
+			Name:           p.Name,
+			NameEN:         p.NameEN,
+			Description:    p.Description,
+			DescriptionEN:  p.DescriptionEN,
+			Price:          p.Price,
+			AvailableUnits: p.AvailableUnits,
+			ImagePath:      primaryImagePath(images),
+			Sizes:          sizesJSON,
+		}
+
+		slug, err := uniqueProductSlug(tx, store.ID, product.ID, product.Name)
+		if err != nil {
+			return err
+		}
+		product.Slug = slug
+		if err := applyProductCategory(tx, &product, nil, p.Category); err != nil {
+			return err
+		}
+		if err := applyCategoryTranslation(tx, &product, p.CategoryEN); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordPrice(tx, product.ID, product.Price, nil, models.PriceSourceCatalog, nil, product.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := syncProductImages(tx, product.ID, images); err != nil {
+		return err
+	}
+
+	// Variantes: si el JSON es viejo, se migran desde sizes
+	variants := variantsFromJSON(p)
+	for i := range variants {
+		variants[i].imported = true
+	}
+	return syncProductVariants(tx, &product, store.Name, variants)
+}
+
+// PlanCatalog calcula el diff sin tocar nada (pa --dry-run)
+func PlanCatalog(store *models.Store, records []CatalogRecord, archiveMissing bool) (*CatalogPlan, error) {
+	existing, err := loadStoreCatalog(database.DB, store.ID)
+	if err != nil {
+		return nil, err
+	}
+	return planCatalog(existing, store, records, archiveMissing), nil
+}
+
+// ApplyCatalog aplica el archivo en una sola transaccion: si algo falla no queda nada a medias
+func ApplyCatalog(store *models.Store, records []CatalogRecord, archiveMissing bool) (*CatalogPlan, error) {
+	var plan *CatalogPlan
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := loadStoreCatalog(tx, store.ID)
+		if err != nil {
+			return err
+		}
+		plan = planCatalog(existing, store, records, archiveMissing)
+
+		byKey := make(map[string]ProductJSON, len(records))
+		for _, record := range records {
+			byKey[catalogKey(record.Product)] = record.Product
+		}
+
+		now := time.Now()
+		for _, change := range plan.Changes {
+			switch change.Action {
+			case CatalogCreate, CatalogUpdate:
+				p := byKey[change.Key]
+				current := existing.match(p)
+				var sale *models.PriceSchedule
+				if current != nil {
+					sale = existing.sales[current.ID]
+				}
+				if err := upsertCatalogProduct(tx, store, p, current, sale); err != nil {
+					return fmt.Errorf("line %d (%s): %w", change.Line, change.Product, err)
+				}
+			case CatalogArchive:
+				if err := tx.Model(&models.Product{}).
+					Where("store_id = ? AND external_key = ?", store.ID, change.Key).
+					Updates(map[string]interface{}{
+						"status":           models.ProductArchived,
+						"archived_at":      now,
+						"archived_by_sync": true,
+						"version":          gorm.Expr("version + 1"),
+						"updated_at":       now,
+					}).Error; err != nil {
+					return fmt.Errorf("failed to archive %s: %w", change.Product, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyBackInStockAsync()
+	return plan, nil
+}
+
+// ExportCatalog saca los productos activos de la tienda en el formato del archivo
+func ExportCatalog(storeID uuid.UUID) ([]ProductJSON, error) {
+	var products []models.Product
+	if err := database.DB.
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Where("active = ?", true).Order("created_at ASC")
+		}).
+		Preload("Images", orderedImages).
+		Where("store_id = ? AND archived_at IS NULL", storeID).
+		Order("category ASC, name ASC").
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	var categoryList []models.Category
+	if err := database.DB.Where("store_id = ?", storeID).Find(&categoryList).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	categories := make(map[uuid.UUID]models.Category, len(categoryList))
+	for _, category := range categoryList {
+		categories[category.ID] = category
+	}
+
+	// Con rebaja corriendo se exporta el precio regular, no el de la rebaja
+	sales, err := storeActiveSales(database.DB, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ProductJSON, 0, len(products))
+	for i := range products {
+		product := &products[i]
+		sizes, _ := product.GetSizes()
+		p := ProductJSON{
+			Category:       categoryPath(categories, product),
+			Name:           product.Name,
+			Description:    product.Description,
+			Price:          product.Price,
+			NameEN:         product.NameEN,
+			DescriptionEN:  product.DescriptionEN,
+			CategoryEN:     categoryPathEN(categories, product),
+			Sizes:          sizes,
+			AvailableUnits: product.AvailableUnits,
+			Image:          product.ImagePath,
+		}
+		if sale := sales[product.ID]; sale != nil && sale.OriginalPrice != nil {
+			p.Price = *sale.OriginalPrice
+		}
+		if product.ExternalKey != nil {
+			p.Key = *product.ExternalKey
+		} else {
+			p.Key = utils.Slugify(product.Name)
+		}
+		for _, img := range product.Images {
+			p.Images = append(p.Images, ProductImageJSON{Path: img.Path, AltES: img.AltES, AltEN: img.AltEN, Primary: img.IsPrimary})
+		}
+		for _, v := range product.Variants {
+			p.Variants = append(p.Variants, ProductVariantJSON{
+				Size:    v.Size,
+				Color:   v.Color,
+				SKU:     v.SKU,
+				Barcode: v.Barcode,
+				Stock:   v.Stock,
+				Price:   v.Price,
+			})
+		}
+		out = append(out, p)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CatalogStatus es lo que muestra el endpoint de estado del catalogo
+type CatalogStatus struct {
+	Watching bool         `json:"watching"`
+	Dir      string       `json:"dir"`
+	Interval string       `json:"interval,omitempty"`
+	Debounce string       `json:"debounce,omitempty"`
+	LastScan *time.Time   `json:"last_scan,omitempty"`
+	Pending  []string     `json:"pending"`
+	Reports  []SyncReport `json:"reports"`
+}
+
+// fileStamp sirve pa saber si un archivo cambio sin leerlo
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// catalogWatcher revisa la carpeta cada cierto tiempo (polling, sin dependencias)
+// y cuando los cambios se calman vuelve a sincronizar los archivos tocados
+type catalogWatcher struct {
+	dir      string
+	interval time.Duration
+	debounce time.Duration
+
+	mu         sync.Mutex
+	stamps     map[string]fileStamp
+	pending    map[string]bool
+	lastChange time.Time
+	lastScan   time.Time
+}
+
+var (
+	watcherMu     sync.Mutex
+	activeWatcher *catalogWatcher
+)
+
+// StartCatalogWatcher arranca el watcher en background; devuelve la funcion pa pararlo
+func StartCatalogWatcher(dir string, interval, debounce time.Duration) func() {
+	w := &catalogWatcher{
+		dir:      dir,
+		interval: interval,
+		debounce: debounce,
+		pending:  make(map[string]bool),
+	}
+	// La foto inicial no dispara sync: el seeder ya cargo todo al arrancar
+	w.stamps = w.scan()
+	w.lastScan = time.Now()
+
+	watcherMu.Lock()
+	activeWatcher = w
+	watcherMu.Unlock()
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.tick()
+			}
+		}
+	}()
+
+	log.Printf("✓ Watching catalog files in %s", dir)
+	return func() { close(stop) }
+}
+
+// scan toma tamano y fecha de cada *.json
+func (w *catalogWatcher) scan() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	files, err := filepath.Glob(filepath.Join(w.dir, "*.json"))
+	if err != nil {
+		log.Printf("Warning: failed to scan catalog dir: %v", err)
+		return stamps
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+	return stamps
+}
+
+func (w *catalogWatcher) tick() {
+	stamps := w.scan()
+
+	w.mu.Lock()
+	now := time.Now()
+	w.lastScan = now
+	for path, stamp := range stamps {
+		if old, ok := w.stamps[path]; !ok || old != stamp {
+			w.pending[path] = true
+			w.lastChange = now
+		}
+	}
+	for path := range w.stamps {
+		if _, ok := stamps[path]; !ok {
+			// Si borran el archivo no archivamos la tienda entera, puede ser un accidente
+			log.Printf("Warning: catalog file %s was removed, its products were left as they are", filepath.Base(path))
+		}
+	}
+	w.stamps = stamps
+
+	// Esperamos a que dejen de guardar (debounce) antes de sincronizar
+	if len(w.pending) == 0 || now.Sub(w.lastChange) < w.debounce {
+		w.mu.Unlock()
+		return
+	}
+	files := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		files = append(files, path)
+	}
+	w.pending = make(map[string]bool)
+	w.mu.Unlock()
+
+	sort.Strings(files)
+	for _, path := range files {
+		// Si quedo mal escrito se rechaza y el catalogo anterior sigue igual
+		SyncCatalogFile(path)
+	}
+}
+
+// GetCatalogStatus devuelve el estado del watcher y los ultimos reportes de sync
+func GetCatalogStatus() CatalogStatus {
+	status := CatalogStatus{
+		Dir:     CatalogDir(),
+		Pending: []string{},
+		Reports: LastSyncReports(),
+	}
+
+	watcherMu.Lock()
+	w := activeWatcher
+	watcherMu.Unlock()
+	if w == nil {
+		return status
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lastScan := w.lastScan
+	status.Watching = true
+	status.Dir = w.dir
+	status.Interval = w.interval.String()
+	status.Debounce = w.debounce.String()
+	status.LastScan = &lastScan
+	for path := range w.pending {
+		status.Pending = append(status.Pending, filepath.Base(path))
+	}
+	sort.Strings(status.Pending)
+	return status
+}
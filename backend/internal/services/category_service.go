@@ -0,0 +1,531 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrCategoryInUse sale al borrar una categoria que todavia tiene productos o hijas
+var ErrCategoryInUse = errors.New("category still has products or subcategories")
+
+// categoryPathSeparator separa niveles en el JSON: "Vestidos > Largos"
+const categoryPathSeparator = ">"
+
+// CategoryInput son los campos editables de una categoria desde el admin
+type CategoryInput struct {
+	StoreID   uuid.UUID
+	ParentID  *uuid.UUID
+	Name      string
+	NameEN    string // vacio = sin traduccion
+	Slug      string
+	SortOrder int
+}
+
+// CategoryNode es una categoria con sus hijas, pa los menus
+type CategoryNode struct {
+	Category     models.Category
+	ProductCount int64 // incluye los productos de las subcategorias
+	Children     []CategoryNode
+}
+
+// StoreCategoryTree es el arbol completo de una tienda
+type StoreCategoryTree struct {
+	Store      models.Store
+	Categories []CategoryNode
+}
+
+// categoryMatchKey normaliza pa comparar nombres: "Blusas", "blusas" y "Blusa"
+// quedan igual. Quita tildes, mayusculas y el plural simple del espanol.
+func categoryMatchKey(name string) string {
+	words := strings.Split(utils.Slugify(name), "-")
+	for i, w := range words {
+		switch {
+		case len(w) > 4 && strings.HasSuffix(w, "es") && !strings.ContainsRune("aeiou", rune(w[len(w)-3])):
+			words[i] = strings.TrimSuffix(w, "es")
+		case len(w) > 3 && strings.HasSuffix(w, "s"):
+			words[i] = strings.TrimSuffix(w, "s")
+		}
+	}
+	return strings.Join(words, "-")
+}
+
+// splitCategoryPath parte "Vestidos > Largos" en niveles
+func splitCategoryPath(path string) []string {
+	var parts []string
+	for _, part := range strings.Split(path, categoryPathSeparator) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// uniqueCategorySlug evita chocar con otra categoria de la misma tienda
+func uniqueCategorySlug(tx *gorm.DB, storeID, categoryID uuid.UUID, base string) (string, error) {
+	base, err := utils.NewSlug(base)
+	if err != nil {
+		return "", err
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Model(&models.Category{}).
+			Where("store_id = ? AND slug = ? AND id <> ?", storeID, slug, categoryID).
+			Count(&taken).Error; err != nil {
+			return "", fmt.Errorf("failed to check category slug: %w", err)
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// resolveCategoryPath encuentra (o crea) la categoria de cada nivel del path.
+// Los nombres se casan con categoryMatchKey, asi "blusa" cae en "Blusas".
+func resolveCategoryPath(tx *gorm.DB, storeID uuid.UUID, path string) (*models.Category, error) {
+	var parent *models.Category
+	for _, name := range splitCategoryPath(path) {
+		query := tx.Where("store_id = ?", storeID)
+		if parent == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", parent.ID)
+		}
+
+		var siblings []models.Category
+		if err := query.Order("sort_order ASC").Find(&siblings).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch categories: %w", err)
+		}
+
+		var found *models.Category
+		key := categoryMatchKey(name)
+		for i := range siblings {
+			if categoryMatchKey(siblings[i].Name) == key {
+				found = &siblings[i]
+				break
+			}
+		}
+
+		if found == nil {
+			slug, err := uniqueCategorySlug(tx, storeID, uuid.Nil, name)
+			if err != nil {
+				return nil, err
+			}
+			found = &models.Category{
+				ID:        uuid.New(),
+				StoreID:   storeID,
+				Name:      name,
+				Slug:      slug,
+				SortOrder: len(siblings),
+			}
+			if parent != nil {
+				found.ParentID = &parent.ID
+			}
+			if err := tx.Create(found).Error; err != nil {
+				return nil, fmt.Errorf("failed to create category %s: %w", name, err)
+			}
+		}
+		parent = found
+	}
+
+	return parent, nil
+}
+
+// categoryForProduct decide la categoria de un producto: por ID si la mandan,
+// si no por el texto (que puede ser un path). nil si no trae categoria.
+func categoryForProduct(tx *gorm.DB, storeID uuid.UUID, categoryID *uuid.UUID, path string) (*models.Category, error) {
+	if categoryID != nil {
+		var category models.Category
+		if err := tx.First(&category, "id = ? AND store_id = ?", *categoryID, storeID).Error; err != nil {
+			return nil, fmt.Errorf("category not found")
+		}
+		return &category, nil
+	}
+	if len(splitCategoryPath(path)) == 0 {
+		return nil, nil
+	}
+	return resolveCategoryPath(tx, storeID, path)
+}
+
+// applyProductCategory deja category_id y la copia del nombre en el producto (sin guardar)
+func applyProductCategory(tx *gorm.DB, product *models.Product, categoryID *uuid.UUID, path string) error {
+	category, err := categoryForProduct(tx, product.StoreID, categoryID, path)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		product.CategoryID = nil
+		product.Category = ""
+		product.CategoryEN = ""
+		return nil
+	}
+	product.CategoryID = &category.ID
+	product.Category = category.Name
+	product.CategoryEN = category.NameEN
+	return nil
+}
+
+// applyCategoryTranslation pone los nombres en ingles del path ("Dresses > Long")
+// en la categoria del producto y sus padres, de la hoja pa arriba. Vacio = no toca nada.
+func applyCategoryTranslation(tx *gorm.DB, product *models.Product, pathEN string) error {
+	names := splitCategoryPath(pathEN)
+	if product.CategoryID == nil || len(names) == 0 {
+		return nil
+	}
+
+	id := product.CategoryID
+	for i := len(names) - 1; i >= 0 && id != nil; i-- {
+		var category models.Category
+		if err := tx.First(&category, "id = ?", *id).Error; err != nil {
+			return fmt.Errorf("category not found")
+		}
+		if category.NameEN != names[i] {
+			if err := setCategoryNameEN(tx, &category, names[i]); err != nil {
+				return err
+			}
+		}
+		if category.ID == *product.CategoryID {
+			product.CategoryEN = category.NameEN
+		}
+		id = category.ParentID
+	}
+	return nil
+}
+
+// setCategoryNameEN guarda la traduccion y la copia en los productos de esa categoria
+func setCategoryNameEN(tx *gorm.DB, category *models.Category, nameEN string) error {
+	category.NameEN = nameEN
+	if err := tx.Model(category).Update("name_en", nameEN).Error; err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	return tx.Model(&models.Product{}).
+		Where("category_id = ?", category.ID).
+		Update("category_en", nameEN).Error
+}
+
+// EnsureProductCategories pasa los textos viejos de products.category al arbol
+func EnsureProductCategories() error {
+	var products []models.Product
+	if err := database.DB.
+		Where("category_id IS NULL AND category <> ''").
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products without category: %w", err)
+	}
+
+	for i := range products {
+		product := &products[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := applyProductCategory(tx, product, nil, product.Category); err != nil {
+				return err
+			}
+			return tx.Model(&models.Product{}).Where("id = ?", product.ID).Updates(map[string]interface{}{
+				"category_id": product.CategoryID,
+				"category":    product.Category,
+				"category_en": product.CategoryEN,
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate category of %s: %w", product.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// categoryIDsMatching busca las categorias que coinciden con el texto (slug o
+// nombre normalizado) y les suma todas sus descendientes
+func categoryIDsMatching(db *gorm.DB, category string) ([]uuid.UUID, error) {
+	var categories []models.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	children := make(map[uuid.UUID][]uuid.UUID)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	slug := utils.Slugify(category)
+	key := categoryMatchKey(category)
+	var ids []uuid.UUID
+	var walk func(id uuid.UUID)
+	walk = func(id uuid.UUID) {
+		ids = append(ids, id)
+		for _, child := range children[id] {
+			walk(child)
+		}
+	}
+	for _, c := range categories {
+		if c.Slug == slug || categoryMatchKey(c.Name) == key {
+			walk(c.ID)
+		}
+	}
+	return ids, nil
+}
+
+// inCategory filtra productos por categoria (incluye subcategorias)
+func inCategory(category string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		ids, err := categoryIDsMatching(database.DB, category)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		if len(ids) == 0 {
+			return db.Where("1 = 0")
+		}
+		return db.Where("products.category_id IN ?", ids)
+	}
+}
+
+// GetCategoryTrees arma el arbol de cada tienda activa (o de una sola)
+func GetCategoryTrees(storeID *uuid.UUID) ([]StoreCategoryTree, error) {
+	var stores []models.Store
+	query := database.DB.Where("archived_at IS NULL").Order("name ASC")
+	if storeID != nil {
+		query = query.Where("id = ?", *storeID)
+	}
+	if err := query.Find(&stores).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stores: %w", err)
+	}
+
+	trees := make([]StoreCategoryTree, 0, len(stores))
+	for _, store := range stores {
+		nodes, err := categoryTree(store.ID)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, StoreCategoryTree{Store: store, Categories: nodes})
+	}
+	return trees, nil
+}
+
+func categoryTree(storeID uuid.UUID) ([]CategoryNode, error) {
+	var categories []models.Category
+	if err := database.DB.Where("store_id = ?", storeID).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	// Conteo de productos publicos por categoria
+	var counts []struct {
+		CategoryID uuid.UUID
+		Total      int64
+	}
+	if err := database.DB.Model(&models.Product{}).
+		Scopes(visibleProducts).
+		Select("category_id, COUNT(*) AS total").
+		Where("products.store_id = ? AND category_id IS NOT NULL", storeID).
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+	direct := make(map[uuid.UUID]int64)
+	for _, c := range counts {
+		direct[c.CategoryID] = c.Total
+	}
+
+	byParent := make(map[uuid.UUID][]models.Category)
+	for _, c := range categories {
+		parent := uuid.Nil
+		if c.ParentID != nil {
+			parent = *c.ParentID
+		}
+		byParent[parent] = append(byParent[parent], c)
+	}
+
+	var build func(parent uuid.UUID) []CategoryNode
+	build = func(parent uuid.UUID) []CategoryNode {
+		list := byParent[parent]
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].SortOrder != list[j].SortOrder {
+				return list[i].SortOrder < list[j].SortOrder
+			}
+			return list[i].Name < list[j].Name
+		})
+
+		nodes := make([]CategoryNode, 0, len(list))
+		for _, c := range list {
+			node := CategoryNode{Category: c, ProductCount: direct[c.ID], Children: build(c.ID)}
+			for _, child := range node.Children {
+				node.ProductCount += child.ProductCount
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+
+	return build(uuid.Nil), nil
+}
+
+// validateCategoryParent revisa que el padre sea de la misma tienda y que no haya ciclos
+func validateCategoryParent(tx *gorm.DB, storeID, categoryID uuid.UUID, parentID *uuid.UUID) error {
+	for current := parentID; current != nil; {
+		if *current == categoryID {
+			return fmt.Errorf("a category cannot be inside itself")
+		}
+		var parent models.Category
+		if err := tx.First(&parent, "id = ?", *current).Error; err != nil {
+			return fmt.Errorf("parent category not found")
+		}
+		if parent.StoreID != storeID {
+			return fmt.Errorf("parent category belongs to another store")
+		}
+		current = parent.ParentID
+	}
+	return nil
+}
+
+func validateCategoryInput(in *CategoryInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.NameEN = strings.TrimSpace(in.NameEN)
+	in.Slug = strings.TrimSpace(in.Slug)
+	if in.Name == "" || len(in.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if len(in.NameEN) > 100 || strings.Contains(in.NameEN, categoryPathSeparator) {
+		return fmt.Errorf("name_en must be at most 100 characters and cannot contain %q", categoryPathSeparator)
+	}
+	if strings.Contains(in.Name, categoryPathSeparator) {
+		return fmt.Errorf("name cannot contain %q", categoryPathSeparator)
+	}
+	if len(in.Slug) > 100 {
+		return fmt.Errorf("slug must be at most 100 characters")
+	}
+	return nil
+}
+
+// CreateCategory crea una categoria en una tienda
+func CreateCategory(access *StaffAccess, in CategoryInput) (*models.Category, error) {
+	if err := validateCategoryInput(&in); err != nil {
+		return nil, err
+	}
+	if !access.CanManageStore(in.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	category := &models.Category{
+		ID:        uuid.New(),
+		StoreID:   in.StoreID,
+		ParentID:  in.ParentID,
+		Name:      in.Name,
+		NameEN:    in.NameEN,
+		SortOrder: in.SortOrder,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var store models.Store
+		if err := tx.First(&store, "id = ?", in.StoreID).Error; err != nil {
+			return fmt.Errorf("store not found")
+		}
+		if err := validateCategoryParent(tx, in.StoreID, category.ID, in.ParentID); err != nil {
+			return err
+		}
+
+		slug, err := uniqueCategorySlug(tx, in.StoreID, category.ID, firstNonEmpty(in.Slug, in.Name))
+		if err != nil {
+			return err
+		}
+		category.Slug = slug
+
+		if err := tx.Create(category).Error; err != nil {
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory cambia nombre, slug, padre u orden; la tienda no se puede cambiar
+func UpdateCategory(access *StaffAccess, categoryID uuid.UUID, in CategoryInput) (*models.Category, error) {
+	if err := validateCategoryInput(&in); err != nil {
+		return nil, err
+	}
+
+	var category models.Category
+	if err := database.DB.First(&category, "id = ?", categoryID).Error; err != nil {
+		return nil, fmt.Errorf("category not found")
+	}
+	if !access.CanManageStore(category.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := validateCategoryParent(tx, category.StoreID, category.ID, in.ParentID); err != nil {
+			return err
+		}
+
+		slug, err := uniqueCategorySlug(tx, category.StoreID, category.ID, firstNonEmpty(in.Slug, in.Name))
+		if err != nil {
+			return err
+		}
+
+		category.Name = in.Name
+		category.NameEN = in.NameEN
+		category.Slug = slug
+		category.ParentID = in.ParentID
+		category.SortOrder = in.SortOrder
+		if err := tx.Omit("Store", "Parent", "Children", "Products").Save(&category).Error; err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+
+		// La copia del nombre en products tiene que seguir igual a la categoria
+		return tx.Model(&models.Product{}).
+			Where("category_id = ?", category.ID).
+			Updates(map[string]interface{}{"category": category.Name, "category_en": category.NameEN}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// DeleteCategory borra una categoria vacia
+func DeleteCategory(access *StaffAccess, categoryID uuid.UUID) error {
+	var category models.Category
+	if err := database.DB.First(&category, "id = ?", categoryID).Error; err != nil {
+		return fmt.Errorf("category not found")
+	}
+	if !access.CanManageStore(category.StoreID) {
+		return ErrForbidden
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var products, children int64
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&products).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+			return err
+		}
+		if products > 0 || children > 0 {
+			return ErrCategoryInUse
+		}
+		return tx.Delete(&category).Error
+	})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
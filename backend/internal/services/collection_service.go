@@ -0,0 +1,448 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// CollectionInput son los campos editables de una coleccion desde el admin
+type CollectionInput struct {
+	StoreID     uuid.UUID
+	Name        string
+	Slug        string
+	Description string
+	BannerPath  string
+	StartsAt    *time.Time
+	EndsAt      *time.Time
+	SortOrder   int
+	ProductIDs  []uuid.UUID // en orden; nil significa "no tocar los productos"
+}
+
+// CollectionSummary es una coleccion con su conteo, pa los listados
+type CollectionSummary struct {
+	Collection   models.Collection
+	Automatic    bool // la de lo nuevo, armada por fecha de publicacion
+	ProductCount int64
+}
+
+// newArrivals deja solo lo publicado en los ultimos NEW_ARRIVALS_DAYS dias
+func newArrivals(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	since := now.AddDate(0, 0, -config.Get().NewArrivalsDays)
+	return db.Scopes(visibleProducts).
+		Where("products.published_at <= ? AND products.published_at >= ?", now, since)
+}
+
+// newArrivalsCollection arma la coleccion automatica de una tienda (no vive en la DB)
+func newArrivalsCollection(store models.Store) models.Collection {
+	return models.Collection{
+		StoreID:     store.ID,
+		Slug:        models.NewArrivalsSlug,
+		Name:        "Lo nuevo",
+		Description: fmt.Sprintf("Lo ultimo que llego a %s", store.Name),
+		SortOrder:   -1,
+	}
+}
+
+// activeCollections deja las colecciones cuya ventana de fechas incluye ahora
+func activeCollections(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	return db.Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", now, now)
+}
+
+// inCollection deja los productos de la coleccion, en el orden que eligieron
+func inCollection(collectionID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN collection_products ON collection_products.product_id = products.id").
+			Where("collection_products.collection_id = ?", collectionID)
+	}
+}
+
+func findPublicStore(storeID uuid.UUID) (*models.Store, error) {
+	var store models.Store
+	if err := database.DB.First(&store, "id = ? AND archived_at IS NULL", storeID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+	return &store, nil
+}
+
+// ListCollections trae las colecciones visibles de una tienda; lo nuevo va primero
+func ListCollections(storeID uuid.UUID) ([]CollectionSummary, error) {
+	store, err := findPublicStore(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []CollectionSummary{}
+
+	var newCount int64
+	if err := database.DB.Model(&models.Product{}).
+		Scopes(newArrivals).
+		Where("products.store_id = ?", store.ID).
+		Count(&newCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count new arrivals: %w", err)
+	}
+	if newCount > 0 {
+		summaries = append(summaries, CollectionSummary{
+			Collection:   newArrivalsCollection(*store),
+			Automatic:    true,
+			ProductCount: newCount,
+		})
+	}
+
+	var collections []models.Collection
+	if err := database.DB.Scopes(activeCollections).
+		Where("store_id = ?", store.ID).
+		Order("sort_order ASC, name ASC").
+		Find(&collections).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %w", err)
+	}
+
+	counts, err := collectionProductCounts(collections)
+	if err != nil {
+		return nil, err
+	}
+	for _, collection := range collections {
+		summaries = append(summaries, CollectionSummary{Collection: collection, ProductCount: counts[collection.ID]})
+	}
+
+	return summaries, nil
+}
+
+// collectionProductCounts cuenta solo los productos publicos de cada coleccion
+func collectionProductCounts(collections []models.Collection) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64)
+	if len(collections) == 0 {
+		return counts, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(collections))
+	for _, c := range collections {
+		ids = append(ids, c.ID)
+	}
+
+	var rows []struct {
+		CollectionID uuid.UUID
+		Total        int64
+	}
+	if err := database.DB.Model(&models.Product{}).
+		Scopes(visibleProducts).
+		Select("collection_products.collection_id, COUNT(*) AS total").
+		Joins("JOIN collection_products ON collection_products.product_id = products.id").
+		Where("collection_products.collection_id IN ?", ids).
+		Group("collection_products.collection_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count collection products: %w", err)
+	}
+	for _, row := range rows {
+		counts[row.CollectionID] = row.Total
+	}
+	return counts, nil
+}
+
+// GetCollectionProducts trae una coleccion publica por slug con sus productos paginados
+func GetCollectionProducts(storeID uuid.UUID, slug string, page, limit int) (*CollectionSummary, []models.Product, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	store, err := findPublicStore(storeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := &CollectionSummary{}
+	query := database.DB.Model(&models.Product{}).Where("products.store_id = ?", store.ID)
+	order := "products.published_at DESC, products.created_at DESC"
+
+	if slug == models.NewArrivalsSlug {
+		summary.Collection = newArrivalsCollection(*store)
+		summary.Automatic = true
+		query = query.Scopes(newArrivals)
+	} else {
+		if err := database.DB.Scopes(activeCollections).
+			Where("store_id = ? AND slug = ?", store.ID, slug).
+			First(&summary.Collection).Error; err != nil {
+			return nil, nil, fmt.Errorf("collection not found")
+		}
+		query = query.Scopes(visibleProducts, inCollection(summary.Collection.ID))
+		order = "collection_products.position ASC"
+	}
+
+	if err := query.Count(&summary.ProductCount).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	var products []models.Product
+	if err := query.
+		Preload("Store").
+		Preload("Images", orderedImages).
+		Order(order).
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&products).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	return summary, products, nil
+}
+
+// ListAdminCollections lista todas las colecciones (activas o no) de las tiendas del staff
+func ListAdminCollections(access *StaffAccess, storeID *uuid.UUID) ([]CollectionSummary, error) {
+	query := database.DB.Model(&models.Collection{})
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, ErrForbidden
+		}
+		query = query.Where("store_id = ?", *storeID)
+	} else if !access.IsAdmin() {
+		query = query.Where("store_id IN ?", access.StoreIDs)
+	}
+
+	var collections []models.Collection
+	if err := query.Preload("Store").Order("store_id, sort_order ASC, name ASC").Find(&collections).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %w", err)
+	}
+
+	counts, err := collectionProductCounts(collections)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]CollectionSummary, 0, len(collections))
+	for _, collection := range collections {
+		summaries = append(summaries, CollectionSummary{Collection: collection, ProductCount: counts[collection.ID]})
+	}
+	return summaries, nil
+}
+
+// GetAdminCollection trae la coleccion con todos sus productos, incluso archivados
+func GetAdminCollection(access *StaffAccess, collectionID uuid.UUID) (*models.Collection, error) {
+	var collection models.Collection
+	if err := database.DB.
+		Preload("Store").
+		Preload("Products", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Preload("Products.Product").
+		First(&collection, "id = ?", collectionID).Error; err != nil {
+		return nil, fmt.Errorf("collection not found")
+	}
+	if !access.CanManageStore(collection.StoreID) {
+		return nil, ErrForbidden
+	}
+	return &collection, nil
+}
+
+func validateCollectionInput(in *CollectionInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Slug = strings.TrimSpace(in.Slug)
+	in.BannerPath = strings.TrimSpace(in.BannerPath)
+
+	if in.Name == "" || len(in.Name) > 150 {
+		return fmt.Errorf("name is required and must be at most 150 characters")
+	}
+	if len(in.Slug) > 100 {
+		return fmt.Errorf("slug must be at most 100 characters")
+	}
+	if utils.Slugify(firstNonEmpty(in.Slug, in.Name)) == models.NewArrivalsSlug {
+		return fmt.Errorf("slug %q is reserved for new arrivals", models.NewArrivalsSlug)
+	}
+	if len(in.BannerPath) > 255 {
+		return fmt.Errorf("banner path must be at most 255 characters")
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// uniqueCollectionSlug evita chocar con otra coleccion de la misma tienda
+func uniqueCollectionSlug(tx *gorm.DB, storeID, collectionID uuid.UUID, base string) (string, error) {
+	base, err := utils.NewSlug(base)
+	if err != nil {
+		return "", err
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Model(&models.Collection{}).
+			Where("store_id = ? AND slug = ? AND id <> ?", storeID, slug, collectionID).
+			Count(&taken).Error; err != nil {
+			return "", fmt.Errorf("failed to check collection slug: %w", err)
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// setCollectionProducts reemplaza la lista de productos respetando el orden que mandan
+func setCollectionProducts(tx *gorm.DB, collection *models.Collection, productIDs []uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, id := range productIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > 0 {
+		var count int64
+		if err := tx.Model(&models.Product{}).
+			Where("id IN ? AND store_id = ?", ids, collection.StoreID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check collection products: %w", err)
+		}
+		if int(count) != len(ids) {
+			return fmt.Errorf("all products must exist and belong to the collection's store")
+		}
+	}
+
+	if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionProduct{}).Error; err != nil {
+		return fmt.Errorf("failed to clear collection products: %w", err)
+	}
+	for i, id := range ids {
+		item := &models.CollectionProduct{ID: uuid.New(), CollectionID: collection.ID, ProductID: id, Position: i}
+		if err := tx.Create(item).Error; err != nil {
+			return fmt.Errorf("failed to add collection product: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateCollection crea una coleccion en una tienda
+func CreateCollection(access *StaffAccess, in CollectionInput) (*models.Collection, error) {
+	if err := validateCollectionInput(&in); err != nil {
+		return nil, err
+	}
+	if !access.CanManageStore(in.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	collection := &models.Collection{
+		ID:          uuid.New(),
+		StoreID:     in.StoreID,
+		Name:        in.Name,
+		Description: in.Description,
+		BannerPath:  in.BannerPath,
+		StartsAt:    in.StartsAt,
+		EndsAt:      in.EndsAt,
+		SortOrder:   in.SortOrder,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var store models.Store
+		if err := tx.First(&store, "id = ?", in.StoreID).Error; err != nil {
+			return fmt.Errorf("store not found")
+		}
+
+		slug, err := uniqueCollectionSlug(tx, in.StoreID, collection.ID, firstNonEmpty(in.Slug, in.Name))
+		if err != nil {
+			return err
+		}
+		collection.Slug = slug
+
+		if err := tx.Create(collection).Error; err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+		return setCollectionProducts(tx, collection, in.ProductIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminCollection(access, collection.ID)
+}
+
+// UpdateCollection cambia los datos de la coleccion; la tienda no se puede cambiar
+func UpdateCollection(access *StaffAccess, collectionID uuid.UUID, in CollectionInput) (*models.Collection, error) {
+	if err := validateCollectionInput(&in); err != nil {
+		return nil, err
+	}
+
+	collection, err := GetAdminCollection(access, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		slug, err := uniqueCollectionSlug(tx, collection.StoreID, collection.ID, firstNonEmpty(in.Slug, in.Name))
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Collection{}).Where("id = ?", collection.ID).Updates(map[string]interface{}{
+			"name":        in.Name,
+			"slug":        slug,
+			"description": in.Description,
+			"banner_path": firstNonEmpty(in.BannerPath, collection.BannerPath),
+			"starts_at":   in.StartsAt,
+			"ends_at":     in.EndsAt,
+			"sort_order":  in.SortOrder,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update collection: %w", err)
+		}
+
+		if in.ProductIDs != nil {
+			return setCollectionProducts(tx, collection, in.ProductIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminCollection(access, collection.ID)
+}
+
+// DeleteCollection borra la coleccion; los productos no se tocan
+func DeleteCollection(access *StaffAccess, collectionID uuid.UUID) error {
+	collection, err := GetAdminCollection(access, collectionID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionProduct{}).Error; err != nil {
+			return fmt.Errorf("failed to delete collection products: %w", err)
+		}
+		return tx.Delete(&models.Collection{}, "id = ?", collection.ID).Error
+	})
+}
+
+// UploadCollectionBanner sube el banner (sin EXIF) y lo deja en la coleccion
+func UploadCollectionBanner(access *StaffAccess, collectionID uuid.UUID, r io.Reader) (*models.Collection, error) {
+	collection, err := GetAdminCollection(access, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	relPath, _, _, err := storeUploadedImage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Model(&models.Collection{}).Where("id = ?", collection.ID).Updates(map[string]interface{}{
+		"banner_path": relPath,
+		"updated_at":  time.Now(),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save banner: %w", err)
+	}
+
+	return GetAdminCollection(access, collection.ID)
+}
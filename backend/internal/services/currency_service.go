@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// ErrUnsupportedCurrency sale cuando piden una moneda que no mostramos o sin tasa
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// DisplayCurrency es la moneda en la que se muestran los estimados.
+// Rate = cuantos COP vale 1 unidad; pa COP es 1.
+type DisplayCurrency struct {
+	Code string
+	Rate float64
+}
+
+// SettlementCurrency es COP, en lo que de verdad se cobra
+var SettlementCurrency = DisplayCurrency{Code: utils.CurrencyCOP, Rate: 1}
+
+// Convert pasa un monto en COP a la moneda, ya redondeado con sus reglas
+func (d DisplayCurrency) Convert(cop float64) float64 {
+	if d.Code == utils.CurrencyCOP || d.Rate <= 0 {
+		return utils.RoundMoney(cop, utils.CurrencyCOP)
+	}
+	return utils.RoundMoney(cop/d.Rate, d.Code)
+}
+
+// Format convierte y escribe el monto con simbolo y separadores
+func (d DisplayCurrency) Format(cop float64) string {
+	return utils.FormatMoney(d.Convert(cop), d.Code)
+}
+
+// ExchangeRateInfo es la tasa vigente de una moneda y de donde sale
+type ExchangeRateInfo struct {
+	Code      string
+	Rate      float64
+	Source    string // "config" o "admin"
+	UpdatedAt *time.Time
+}
+
+// currentRates junta las tasas de EXCHANGE_RATES con las del admin (estas mandan)
+func currentRates() (map[string]ExchangeRateInfo, error) {
+	rates := make(map[string]ExchangeRateInfo)
+	for code, rate := range config.Get().ExchangeRates {
+		if _, ok := utils.LookupCurrency(code); !ok || code == utils.CurrencyCOP {
+			continue
+		}
+		rates[code] = ExchangeRateInfo{Code: code, Rate: rate, Source: "config"}
+	}
+
+	var stored []models.ExchangeRate
+	if err := database.DB.Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+	for _, rate := range stored {
+		updatedAt := rate.UpdatedAt
+		rates[rate.Code] = ExchangeRateInfo{Code: rate.Code, Rate: rate.Rate, Source: "admin", UpdatedAt: &updatedAt}
+	}
+	return rates, nil
+}
+
+// GetDisplayCurrency resuelve el ?currency= de la peti; vacio = COP
+func GetDisplayCurrency(code string) (DisplayCurrency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || code == utils.CurrencyCOP {
+		return SettlementCurrency, nil
+	}
+	if _, ok := utils.LookupCurrency(code); !ok {
+		return DisplayCurrency{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+
+	rates, err := currentRates()
+	if err != nil {
+		return DisplayCurrency{}, err
+	}
+	rate, ok := rates[code]
+	if !ok {
+		return DisplayCurrency{}, fmt.Errorf("%w: no exchange rate for %s", ErrUnsupportedCurrency, code)
+	}
+	return DisplayCurrency{Code: code, Rate: rate.Rate}, nil
+}
+
+// ListExchangeRates devuelve las tasas vigentes ordenadas por codigo
+func ListExchangeRates() ([]ExchangeRateInfo, error) {
+	rates, err := currentRates()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]ExchangeRateInfo, 0, len(rates))
+	for _, rate := range rates {
+		list = append(list, rate)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list, nil
+}
+
+// SetExchangeRate guarda la tasa del admin pa una moneda (solo admin)
+func SetExchangeRate(access *StaffAccess, code string, rate float64) (*ExchangeRateInfo, error) {
+	if !access.IsAdmin() {
+		return nil, ErrForbidden
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == utils.CurrencyCOP {
+		return nil, fmt.Errorf("COP is the settlement currency and has no exchange rate")
+	}
+	if _, ok := utils.LookupCurrency(code); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("rate must be greater than 0")
+	}
+
+	now := time.Now()
+	stored := models.ExchangeRate{Code: code, Rate: rate, UpdatedBy: &access.UserID, CreatedAt: now, UpdatedAt: now}
+	var existing models.ExchangeRate
+	if err := database.DB.First(&existing, "code = ?", code).Error; err == nil {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	if err := database.DB.Save(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return &ExchangeRateInfo{Code: code, Rate: rate, Source: "admin", UpdatedAt: &now}, nil
+}
+
+// DeleteExchangeRate quita la tasa del admin; si hay una en la config vuelve a esa
+func DeleteExchangeRate(access *StaffAccess, code string) error {
+	if !access.IsAdmin() {
+		return ErrForbidden
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	result := database.DB.Where("code = ?", code).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("exchange rate not found")
+	}
+	return nil
+}
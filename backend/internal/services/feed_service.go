@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// Tipos de documento que sabemos generar
+const (
+	FeedSitemap     = "sitemap"
+	FeedMerchantXML = "merchant_xml"
+	FeedMerchantCSV = "merchant_csv"
+)
+
+// Moneda del feed, los precios del catalogo estan en pesos
+const feedCurrency = "COP"
+
+// FeedDocument es un sitemap o feed ya armado, listo pa servir tal cual
+type FeedDocument struct {
+	Body         []byte
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// feedCache guarda los documentos generados mientras el catalogo no cambie
+var feedCache = struct {
+	sync.Mutex
+	fingerprint string
+	docs        map[string]*FeedDocument
+}{}
+
+// Tablas que, si cambian, invalidan los feeds
+var feedSourceTables = []string{"stores", "categories", "products", "product_variants", "product_images"}
+
+// catalogFingerprint resume el estado del catalogo con conteos y max updated_at.
+// Los conteos atrapan borrados, los timestamps atrapan ediciones.
+func catalogFingerprint(db *gorm.DB) (string, time.Time, error) {
+	var lastModified time.Time
+	h := sha256.New()
+
+	for _, table := range feedSourceTables {
+		var row struct {
+			Count   int64
+			Updated *time.Time
+		}
+		if err := db.Table(table).Select("COUNT(*) AS count, MAX(updated_at) AS updated").Scan(&row).Error; err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to read catalog state: %w", err)
+		}
+
+		updated := int64(0)
+		if row.Updated != nil {
+			updated = row.Updated.UnixNano()
+			if row.Updated.After(lastModified) {
+				lastModified = *row.Updated
+			}
+		}
+		fmt.Fprintf(h, "%s=%d:%d;", table, row.Count, updated)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16]), lastModified, nil
+}
+
+// GetCatalogFeed devuelve el documento pedido, regenerandolo solo si el catalogo cambio
+func GetCatalogFeed(kind string) (*FeedDocument, error) {
+	var generate func(*feedCatalog) ([]byte, error)
+	contentType := ""
+	switch kind {
+	case FeedSitemap:
+		generate, contentType = buildSitemap, "application/xml; charset=utf-8"
+	case FeedMerchantXML:
+		generate, contentType = buildMerchantXML, "application/rss+xml; charset=utf-8"
+	case FeedMerchantCSV:
+		generate, contentType = buildMerchantCSV, "text/csv; charset=utf-8"
+	default:
+		return nil, fmt.Errorf("unknown feed %q", kind)
+	}
+
+	fingerprint, lastModified, err := catalogFingerprint(database.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	feedCache.Lock()
+	defer feedCache.Unlock()
+
+	if feedCache.fingerprint != fingerprint || feedCache.docs == nil {
+		feedCache.fingerprint = fingerprint
+		feedCache.docs = make(map[string]*FeedDocument)
+	}
+	if doc, ok := feedCache.docs[kind]; ok {
+		return doc, nil
+	}
+
+	catalog, err := loadFeedCatalog(database.DB)
+	if err != nil {
+		return nil, err
+	}
+	body, err := generate(catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &FeedDocument{
+		Body:         body,
+		ContentType:  contentType,
+		ETag:         `W/"` + kind + "-" + fingerprint + `"`,
+		LastModified: lastModified,
+	}
+	feedCache.docs[kind] = doc
+	return doc, nil
+}
+
+// feedCatalog es lo publico del catalogo, cargado una vez por generacion
+type feedCatalog struct {
+	stores       []models.Store
+	categoryList []models.Category // en orden, pal sitemap
+	categories   map[uuid.UUID]models.Category
+	products     []models.Product
+}
+
+func loadFeedCatalog(db *gorm.DB) (*feedCatalog, error) {
+	catalog := &feedCatalog{categories: make(map[uuid.UUID]models.Category)}
+
+	if err := db.Where("archived_at IS NULL").Order("name ASC").Find(&catalog.stores).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stores: %w", err)
+	}
+
+	if err := db.Where("store_id IN (SELECT id FROM stores WHERE archived_at IS NULL)").
+		Order("sort_order ASC, name ASC").
+		Find(&catalog.categoryList).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	for _, category := range catalog.categoryList {
+		catalog.categories[category.ID] = category
+	}
+
+	if err := db.Scopes(visibleProducts).
+		Preload("Store").
+		Preload("Images", orderedImages).
+		Order("products.store_id ASC, products.name ASC").
+		Find(&catalog.products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// URLs publicas. El frontend tiene una pagina por tienda (pages/celeste.html)
+// y la ficha del producto se abre por tienda + slug.
+
+func frontendURL(path string) string {
+	return strings.TrimRight(config.Get().FrontendURL, "/") + path
+}
+
+// storeSlug cae al nombre si la tienda todavia no tiene slug cargado
+func storeSlug(store *models.Store) string {
+	return firstNonEmpty(store.Slug, utils.Slugify(store.Name))
+}
+
+func storePageURL(store *models.Store) string {
+	return frontendURL("/pages/" + storeSlug(store) + ".html")
+}
+
+func categoryPageURL(store *models.Store, category *models.Category) string {
+	return storePageURL(store) + "?category=" + url.QueryEscape(category.Slug)
+}
+
+func productPageURL(product *models.Product) string {
+	query := url.Values{}
+	query.Set("store", storeSlug(&product.Store))
+	query.Set("slug", product.Slug)
+	return frontendURL("/pages/product.html?" + query.Encode())
+}
+
+// feedImageURL arma el link absoluto a la imagen servida por el API
+func feedImageURL(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	for _, prefix := range []string{"../assets/images/", "assets/images/", "images/"} {
+		if idx := strings.Index(path, prefix); idx != -1 {
+			path = path[idx+len(prefix):]
+			break
+		}
+	}
+
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimRight(config.Get().PublicAPIURL, "/") + "/api/products/images/" + strings.Join(segments, "/")
+}
+
+// Sitemap (https://www.sitemaps.org/protocol.html)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+func sitemapDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02")
+}
+
+func buildSitemap(catalog *feedCatalog) ([]byte, error) {
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	set.URLs = append(set.URLs, sitemapURL{Loc: frontendURL("/"), ChangeFreq: "daily", Priority: "1.0"})
+
+	storeUpdated := make(map[uuid.UUID]time.Time)
+	for _, store := range catalog.stores {
+		storeUpdated[store.ID] = store.UpdatedAt
+	}
+
+	// La pagina de la tienda cambia cuando cambia cualquiera de sus productos
+	for _, product := range catalog.products {
+		if product.UpdatedAt.After(storeUpdated[product.StoreID]) {
+			storeUpdated[product.StoreID] = product.UpdatedAt
+		}
+	}
+
+	for _, store := range catalog.stores {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:        storePageURL(&store),
+			LastMod:    sitemapDate(storeUpdated[store.ID]),
+			ChangeFreq: "daily",
+			Priority:   "0.8",
+		})
+	}
+
+	for _, store := range catalog.stores {
+		for _, category := range catalog.categoryList {
+			if category.StoreID != store.ID {
+				continue
+			}
+			set.URLs = append(set.URLs, sitemapURL{
+				Loc:        categoryPageURL(&store, &category),
+				LastMod:    sitemapDate(category.UpdatedAt),
+				ChangeFreq: "weekly",
+				Priority:   "0.6",
+			})
+		}
+	}
+
+	for i := range catalog.products {
+		product := &catalog.products[i]
+		if product.Slug == "" {
+			continue
+		}
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:        productPageURL(product),
+			LastMod:    sitemapDate(product.UpdatedAt),
+			ChangeFreq: "weekly",
+			Priority:   "0.7",
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		return nil, fmt.Errorf("failed to encode sitemap: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// Feed de productos estilo Google Merchant
+
+// merchantItem es una fila del feed; el mismo dato sale en RSS y en CSV
+type merchantItem struct {
+	ID                   string
+	Title                string
+	Description          string
+	Link                 string
+	ImageLink            string
+	AdditionalImageLinks []string
+	Availability         string
+	Price                string
+	SalePrice            string
+	Brand                string
+	Condition            string
+	ProductType          string
+}
+
+func feedPrice(amount float64) string {
+	return fmt.Sprintf("%.2f %s", amount, feedCurrency)
+}
+
+func merchantItems(catalog *feedCatalog) []merchantItem {
+	items := make([]merchantItem, 0, len(catalog.products))
+	for i := range catalog.products {
+		product := &catalog.products[i]
+		if product.Slug == "" || product.Price <= 0 {
+			continue
+		}
+
+		item := merchantItem{
+			ID:           product.ID.String(),
+			Title:        product.Name,
+			Description:  product.Description,
+			Link:         productPageURL(product),
+			Availability: "out_of_stock",
+			Price:        feedPrice(product.Price),
+			Brand:        product.Store.Name,
+			Condition:    "new",
+			ProductType:  categoryPath(catalog.categories, product),
+		}
+		if item.Description == "" {
+			item.Description = product.Name
+		}
+		if product.AvailableUnits > 0 {
+			item.Availability = "in_stock"
+		}
+
+		// Con rebaja: price es el "antes" y sale_price lo que se cobra
+		if product.CompareAtPrice != nil && *product.CompareAtPrice > product.Price {
+			item.Price = feedPrice(*product.CompareAtPrice)
+			item.SalePrice = feedPrice(product.Price)
+		}
+
+		for _, image := range product.Images {
+			if item.ImageLink == "" {
+				item.ImageLink = feedImageURL(image.Path)
+				continue
+			}
+			if len(item.AdditionalImageLinks) < 10 { // Merchant acepta hasta 10 extra
+				item.AdditionalImageLinks = append(item.AdditionalImageLinks, feedImageURL(image.Path))
+			}
+		}
+		if item.ImageLink == "" && product.ImagePath != "" {
+			item.ImageLink = feedImageURL(product.ImagePath)
+		}
+
+		items = append(items, item)
+	}
+	return items
+}
+
+type merchantRSS struct {
+	XMLName xml.Name           `xml:"rss"`
+	Version string             `xml:"version,attr"`
+	XMLNSG  string             `xml:"xmlns:g,attr"`
+	Channel merchantRSSChannel `xml:"channel"`
+}
+
+type merchantRSSChannel struct {
+	Title       string            `xml:"title"`
+	Link        string            `xml:"link"`
+	Description string            `xml:"description"`
+	Items       []merchantRSSItem `xml:"item"`
+}
+
+type merchantRSSItem struct {
+	ID                   string   `xml:"g:id"`
+	Title                string   `xml:"title"`
+	Description          string   `xml:"description"`
+	Link                 string   `xml:"link"`
+	ImageLink            string   `xml:"g:image_link,omitempty"`
+	AdditionalImageLinks []string `xml:"g:additional_image_link,omitempty"`
+	Availability         string   `xml:"g:availability"`
+	Price                string   `xml:"g:price"`
+	SalePrice            string   `xml:"g:sale_price,omitempty"`
+	Brand                string   `xml:"g:brand"`
+	Condition            string   `xml:"g:condition"`
+	ProductType          string   `xml:"g:product_type,omitempty"`
+}
+
+func buildMerchantXML(catalog *feedCatalog) ([]byte, error) {
+	feed := merchantRSS{
+		Version: "2.0",
+		XMLNSG:  "http://base.google.com/ns/1.0",
+		Channel: merchantRSSChannel{
+			Title:       "Celeste x Mewave",
+			Link:        frontendURL("/"),
+			Description: "Catalogo de productos de Celeste x Mewave",
+		},
+	}
+
+	for _, item := range merchantItems(catalog) {
+		feed.Channel.Items = append(feed.Channel.Items, merchantRSSItem(item))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return nil, fmt.Errorf("failed to encode product feed: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+var merchantCSVHeader = []string{
+	"id", "title", "description", "link", "image_link", "additional_image_link",
+	"availability", "price", "sale_price", "brand", "condition", "product_type",
+}
+
+func buildMerchantCSV(catalog *feedCatalog) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(merchantCSVHeader); err != nil {
+		return nil, fmt.Errorf("failed to write product feed: %w", err)
+	}
+
+	for _, item := range merchantItems(catalog) {
+		record := []string{
+			item.ID, item.Title, item.Description, item.Link, item.ImageLink,
+			strings.Join(item.AdditionalImageLinks, ","),
+			item.Availability, item.Price, item.SalePrice, item.Brand, item.Condition,
+			item.ProductType,
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write product feed: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write product feed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/utils"
+)
+
+var (
+	// ErrImageNotFound sale cuando el archivo no existe dentro de UploadDir
+	ErrImageNotFound = errors.New("image not found")
+	// ErrInvalidImageRequest sale con nombres raros o tamanos fuera de la lista
+	ErrInvalidImageRequest = errors.New("invalid image request")
+)
+
+// allowedImageSizes son los anchos/altos que dejamos pedir, asi nadie llena el disco
+var allowedImageSizes = map[int]bool{
+	160: true, 320: true, 480: true, 640: true, 960: true, 1280: true, 1920: true,
+}
+
+// imageFormats mapea ?fmt= al content type
+var imageFormats = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+}
+
+var (
+	imageFSOnce sync.Once
+	imageFS     fs.FS
+)
+
+// uploadFS es el unico lugar desde donde servimos imagenes
+func uploadFS() fs.FS {
+	imageFSOnce.Do(func() {
+		imageFS = os.DirFS(config.Get().UploadDir)
+	})
+	return imageFS
+}
+
+// ImageRequest son los parametros de ?w=&h=&fmt=
+type ImageRequest struct {
+	Name   string
+	Width  int
+	Height int
+	Format string
+}
+
+// ServedImage es lo que necesita el handler pa responder
+type ServedImage struct {
+	Content     io.ReadSeekCloser
+	Name        string
+	ModTime     time.Time
+	ETag        string
+	ContentType string
+	Immutable   bool // nombres por hash nunca cambian de contenido
+}
+
+// validImageName revisa que el path sea relativo, limpio y sin archivos ocultos
+func validImageName(name string) bool {
+	if !fs.ValidPath(name) || name == "." {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenImage abre el original o una variante redimensionada (cacheada en disco)
+func OpenImage(req ImageRequest) (*ServedImage, error) {
+	if !validImageName(req.Name) {
+		return nil, ErrInvalidImageRequest
+	}
+	if (req.Width != 0 && !allowedImageSizes[req.Width]) || (req.Height != 0 && !allowedImageSizes[req.Height]) {
+		return nil, ErrInvalidImageRequest
+	}
+	if req.Format != "" {
+		if _, ok := imageFormats[strings.ToLower(req.Format)]; !ok {
+			return nil, ErrInvalidImageRequest
+		}
+	}
+
+	file, err := uploadFS().Open(req.Name)
+	if err != nil {
+		return nil, ErrImageNotFound
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, ErrImageNotFound
+	}
+
+	immutable := strings.HasPrefix(req.Name, uploadsSubdir+"/")
+	fingerprint := fmt.Sprintf("%s|%d|%d|%d|%d|%s", req.Name, info.Size(), info.ModTime().UnixNano(), req.Width, req.Height, strings.ToLower(req.Format))
+	sum := sha256.Sum256([]byte(fingerprint))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Sin parametros mandamos el original tal cual
+	if req.Width == 0 && req.Height == 0 && req.Format == "" {
+		seeker, ok := file.(io.ReadSeekCloser)
+		if !ok {
+			file.Close()
+			return nil, ErrImageNotFound
+		}
+		return &ServedImage{
+			Content:   seeker,
+			Name:      path.Base(req.Name),
+			ModTime:   info.ModTime(),
+			ETag:      etag,
+			Immutable: immutable,
+		}, nil
+	}
+
+	defer file.Close()
+	return openImageVariant(file, req, info.ModTime(), etag, hex.EncodeToString(sum[:16]), immutable)
+}
+
+// openImageVariant genera (o reusa del cache) la version redimensionada
+func openImageVariant(original io.Reader, req ImageRequest, modTime time.Time, etag, key string, immutable bool) (*ServedImage, error) {
+	cfg := config.Get()
+
+	contentType := imageFormats[strings.ToLower(req.Format)]
+	if contentType == "" {
+		contentType = "image/jpeg"
+		if strings.EqualFold(path.Ext(req.Name), ".png") {
+			contentType = "image/png"
+		}
+	}
+
+	cachePath := filepath.Join(cfg.ImageCacheDir, key[:2], key+utils.ImageExtension(contentType))
+	if _, err := os.Stat(cachePath); err != nil {
+		if err := renderImageVariant(original, req, contentType, cachePath); err != nil {
+			return nil, err
+		}
+	}
+
+	cached, err := os.Open(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cached image: %w", err)
+	}
+
+	base := strings.TrimSuffix(path.Base(req.Name), path.Ext(req.Name))
+	return &ServedImage{
+		Content:     cached,
+		Name:        base + utils.ImageExtension(contentType),
+		ModTime:     modTime,
+		ETag:        etag,
+		ContentType: contentType,
+		Immutable:   immutable,
+	}, nil
+}
+
+func renderImageVariant(original io.Reader, req ImageRequest, contentType, cachePath string) error {
+	raw, err := io.ReadAll(original)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+
+	// Dimensiones primero: un original con header mentiroso no nos tumba el proceso
+	src, err := utils.DecodeImage(raw)
+	if err != nil {
+		return ErrInvalidImageRequest
+	}
+
+	img := utils.ResizeImage(src, req.Width, req.Height)
+
+	// JPEG no tiene transparencia: pintamos sobre blanco pa que no salga negro
+	if contentType == "image/jpeg" {
+		canvas := image.NewRGBA(img.Bounds())
+		draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+		draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Over)
+		img = canvas
+	}
+
+	data, err := utils.EncodeImage(img, contentType)
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create image cache dir: %w", err)
+	}
+
+	// Temporal + rename: si dos requests generan lo mismo, gana cualquiera sin romper nada
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".variant-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cached image: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cached image: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cached image: %w", err)
+	}
+
+	return nil
+}
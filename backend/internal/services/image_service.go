@@ -0,0 +1,263 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ProductImageJSON es la forma de una foto de la galeria en el JSON
+type ProductImageJSON struct {
+	Path    string `json:"path"`
+	AltES   string `json:"alt_es"`
+	AltEN   string `json:"alt_en"`
+	Primary bool   `json:"primary"`
+}
+
+// ImageOrderInput es una foto en el orden nuevo que manda el admin
+type ImageOrderInput struct {
+	ID      uuid.UUID
+	AltES   string
+	AltEN   string
+	Primary bool
+}
+
+// imagesFromJSON devuelve la galeria; el campo viejo "image" sigue sirviendo como foto unica
+func imagesFromJSON(p ProductJSON) []ProductImageJSON {
+	if len(p.Images) > 0 {
+		return p.Images
+	}
+	if p.Image != "" {
+		return []ProductImageJSON{{Path: p.Image, AltES: p.Name, Primary: true}}
+	}
+	return nil
+}
+
+// primaryImagePath devuelve la principal (o la primera si nadie la marco)
+func primaryImagePath(images []ProductImageJSON) string {
+	for _, img := range images {
+		if img.Primary {
+			return img.Path
+		}
+	}
+	if len(images) > 0 {
+		return images[0].Path
+	}
+	return ""
+}
+
+// syncProductImages deja la galeria igual a la lista (orden incluido) y copia
+// la principal a products.image_path pa carrito, correos y clientes viejos
+func syncProductImages(tx *gorm.DB, productID uuid.UUID, images []ProductImageJSON) error {
+	var existing []models.ProductImage
+	if err := tx.Where("product_id = ?", productID).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch product images: %w", err)
+	}
+
+	byPath := make(map[string]*models.ProductImage)
+	for i := range existing {
+		byPath[existing[i].Path] = &existing[i]
+	}
+
+	primary := primaryImagePath(images)
+	keep := make(map[uuid.UUID]bool)
+	for position, img := range images {
+		img.Path = strings.TrimSpace(img.Path)
+		if img.Path == "" {
+			continue
+		}
+
+		current, ok := byPath[img.Path]
+		if !ok {
+			current = &models.ProductImage{ID: uuid.New(), ProductID: productID, Path: img.Path}
+		}
+		current.Position = position
+		current.AltES = img.AltES
+		current.AltEN = img.AltEN
+		current.IsPrimary = img.Path == primary
+
+		save := tx.Save
+		if !ok {
+			save = tx.Create
+		}
+		if err := save(current).Error; err != nil {
+			return fmt.Errorf("failed to save product image: %w", err)
+		}
+		byPath[img.Path] = current
+		keep[current.ID] = true
+	}
+
+	for _, img := range existing {
+		if keep[img.ID] {
+			continue
+		}
+		if err := tx.Delete(&models.ProductImage{}, "id = ?", img.ID).Error; err != nil {
+			return fmt.Errorf("failed to remove product image: %w", err)
+		}
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image_path", primary).Error
+}
+
+// loadImageList trae la galeria actual como lista editable
+func loadImageList(tx *gorm.DB, productID uuid.UUID) ([]ProductImageJSON, error) {
+	var images []models.ProductImage
+	if err := tx.Where("product_id = ?", productID).Order("position ASC").Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product images: %w", err)
+	}
+
+	list := make([]ProductImageJSON, 0, len(images))
+	for _, img := range images {
+		list = append(list, ProductImageJSON{Path: img.Path, AltES: img.AltES, AltEN: img.AltEN, Primary: img.IsPrimary})
+	}
+	return list, nil
+}
+
+// appendProductImage mete una foto al final de la galeria
+func appendProductImage(tx *gorm.DB, productID uuid.UUID, path string, primary bool) error {
+	images, err := loadImageList(tx, productID)
+	if err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		if img.Path == path {
+			// Ya estaba (mismo hash), solo la marcamos principal si la piden
+			if !primary {
+				return nil
+			}
+			images = removeImagePath(images, path)
+			break
+		}
+	}
+
+	if primary {
+		for i := range images {
+			images[i].Primary = false
+		}
+	}
+	images = append(images, ProductImageJSON{Path: path, Primary: primary || len(images) == 0})
+
+	return syncProductImages(tx, productID, images)
+}
+
+func removeImagePath(images []ProductImageJSON, path string) []ProductImageJSON {
+	out := images[:0]
+	for _, img := range images {
+		if img.Path != path {
+			out = append(out, img)
+		}
+	}
+	return out
+}
+
+// GetProductImages trae la galeria ordenada
+func GetProductImages(productID uuid.UUID) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	if err := database.DB.Where("product_id = ?", productID).Order("position ASC").Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product images: %w", err)
+	}
+	return images, nil
+}
+
+// ReorderProductImages cambia orden, textos alt y principal de la galeria
+func ReorderProductImages(access *StaffAccess, productID uuid.UUID, order []ImageOrderInput) ([]models.ProductImage, error) {
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := GetProductImages(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) != len(current) {
+		return nil, fmt.Errorf("all %d images must be listed", len(current))
+	}
+
+	byID := make(map[uuid.UUID]models.ProductImage)
+	for _, img := range current {
+		byID[img.ID] = img
+	}
+
+	images := make([]ProductImageJSON, 0, len(order))
+	for _, item := range order {
+		img, ok := byID[item.ID]
+		if !ok {
+			return nil, fmt.Errorf("image %s not found", item.ID)
+		}
+		delete(byID, item.ID)
+		images = append(images, ProductImageJSON{Path: img.Path, AltES: item.AltES, AltEN: item.AltEN, Primary: item.Primary})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := syncProductImages(tx, product.ID, images); err != nil {
+			return err
+		}
+		return bumpProductVersion(tx, product.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProductImages(product.ID)
+}
+
+// DeleteProductImage saca una foto de la galeria
+func DeleteProductImage(access *StaffAccess, productID, imageID uuid.UUID) error {
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return err
+	}
+
+	var image models.ProductImage
+	if err := database.DB.First(&image, "id = ? AND product_id = ?", imageID, product.ID).Error; err != nil {
+		return fmt.Errorf("image not found")
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		images, err := loadImageList(tx, product.ID)
+		if err != nil {
+			return err
+		}
+		if err := syncProductImages(tx, product.ID, removeImagePath(images, image.Path)); err != nil {
+			return err
+		}
+		return bumpProductVersion(tx, product.ID)
+	})
+}
+
+// bumpProductVersion sube la version pa que otros editores vean que hubo cambios
+func bumpProductVersion(tx *gorm.DB, productID uuid.UUID) error {
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// EnsureProductImages migra productos que solo tienen image_path a la galeria
+func EnsureProductImages() error {
+	var products []models.Product
+	if err := database.DB.
+		Where("image_path <> ''").
+		Where("NOT EXISTS (SELECT 1 FROM product_images pi WHERE pi.product_id = products.id)").
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products without images: %w", err)
+	}
+
+	for _, product := range products {
+		images := []ProductImageJSON{{Path: product.ImagePath, AltES: product.Name, Primary: true}}
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return syncProductImages(tx, product.ID, images)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
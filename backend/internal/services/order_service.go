@@ -2,10 +2,13 @@ package services
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/database"
 	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ShippingInfo es la info de envio sin misterio
@@ -32,20 +35,14 @@ func CreateOrderFromCart(cartID uuid.UUID, userID *uuid.UUID, sessionID *string,
 		return nil, fmt.Errorf("cart is empty")
 	}
 
-	// Calculamos total
-	total := 0.0
-	for _, item := range cartItems {
-		total += item.Product.Price * float64(item.Quantity)
-	}
-
-	// Creamos el pedido
+	// Creamos el pedido; los montos salen del motor de promos dentro de la transaccion
 	order := &models.Order{
 		ID:                 uuid.New(),
 		UserID:             userID,
 		SessionID:          sessionID,
-		TotalAmount:        total,
 		Status:             "pending",
 		PaymentStatus:      "pending",
+		Currency:           SettlementCurrency.Code,
 		ShippingName:       shipping.Name,
 		ShippingPhone:      shipping.Phone,
 		ShippingEmail:      shipping.Email,
@@ -56,40 +53,151 @@ func CreateOrderFromCart(cartID uuid.UUID, userID *uuid.UUID, sessionID *string,
 		ShippingNotes:      shipping.Notes,
 	}
 
-	if err := database.DB.Create(order).Error; err != nil {
-		return nil, fmt.Errorf("failed to create order: %w", err)
-	}
+	customer := customerKey(userID, sessionID, shipping.Email)
+
+	// Todo en una transaccion: pedido, items, descuentos, stock y limpiar carrito
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Primero bloqueamos producto y variante de cada linea; los precios salen de
+		// estas filas bloqueadas, asi el total cuadra con los items aunque cambien a mitad
+		requested := make(map[uuid.UUID]int)
+		for i := range cartItems {
+			cartItem := &cartItems[i]
+
+			// El producto (o su tienda) pudo salir de la vitrina despues de meterlo al carrito
+			var product models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Scopes(visibleProducts).
+				First(&product, "products.id = ?", cartItem.ProductID).Error; err != nil {
+				return fmt.Errorf("product not available: %s", cartItem.Product.Name)
+			}
+
+			// Bloqueamos la variante pa que dos pedidos no se coman el mismo stock
+			var variant *models.ProductVariant
+			if cartItem.VariantID != nil {
+				variant = &models.ProductVariant{}
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+					First(variant, "id = ? AND active = ?", *cartItem.VariantID, true).Error; err != nil {
+					return fmt.Errorf("variant for %s is no longer available", cartItem.Product.Name)
+				}
+			} else {
+				// Items viejos sin variante: la buscamos por talla
+				resolved, err := resolveVariant(tx.Clauses(clause.Locking{Strength: "UPDATE"}), cartItem.ProductID, nil, cartItem.Size, "")
+				if err != nil {
+					return fmt.Errorf("%s: %w", cartItem.Product.Name, err)
+				}
+				variant = resolved
+			}
+
+			// Dos lineas de la misma variante suman contra el mismo stock
+			requested[variant.ID] += cartItem.Quantity
+			if variant.Stock < requested[variant.ID] {
+				return fmt.Errorf("insufficient stock for %s (%s)", cartItem.Product.Name, variant.Size)
+			}
 
-	// Creamos los items del pedido
-	for _, cartItem := range cartItems {
-		orderItem := &models.OrderItem{
-			ID:        uuid.New(),
-			OrderID:   order.ID,
-			ProductID: cartItem.ProductID,
-			Quantity:  cartItem.Quantity,
-			Size:      cartItem.Size,
-			UnitPrice: cartItem.Product.Price,
+			cartItem.Product = product
+			cartItem.VariantID = &variant.ID
+			cartItem.Variant = variant
 		}
 
-		if err := database.DB.Create(orderItem).Error; err != nil {
-			return nil, fmt.Errorf("failed to create order item: %w", err)
+		coupons, err := cartCoupons(tx, cartID)
+		if err != nil {
+			return err
 		}
-	}
+		pricing, err := priceLines(tx, pricingLines(cartItems), coupons, customer, time.Now())
+		if err != nil {
+			return err
+		}
+		order.SubtotalAmount = pricing.Subtotal
+		order.DiscountAmount = pricing.Discount
+		order.TotalAmount = pricing.Total
+
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create order: %w", err)
+		}
+		if err := redeemPromotions(tx, order, pricing, customer); err != nil {
+			return err
+		}
+
+		for i, cartItem := range cartItems {
+			variant := cartItem.Variant
+			if err := tx.Model(variant).Update("stock", gorm.Expr("stock - ?", cartItem.Quantity)).Error; err != nil {
+				return fmt.Errorf("failed to update stock: %w", err)
+			}
+			if err := refreshProductStock(tx, cartItem.ProductID); err != nil {
+				return err
+			}
+			// El stock es parte del producto que edita el admin: sin esto un PUT con
+			// las variantes viejas pasaria el chequeo de version y devolveria lo vendido
+			if err := tx.Model(&models.Product{}).
+				Where("id = ?", cartItem.ProductID).
+				Update("version", gorm.Expr("version + 1")).Error; err != nil {
+				return fmt.Errorf("failed to update product version: %w", err)
+			}
+
+			orderItem := &models.OrderItem{
+				ID:        uuid.New(),
+				OrderID:   order.ID,
+				ProductID: cartItem.ProductID,
+				VariantID: &variant.ID,
+				Quantity:  cartItem.Quantity,
+				Size:      variant.Size,
+				Color:     variant.Color,
+				SKU:       variant.SKU,
+				UnitPrice: pricing.Lines[i].UnitPrice,
+			}
 
-	// Limpiamos el carrito
-	if err := ClearCart(cartID); err != nil {
+			if err := tx.Create(orderItem).Error; err != nil {
+				return fmt.Errorf("failed to create order item: %w", err)
+			}
+
+			// Desglose: una fila por promo por item
+			for _, discount := range pricing.Lines[i].Discounts {
+				row := &models.OrderDiscount{
+					ID:          uuid.New(),
+					OrderID:     order.ID,
+					OrderItemID: orderItem.ID,
+					PromotionID: discount.PromotionID,
+					Name:        discount.Name,
+					Code:        discount.Code,
+					Amount:      discount.Amount,
+				}
+				if err := tx.Create(row).Error; err != nil {
+					return fmt.Errorf("failed to save order discount: %w", err)
+				}
+			}
+		}
+
+		// Limpiamos el carrito y sus cupones
+		if err := tx.Delete(&models.CartItem{}, "cart_id = ?", cartID).Error; err != nil {
+			return fmt.Errorf("failed to clear cart: %w", err)
+		}
+		if err := tx.Delete(&models.CartCoupon{}, "cart_id = ?", cartID).Error; err != nil {
+			return fmt.Errorf("failed to clear cart coupons: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return order, nil
 }
 
+// CartItemUnitPrice devuelve el precio unitario del item (variante o producto)
+func CartItemUnitPrice(item models.CartItem) float64 {
+	if item.Variant != nil {
+		return item.Variant.EffectivePrice(item.Product.Price)
+	}
+	return item.Product.Price
+}
+
 // GetOrder trae pedido por id
 func GetOrder(orderID uuid.UUID) (*models.Order, error) {
 	var order models.Order
 	if err := database.DB.
 		Preload("OrderItems").
 		Preload("OrderItems.Product").
+		Preload("Discounts").
 		First(&order, "id = ?", orderID).Error; err != nil {
 		return nil, fmt.Errorf("order not found")
 	}
@@ -120,6 +228,7 @@ func GetOrdersByUser(userID uuid.UUID, page, limit int) ([]models.Order, int64,
 		Where("user_id = ?", userID).
 		Preload("OrderItems").
 		Preload("OrderItems.Product").
+		Preload("Discounts").
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -154,6 +263,7 @@ func GetOrdersBySession(sessionID string, page, limit int) ([]models.Order, int6
 		Where("session_id = ?", sessionID).
 		Preload("OrderItems").
 		Preload("OrderItems.Product").
+		Preload("Discounts").
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -185,9 +295,24 @@ func UpdatePaymentStatus(orderID uuid.UUID, paymentStatus string) (*models.Order
 		return nil, fmt.Errorf("order not found")
 	}
 
-	if err := database.DB.Model(&order).Update("payment_status", paymentStatus).Error; err != nil {
-		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Condicionado al estado anterior: si pagan dos veces, la promo cuenta una sola
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND payment_status <> ?", order.ID, paymentStatus).
+			Update("payment_status", paymentStatus)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update payment status: %w", result.Error)
+		}
+		// Recien con el pago completo el pedido gasta los cupones que uso
+		if paymentStatus == "completed" && result.RowsAffected > 0 {
+			return confirmPromotions(tx, order.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	order.PaymentStatus = paymentStatus
 	return &order, nil
 }
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PriceScheduleInput es un precio programado pedido desde el admin
+type PriceScheduleInput struct {
+	Price          float64
+	CompareAtPrice *float64
+	StartsAt       time.Time
+	EndsAt         *time.Time
+}
+
+// recordPrice escribe una fila del historial. Se llama en cada cambio de precio,
+// asi PriceAt puede reconstruir cualquier fecha.
+func recordPrice(tx *gorm.DB, productID uuid.UUID, price float64, compareAt *float64, source string, scheduleID *uuid.UUID, at time.Time) error {
+	entry := &models.PriceHistory{
+		ID:             uuid.New(),
+		ProductID:      productID,
+		Price:          price,
+		CompareAtPrice: compareAt,
+		Source:         source,
+		ScheduleID:     scheduleID,
+		EffectiveAt:    at,
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to record price history: %w", err)
+	}
+	return nil
+}
+
+// validateCompareAt revisa que el "antes" sea mayor que el precio
+func validateCompareAt(price float64, compareAt *float64) error {
+	if compareAt != nil && *compareAt <= price {
+		return fmt.Errorf("compare_at_price must be greater than price")
+	}
+	return nil
+}
+
+// storeActiveSales trae las rebajas corriendo de una tienda, por producto
+func storeActiveSales(db *gorm.DB, storeID uuid.UUID) (map[uuid.UUID]*models.PriceSchedule, error) {
+	var schedules []models.PriceSchedule
+	if err := db.
+		Joins("JOIN products ON products.id = price_schedules.product_id").
+		Where("products.store_id = ? AND price_schedules.status = ?", storeID, models.PriceScheduleActive).
+		Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch price schedules: %w", err)
+	}
+
+	sales := make(map[uuid.UUID]*models.PriceSchedule, len(schedules))
+	for i := range schedules {
+		sales[schedules[i].ProductID] = &schedules[i]
+	}
+	return sales, nil
+}
+
+// setProductPrice cambia el precio vivo del producto y lo deja en el historial
+func setProductPrice(tx *gorm.DB, productID uuid.UUID, price float64, compareAt *float64, source string, scheduleID *uuid.UUID, at time.Time) error {
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"price":            price,
+		"compare_at_price": compareAt,
+		"version":          gorm.Expr("version + 1"),
+		"updated_at":       at,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update price: %w", err)
+	}
+	return recordPrice(tx, productID, price, compareAt, source, scheduleID, at)
+}
+
+// PriceAt devuelve el precio que tenia el producto en ese momento (pa reportes)
+func PriceAt(productID uuid.UUID, at time.Time) (*models.PriceHistory, error) {
+	var entry models.PriceHistory
+	if err := database.DB.
+		Where("product_id = ? AND effective_at <= ?", productID, at).
+		Order("effective_at DESC, created_at DESC").
+		First(&entry).Error; err != nil {
+		return nil, fmt.Errorf("no price recorded at that date")
+	}
+	return &entry, nil
+}
+
+// GetPriceHistory trae el historial completo del producto, lo mas nuevo primero
+func GetPriceHistory(access *StaffAccess, productID uuid.UUID) ([]models.PriceHistory, error) {
+	if _, err := GetAdminProduct(access, productID); err != nil {
+		return nil, err
+	}
+
+	var history []models.PriceHistory
+	if err := database.DB.
+		Where("product_id = ?", productID).
+		Order("effective_at DESC, created_at DESC").
+		Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch price history: %w", err)
+	}
+	return history, nil
+}
+
+// ListPriceSchedules trae los precios programados del producto
+func ListPriceSchedules(access *StaffAccess, productID uuid.UUID) ([]models.PriceSchedule, error) {
+	if _, err := GetAdminProduct(access, productID); err != nil {
+		return nil, err
+	}
+
+	var schedules []models.PriceSchedule
+	if err := database.DB.Where("product_id = ?", productID).Order("starts_at DESC").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch price schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// CreatePriceSchedule programa un precio; no puede pisarse con otro pendiente o activo
+func CreatePriceSchedule(access *StaffAccess, productID uuid.UUID, in PriceScheduleInput) (*models.PriceSchedule, error) {
+	if in.Price <= 0 {
+		return nil, fmt.Errorf("price must be greater than 0")
+	}
+	if err := validateCompareAt(in.Price, in.CompareAtPrice); err != nil {
+		return nil, err
+	}
+	if in.StartsAt.IsZero() {
+		return nil, fmt.Errorf("starts_at is required")
+	}
+	if in.EndsAt != nil && !in.EndsAt.After(in.StartsAt) {
+		return nil, fmt.Errorf("ends_at must be after starts_at")
+	}
+	if in.EndsAt != nil && !in.EndsAt.After(time.Now()) {
+		return nil, fmt.Errorf("ends_at must be in the future")
+	}
+
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &models.PriceSchedule{
+		ID:             uuid.New(),
+		ProductID:      product.ID,
+		Price:          in.Price,
+		CompareAtPrice: in.CompareAtPrice,
+		StartsAt:       in.StartsAt,
+		EndsAt:         in.EndsAt,
+		Status:         models.PriceSchedulePending,
+	}
+	if access.UserID != uuid.Nil {
+		schedule.CreatedBy = &access.UserID
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Bloqueamos el producto pa que dos rebajas creadas a la vez no pasen las dos el chequeo
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&models.Product{}, "id = ?", product.ID).Error; err != nil {
+			return fmt.Errorf("product not found")
+		}
+
+		// Dos ventanas se pisan si cada una empieza antes de que la otra termine
+		query := tx.Model(&models.PriceSchedule{}).
+			Where("product_id = ? AND status IN ?", product.ID, []string{models.PriceSchedulePending, models.PriceScheduleActive}).
+			Where("(ends_at IS NULL OR ends_at > ?)", in.StartsAt)
+		if in.EndsAt != nil {
+			query = query.Where("starts_at < ?", *in.EndsAt)
+		}
+		var overlapping int64
+		if err := query.Count(&overlapping).Error; err != nil {
+			return fmt.Errorf("failed to check price schedules: %w", err)
+		}
+		if overlapping > 0 {
+			return fmt.Errorf("another price schedule overlaps those dates")
+		}
+
+		if err := tx.Create(schedule).Error; err != nil {
+			return fmt.Errorf("failed to create price schedule: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Si ya arranco lo aplicamos de una, sin esperar al scheduler
+	if !schedule.StartsAt.After(time.Now()) {
+		if _, _, err := ApplyPriceSchedules(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return getPriceSchedule(schedule.ID)
+}
+
+func getPriceSchedule(scheduleID uuid.UUID) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	if err := database.DB.First(&schedule, "id = ?", scheduleID).Error; err != nil {
+		return nil, fmt.Errorf("price schedule not found")
+	}
+	return &schedule, nil
+}
+
+// CancelPriceSchedule cancela un precio programado; si ya estaba corriendo, restaura el precio
+func CancelPriceSchedule(access *StaffAccess, productID, scheduleID uuid.UUID) (*models.PriceSchedule, error) {
+	if _, err := GetAdminProduct(access, productID); err != nil {
+		return nil, err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var schedule models.PriceSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&schedule, "id = ? AND product_id = ?", scheduleID, productID).Error; err != nil {
+			return fmt.Errorf("price schedule not found")
+		}
+
+		now := time.Now()
+		switch schedule.Status {
+		case models.PriceSchedulePending:
+		case models.PriceScheduleActive:
+			if err := endPriceSchedule(tx, &schedule, now); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("price schedule is already %s", schedule.Status)
+		}
+
+		return tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":     models.PriceScheduleCancelled,
+			"ended_at":   now,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getPriceSchedule(scheduleID)
+}
+
+// startPriceSchedule pone el precio programado y guarda el regular pa despues
+func startPriceSchedule(tx *gorm.DB, schedule *models.PriceSchedule, now time.Time) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", schedule.ProductID).Error; err != nil {
+		return fmt.Errorf("product not found")
+	}
+
+	original := product.Price
+	compareAt := schedule.CompareAtPrice
+	if compareAt == nil && schedule.Price < original {
+		compareAt = &original
+	}
+
+	if err := setProductPrice(tx, product.ID, schedule.Price, compareAt, models.PriceSourceSchedule, &schedule.ID, now); err != nil {
+		return err
+	}
+	return tx.Model(schedule).Updates(map[string]interface{}{
+		"status":              models.PriceScheduleActive,
+		"original_price":      original,
+		"original_compare_at": product.CompareAtPrice,
+		"applied_at":          now,
+		"updated_at":          now,
+	}).Error
+}
+
+// overridePriceSchedule marca la rebaja corriendo del producto como pisada a mano
+func overridePriceSchedule(tx *gorm.DB, productID uuid.UUID) error {
+	if err := tx.Model(&models.PriceSchedule{}).
+		Where("product_id = ? AND status = ?", productID, models.PriceScheduleActive).
+		Update("manual_override", true).Error; err != nil {
+		return fmt.Errorf("failed to update price schedule: %w", err)
+	}
+	return nil
+}
+
+// endPriceSchedule vuelve al precio regular. Si alguien cambio el precio a mano
+// mientras tanto (ManualOverride), se respeta lo que puso.
+func endPriceSchedule(tx *gorm.DB, schedule *models.PriceSchedule, now time.Time) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, "id = ?", schedule.ProductID).Error; err != nil {
+		return fmt.Errorf("product not found")
+	}
+
+	if !schedule.ManualOverride && schedule.OriginalPrice != nil {
+		if err := setProductPrice(tx, product.ID, *schedule.OriginalPrice, schedule.OriginalCompareAt, models.PriceSourceSchedule, &schedule.ID, now); err != nil {
+			return err
+		}
+	}
+	return tx.Model(schedule).Updates(map[string]interface{}{
+		"status":     models.PriceScheduleFinished,
+		"ended_at":   now,
+		"updated_at": now,
+	}).Error
+}
+
+// ApplyPriceSchedules termina las rebajas vencidas y arranca las que tocan.
+// Primero termina, asi una rebaja puede empezar justo cuando otra acaba.
+func ApplyPriceSchedules(now time.Time) (started, ended int, err error) {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var due []models.PriceSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND ends_at IS NOT NULL AND ends_at <= ?", models.PriceScheduleActive, now).
+			Order("ends_at ASC").
+			Find(&due).Error; err != nil {
+			return fmt.Errorf("failed to fetch ending price schedules: %w", err)
+		}
+		for i := range due {
+			if err := endPriceSchedule(tx, &due[i], now); err != nil {
+				return err
+			}
+			ended++
+		}
+
+		var pending []models.PriceSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND starts_at <= ?", models.PriceSchedulePending, now).
+			Order("starts_at ASC").
+			Find(&pending).Error; err != nil {
+			return fmt.Errorf("failed to fetch pending price schedules: %w", err)
+		}
+		for i := range pending {
+			schedule := &pending[i]
+			if schedule.EndsAt != nil && !schedule.EndsAt.After(now) {
+				if err := tx.Model(schedule).Updates(map[string]interface{}{
+					"status":     models.PriceScheduleExpired,
+					"updated_at": now,
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := startPriceSchedule(tx, schedule, now); err != nil {
+				return err
+			}
+			started++
+		}
+		return nil
+	})
+	return started, ended, err
+}
+
+// StartPriceScheduler revisa los precios programados cada interval; devuelve la funcion pa pararlo
+func StartPriceScheduler(interval time.Duration) func() {
+	run := func() {
+		started, ended, err := ApplyPriceSchedules(time.Now())
+		if err != nil {
+			log.Printf("Warning: failed to apply price schedules: %v", err)
+			return
+		}
+		if started > 0 || ended > 0 {
+			log.Printf("✓ Price schedules: %d started, %d ended", started, ended)
+		}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
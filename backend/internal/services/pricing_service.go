@@ -0,0 +1,378 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// AppliedDiscount es cuanto desconto una promo (en una linea o en total)
+type AppliedDiscount struct {
+	PromotionID uuid.UUID
+	Name        string
+	Code        string
+	Amount      float64
+}
+
+// PricingLine es un item del carrito con su precio y sus descuentos
+type PricingLine struct {
+	ItemID     uuid.UUID
+	ProductID  uuid.UUID
+	StoreID    uuid.UUID
+	CategoryID *uuid.UUID
+	Quantity   int
+	UnitPrice  float64
+	Subtotal   float64
+	Discounts  []AppliedDiscount
+	Total      float64
+}
+
+// CouponStatus dice si un cupon del carrito se aplico y si no, por que
+type CouponStatus struct {
+	Code    string
+	Applied bool
+	Reason  string
+}
+
+// CartPricing es el resultado completo del motor de promos
+type CartPricing struct {
+	Lines     []PricingLine
+	Subtotal  float64
+	Discount  float64
+	Total     float64
+	Discounts []AppliedDiscount // total por promo, en el orden en que se aplicaron
+	Coupons   []CouponStatus
+
+	applied []models.Promotion // las que quedaron, pa registrar el uso al crear el pedido
+}
+
+// promoCandidate es una promo que aplica a ciertas lineas del carrito
+type promoCandidate struct {
+	promo models.Promotion
+	lines []int
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// customerKey identifica al cliente pa los limites por cliente:
+// user si esta logueado, si no el correo del envio, si no la sesion
+func customerKey(userID *uuid.UUID, sessionID *string, email string) string {
+	if userID != nil {
+		return "user:" + userID.String()
+	}
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		return "email:" + email
+	}
+	if sessionID != nil && *sessionID != "" {
+		return "session:" + *sessionID
+	}
+	return ""
+}
+
+// pricingLines arma las lineas desde los items del carrito (con Product y Variant cargados)
+func pricingLines(items []models.CartItem) []PricingLine {
+	lines := make([]PricingLine, 0, len(items))
+	for _, item := range items {
+		unit := CartItemUnitPrice(item)
+		subtotal := roundMoney(unit * float64(item.Quantity))
+		lines = append(lines, PricingLine{
+			ItemID:     item.ID,
+			ProductID:  item.ProductID,
+			StoreID:    item.Product.StoreID,
+			CategoryID: item.Product.CategoryID,
+			Quantity:   item.Quantity,
+			UnitPrice:  unit,
+			Subtotal:   subtotal,
+			Total:      subtotal,
+		})
+	}
+	return lines
+}
+
+// categoryAncestors devuelve, por categoria, ella misma y todos sus padres
+func categoryAncestors(db *gorm.DB, storeIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	var categories []models.Category
+	if err := db.Where("store_id IN ?", storeIDs).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %w", err)
+	}
+
+	parents := make(map[uuid.UUID]*uuid.UUID, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+
+	ancestors := make(map[uuid.UUID][]uuid.UUID, len(categories))
+	for id := range parents {
+		seen := make(map[uuid.UUID]bool)
+		for current := &id; current != nil && !seen[*current]; current = parents[*current] {
+			seen[*current] = true
+			ancestors[id] = append(ancestors[id], *current)
+		}
+	}
+	return ancestors, nil
+}
+
+// promotionMatches dice si la promo cubre la linea segun su alcance
+func promotionMatches(promo models.Promotion, line PricingLine, ancestors map[uuid.UUID][]uuid.UUID) bool {
+	if promo.StoreID != line.StoreID {
+		return false
+	}
+	switch promo.Scope {
+	case models.PromotionScopeProduct:
+		return promo.ProductID != nil && *promo.ProductID == line.ProductID
+	case models.PromotionScopeCategory:
+		if promo.CategoryID == nil || line.CategoryID == nil {
+			return false
+		}
+		for _, id := range ancestors[*line.CategoryID] {
+			if id == *promo.CategoryID {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// priceLines es el motor: valida cada promo contra el carrito, resuelve el
+// stacking y reparte los descuentos linea por linea.
+//
+// Reglas de stacking: las promos combinables se aplican todas, en orden de
+// prioridad, cada una sobre lo que queda de la linea. Una promo no combinable
+// va sola; gana lo que mas descuente (a igual descuento ganan las combinables).
+func priceLines(db *gorm.DB, lines []PricingLine, coupons []models.Promotion, customer string, now time.Time) (*CartPricing, error) {
+	pricing := &CartPricing{Lines: lines, Coupons: []CouponStatus{}, Discounts: []AppliedDiscount{}}
+	for _, line := range lines {
+		pricing.Subtotal += line.Subtotal
+	}
+	pricing.Subtotal = roundMoney(pricing.Subtotal)
+	pricing.Total = pricing.Subtotal
+	if len(lines) == 0 {
+		for _, coupon := range coupons {
+			pricing.Coupons = append(pricing.Coupons, CouponStatus{Code: *coupon.Code, Reason: "cart is empty"})
+		}
+		return pricing, nil
+	}
+
+	seenStores := make(map[uuid.UUID]bool)
+	var storeIDs []uuid.UUID
+	for _, line := range lines {
+		if !seenStores[line.StoreID] {
+			seenStores[line.StoreID] = true
+			storeIDs = append(storeIDs, line.StoreID)
+		}
+	}
+
+	// Promos automaticas (sin codigo) de las tiendas del carrito
+	var promos []models.Promotion
+	if err := db.
+		Where("store_id IN ? AND code IS NULL AND active = ?", storeIDs, true).
+		Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Find(&promos).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch promotions: %w", err)
+	}
+	promos = append(promos, coupons...)
+
+	ancestors, err := categoryAncestors(db, storeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	reasons := make(map[uuid.UUID]string)
+	var stackable, exclusive []promoCandidate
+	for _, promo := range promos {
+		reason, err := promotionUnavailable(db, promo, customer, now)
+		if err != nil {
+			return nil, err
+		}
+
+		candidate := promoCandidate{promo: promo}
+		eligible := 0.0
+		for i, line := range lines {
+			if promotionMatches(promo, line, ancestors) {
+				candidate.lines = append(candidate.lines, i)
+				eligible += line.Subtotal
+			}
+		}
+		switch {
+		case reason != "":
+		case len(candidate.lines) == 0:
+			reason = "no products in the cart qualify for this coupon"
+		case eligible < promo.MinSpend:
+			reason = fmt.Sprintf("minimum spend of %.2f not reached", promo.MinSpend)
+		}
+		if reason != "" {
+			reasons[promo.ID] = reason
+			continue
+		}
+
+		if promo.Stackable {
+			stackable = append(stackable, candidate)
+		} else {
+			exclusive = append(exclusive, candidate)
+		}
+	}
+
+	// Nos quedamos con la combinacion que mas descuenta
+	best, bestTotal := applyPromotions(lines, stackable)
+	bestSet := stackable
+	for _, candidate := range exclusive {
+		result, total := applyPromotions(lines, []promoCandidate{candidate})
+		if total > bestTotal {
+			best, bestTotal, bestSet = result, total, []promoCandidate{candidate}
+		}
+	}
+
+	chosen := make(map[uuid.UUID]bool)
+	for _, candidate := range bestSet {
+		chosen[candidate.promo.ID] = true
+	}
+	for _, candidate := range append(append([]promoCandidate{}, stackable...), exclusive...) {
+		if !chosen[candidate.promo.ID] {
+			reasons[candidate.promo.ID] = "cannot be combined with the other promotions in the cart"
+		}
+	}
+
+	// Pasamos el resultado a las lineas y al resumen por promo
+	totals := make(map[uuid.UUID]*AppliedDiscount)
+	for i := range pricing.Lines {
+		line := &pricing.Lines[i]
+		line.Discounts = best[i]
+		for _, d := range line.Discounts {
+			line.Total -= d.Amount
+			if totals[d.PromotionID] == nil {
+				totals[d.PromotionID] = &AppliedDiscount{PromotionID: d.PromotionID, Name: d.Name, Code: d.Code}
+			}
+			totals[d.PromotionID].Amount += d.Amount
+		}
+		line.Total = roundMoney(line.Total)
+	}
+	for _, candidate := range sortedCandidates(bestSet) {
+		if d := totals[candidate.promo.ID]; d != nil && d.Amount > 0 {
+			d.Amount = roundMoney(d.Amount)
+			pricing.Discounts = append(pricing.Discounts, *d)
+			pricing.Discount += d.Amount
+			pricing.applied = append(pricing.applied, candidate.promo)
+		} else if candidate.promo.Code != nil {
+			reasons[candidate.promo.ID] = "nothing left to discount"
+		}
+	}
+	pricing.Discount = roundMoney(pricing.Discount)
+	pricing.Total = roundMoney(pricing.Subtotal - pricing.Discount)
+
+	for _, coupon := range coupons {
+		status := CouponStatus{Code: *coupon.Code, Reason: reasons[coupon.ID]}
+		status.Applied = status.Reason == "" && totals[coupon.ID] != nil
+		pricing.Coupons = append(pricing.Coupons, status)
+	}
+
+	return pricing, nil
+}
+
+// promotionUnavailable revisa ventana y limites de uso; "" si se puede usar
+func promotionUnavailable(db *gorm.DB, promo models.Promotion, customer string, now time.Time) (string, error) {
+	if !promo.ActiveAt(now) {
+		return "coupon is not active", nil
+	}
+	if promo.UsageLimit > 0 && promo.UsageCount >= promo.UsageLimit {
+		return "coupon has reached its usage limit", nil
+	}
+	if promo.PerCustomerLimit > 0 && customer != "" {
+		var used int64
+		// Solo cuentan los pedidos pagados, igual que usage_count
+		if err := db.Model(&models.PromotionRedemption{}).
+			Joins("JOIN orders ON orders.id = promotion_redemptions.order_id").
+			Where("promotion_redemptions.promotion_id = ? AND promotion_redemptions.customer_key = ? AND orders.payment_status = ?", promo.ID, customer, "completed").
+			Count(&used).Error; err != nil {
+			return "", fmt.Errorf("failed to check promotion usage: %w", err)
+		}
+		if int(used) >= promo.PerCustomerLimit {
+			return "you already used this coupon", nil
+		}
+	}
+	return "", nil
+}
+
+// sortedCandidates ordena por prioridad (mayor primero) y luego por nombre, pa que sea estable
+func sortedCandidates(candidates []promoCandidate) []promoCandidate {
+	sorted := append([]promoCandidate{}, candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].promo.Priority != sorted[j].promo.Priority {
+			return sorted[i].promo.Priority > sorted[j].promo.Priority
+		}
+		return sorted[i].promo.Name < sorted[j].promo.Name
+	})
+	return sorted
+}
+
+// applyPromotions aplica las promos en orden sobre lo que va quedando de cada linea.
+// Los montos fijos se reparten proporcional entre las lineas que entran.
+func applyPromotions(lines []PricingLine, candidates []promoCandidate) ([][]AppliedDiscount, float64) {
+	remaining := make([]float64, len(lines))
+	for i, line := range lines {
+		remaining[i] = line.Subtotal
+	}
+	result := make([][]AppliedDiscount, len(lines))
+	total := 0.0
+
+	for _, candidate := range sortedCandidates(candidates) {
+		promo := candidate.promo
+		code := ""
+		if promo.Code != nil {
+			code = *promo.Code
+		}
+
+		amounts := make(map[int]float64)
+		switch promo.DiscountType {
+		case models.DiscountPercent:
+			for _, i := range candidate.lines {
+				amounts[i] = roundMoney(remaining[i] * promo.Value / 100)
+			}
+		case models.DiscountFixed:
+			base := 0.0
+			for _, i := range candidate.lines {
+				base += remaining[i]
+			}
+			discount := roundMoney(math.Min(promo.Value, base))
+			left := discount
+			for n, i := range candidate.lines {
+				if base <= 0 {
+					break
+				}
+				share := roundMoney(discount * remaining[i] / base)
+				if n == len(candidate.lines)-1 {
+					share = left // lo que sobra del redondeo va a la ultima
+				}
+				share = math.Min(share, remaining[i])
+				amounts[i] = share
+				left = roundMoney(left - share)
+			}
+		}
+
+		for _, i := range candidate.lines {
+			amount := amounts[i]
+			if amount <= 0 {
+				continue
+			}
+			remaining[i] = roundMoney(remaining[i] - amount)
+			total += amount
+			result[i] = append(result[i], AppliedDiscount{
+				PromotionID: promo.ID,
+				Name:        promo.Name,
+				Code:        code,
+				Amount:      amount,
+			})
+		}
+	}
+
+	return result, roundMoney(total)
+}
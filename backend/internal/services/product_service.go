@@ -1,79 +1,64 @@
 package services
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
 	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
 )
 
 // ProductJSON es la forma del producto en el JSON
 type ProductJSON struct {
-	Category       string   `json:"category"`
-	Name           string   `json:"name"`
-	Description    string   `json:"description"`
-	Price          float64  `json:"price"`
+	Key         string  `json:"key"`      // estable: si cambia el nombre el producto sigue siendo el mismo
+	Category    string  `json:"category"` // nombre o path con niveles: "Vestidos > Largos"
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+
+	// Traducciones al ingles; vacias = se muestra el espanol.
+	// category_en va con los mismos niveles que category: "Dresses > Long"
+	NameEN        string `json:"name_en,omitempty"`
+	DescriptionEN string `json:"description_en,omitempty"`
+	CategoryEN    string `json:"category_en,omitempty"`
+
 	Sizes          []string `json:"sizes"`
 	AvailableUnits int      `json:"available_units"`
-	Image          string   `json:"image"`
+	Image          string   `json:"image"` // formato viejo, una sola foto
+
+	// Variants es opcional; si no viene, se arman desde sizes y available_units
+	Variants []ProductVariantJSON `json:"variants"`
+
+	// Images es la galeria ordenada; si no viene se usa Image
+	Images []ProductImageJSON `json:"images"`
+}
+
+// publishedProducts deja los publicados y los programados cuya hora ya llego
+// (el publisher los pasa a published, pero no hay que esperarlo)
+func publishedProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("(products.status = ? OR (products.status = ? AND products.published_at <= ?))",
+		models.ProductPublished, models.ProductScheduled, time.Now())
+}
+
+// visibleProducts deja solo productos publicos: publicados y de tiendas activas
+func visibleProducts(db *gorm.DB) *gorm.DB {
+	return db.Scopes(publishedProducts).
+		Where("products.store_id NOT IN (SELECT id FROM stores WHERE archived_at IS NOT NULL)")
+}
+
+// resolvableProducts es pa la ficha por id o slug: lo publicado mas lo archivado,
+// que sigue abriendo desde el historial de pedidos. Borradores no salen.
+func resolvableProducts(db *gorm.DB) *gorm.DB {
+	return db.Where("(products.status IN ? OR (products.status = ? AND products.published_at <= ?))",
+		[]string{models.ProductPublished, models.ProductArchived}, models.ProductScheduled, time.Now())
 }
 
-// LoadProductsFromJSON carga productos desde un archivo JSON, todo casero
-func LoadProductsFromJSON(storeID uuid.UUID, storeName, jsonPath string) error {
-	// Leemos el archivo JSON
-	data, err := ioutil.ReadFile(jsonPath)
-	if err != nil {
-		return fmt.Errorf("failed to read JSON file: %w", err)
-	}
-
-	var products []ProductJSON
-	if err := json.Unmarshal(data, &products); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	// Insertamos o actualizamos productos
-	for _, p := range products {
-		sizesJSON, _ := json.Marshal(p.Sizes)
-		
-		// Revisamos si el producto ya existe
-		var existingProduct models.Product
-		err := database.DB.Where("store_id = ? AND name = ?", storeID, p.Name).First(&existingProduct).Error
-
-		if err == nil {
-			// Si existe, lo actualizamos
-			existingProduct.Description = p.Description
-			existingProduct.Price = p.Price
-			existingProduct.AvailableUnits = p.AvailableUnits
-			existingProduct.ImagePath = p.Image
-			existingProduct.Sizes = sizesJSON
-			
-			if err := database.DB.Save(&existingProduct).Error; err != nil {
-				fmt.Printf("Failed to update product %s: %v\n", p.Name, err)
-			}
-		} else {
-			// Producto nuevo
-			product := &models.Product{
-				ID:             uuid.New(),
-				StoreID:        storeID,
-				Name:           p.Name,
-				Description:    p.Description,
-				Category:       p.Category,
-				Price:          p.Price,
-				AvailableUnits: p.AvailableUnits,
-				ImagePath:      p.Image,
-				Sizes:          sizesJSON,
-			}
-
-			if err := database.DB.Create(product).Error; err != nil {
-				fmt.Printf("Failed to create product %s: %v\n", p.Name, err)
-			}
-		}
-	}
-
-	return nil
+// orderedImages deja la galeria en el orden que eligieron
+func orderedImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
 }
 
 // GetAllProducts trae productos con filtros opcionales
@@ -89,16 +74,16 @@ func GetAllProducts(store, category string, minPrice, maxPrice float64, page, li
 	}
 
 	offset := (page - 1) * limit
-	query := database.DB
+	query := database.DB.Scopes(visibleProducts)
 
-	// Filtros opcionales
+	// Filtros opcionales; la tienda va por slug o por nombre (links viejos)
 	if store != "" {
 		query = query.Joins("JOIN stores ON products.store_id = stores.id").
-			Where("stores.name = ?", store)
+			Where("stores.slug = ? OR stores.name = ?", utils.Slugify(store), store)
 	}
 
 	if category != "" {
-		query = query.Where("category = ?", category)
+		query = query.Scopes(inCategory(category))
 	}
 
 	if minPrice > 0 {
@@ -117,6 +102,7 @@ func GetAllProducts(store, category string, minPrice, maxPrice float64, page, li
 	// Productos paginados
 	if err := query.
 		Preload("Store").
+		Preload("Images", orderedImages).
 		Offset(offset).
 		Limit(limit).
 		Find(&products).Error; err != nil {
@@ -126,10 +112,18 @@ func GetAllProducts(store, category string, minPrice, maxPrice float64, page, li
 	return products, total, nil
 }
 
+// productDetail carga lo que necesita la pagina de producto
+func productDetail(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Store").
+		Preload("Variants", "active = ?", true).
+		Preload("Images", orderedImages)
+}
+
 // GetProductByID trae producto por ID
 func GetProductByID(productID uuid.UUID) (*models.Product, error) {
 	var product models.Product
-	if err := database.DB.Preload("Store").First(&product, "id = ?", productID).Error; err != nil {
+	if err := database.DB.Scopes(productDetail, resolvableProducts).First(&product, "id = ?", productID).Error; err != nil {
 		return nil, fmt.Errorf("product not found")
 	}
 	return &product, nil
@@ -150,14 +144,16 @@ func GetProductsByStore(storeID uuid.UUID, page, limit int) ([]models.Product, i
 	offset := (page - 1) * limit
 
 	// Total de productos
-	if err := database.DB.Where("store_id = ?", storeID).Model(&models.Product{}).Count(&total).Error; err != nil {
+	if err := database.DB.Scopes(visibleProducts).Where("store_id = ?", storeID).Model(&models.Product{}).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count products: %w", err)
 	}
 
 	// Productos paginados
 	if err := database.DB.
+		Scopes(visibleProducts).
 		Where("store_id = ?", storeID).
 		Preload("Store").
+		Preload("Images", orderedImages).
 		Offset(offset).
 		Limit(limit).
 		Find(&products).Error; err != nil {
@@ -167,7 +163,7 @@ func GetProductsByStore(storeID uuid.UUID, page, limit int) ([]models.Product, i
 	return products, total, nil
 }
 
-// GetProductsByCategory trae productos por categoria
+// GetProductsByCategory trae productos por categoria (slug o nombre, con subcategorias)
 func GetProductsByCategory(category string, page, limit int) ([]models.Product, int64, error) {
 	var products []models.Product
 	var total int64
@@ -182,14 +178,15 @@ func GetProductsByCategory(category string, page, limit int) ([]models.Product,
 	offset := (page - 1) * limit
 
 	// Total de productos
-	if err := database.DB.Where("category = ?", category).Model(&models.Product{}).Count(&total).Error; err != nil {
+	if err := database.DB.Scopes(visibleProducts, inCategory(category)).Model(&models.Product{}).Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count products: %w", err)
 	}
 
 	// Productos paginados
 	if err := database.DB.
-		Where("category = ?", category).
+		Scopes(visibleProducts, inCategory(category)).
 		Preload("Store").
+		Preload("Images", orderedImages).
 		Offset(offset).
 		Limit(limit).
 		Find(&products).Error; err != nil {
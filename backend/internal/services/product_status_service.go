@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTransition sale cuando el estado actual no deja pasar al pedido
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// validateNewProductStatus revisa el estado con el que se crea un producto
+func validateNewProductStatus(in *ProductInput, now time.Time) error {
+	if in.Status == "" {
+		in.Status = models.ProductPublished
+	}
+
+	switch in.Status {
+	case models.ProductDraft:
+		in.PublishedAt = nil // la fecha se pone al publicar
+	case models.ProductScheduled:
+		if in.PublishedAt == nil || !in.PublishedAt.After(now) {
+			return fmt.Errorf("published_at must be in the future to schedule a product")
+		}
+	case models.ProductPublished:
+	default:
+		return fmt.Errorf("status must be draft, scheduled or published")
+	}
+	return nil
+}
+
+// SetProductStatus mueve el producto a otro estado si la transicion es valida.
+// publishAt solo aplica pa scheduled.
+func SetProductStatus(access *StaffAccess, productID uuid.UUID, version int, status string, publishAt *time.Time) (*models.Product, error) {
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !models.ValidProductStatus(status) {
+		return nil, fmt.Errorf("status must be draft, scheduled, published or archived")
+	}
+	if !models.CanTransitionProduct(product.Status, status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, product.Status, status)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"archived_at": nil,
+		// Lo que archiva el staff no lo revive el sync del catalogo
+		"archived_by_sync": false,
+		"version":          gorm.Expr("version + 1"),
+		"updated_at":       now,
+	}
+
+	switch status {
+	case models.ProductScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return nil, fmt.Errorf("publish_at must be in the future to schedule a product")
+		}
+		updates["published_at"] = *publishAt
+	case models.ProductPublished:
+		// Si sale de borrador o lo adelantan, se publica ya y cuenta como nuevo desde hoy
+		if product.Status == models.ProductDraft || product.PublishedAt == nil || product.PublishedAt.After(now) {
+			updates["published_at"] = now
+		}
+	case models.ProductArchived:
+		updates["archived_at"] = now
+	}
+
+	result := database.DB.Model(&models.Product{}).
+		Where("id = ? AND version = ?", product.ID, version).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update product: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	// Volvio a la vitrina: puede haber gente esperando el stock que ya tenia
+	if status == models.ProductPublished {
+		if err := resetRestockWatermarks(database.DB, []uuid.UUID{product.ID}); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		notifyBackInStockAsync()
+	}
+
+	return GetAdminProduct(access, product.ID)
+}
+
+// PublishScheduledProducts pasa a published los programados cuya hora ya llego
+func PublishScheduledProducts(now time.Time) (int64, error) {
+	var result *gorm.DB
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		due := tx.Model(&models.Product{}).
+			Select("id").
+			Where("status = ? AND published_at <= ?", models.ProductScheduled, now)
+		if err := resetRestockWatermarks(tx, due); err != nil {
+			return err
+		}
+
+		result = tx.Model(&models.Product{}).
+			Where("status = ? AND published_at <= ?", models.ProductScheduled, now).
+			Updates(map[string]interface{}{
+				"status":     models.ProductPublished,
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": now,
+			})
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish scheduled products: %w", err)
+	}
+
+	// Igual que al publicar a mano: quien esperaba stock de estos ya puede comprar
+	if result.RowsAffected > 0 {
+		notifyBackInStockAsync()
+	}
+	return result.RowsAffected, nil
+}
+
+// StartProductPublisher revisa cada interval los productos programados.
+// Devuelve una funcion pa pararlo.
+func StartProductPublisher(interval time.Duration) func() {
+	run := func() {
+		published, err := PublishScheduledProducts(time.Now())
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		if published > 0 {
+			log.Printf("✓ Published %d scheduled products", published)
+		}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
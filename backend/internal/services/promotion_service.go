@@ -0,0 +1,399 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPromotionInUse sale al borrar una promo que ya se uso en pedidos
+var ErrPromotionInUse = errors.New("promotion was already used in orders, deactivate it instead")
+
+// couponCodePattern: mayusculas, numeros, guion y guion bajo
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+// PromotionInput son los campos editables de una promo desde el admin
+type PromotionInput struct {
+	StoreID          uuid.UUID
+	Name             string
+	Description      string
+	Code             string // vacio = promo automatica
+	DiscountType     string
+	Value            float64
+	Scope            string
+	CategoryID       *uuid.UUID
+	ProductID        *uuid.UUID
+	MinSpend         float64
+	UsageLimit       int
+	PerCustomerLimit int
+	Stackable        bool
+	Priority         int
+	StartsAt         *time.Time
+	EndsAt           *time.Time
+	Active           bool
+}
+
+// normalizeCouponCode deja el codigo como se guarda
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func validatePromotionInput(in *PromotionInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Code = normalizeCouponCode(in.Code)
+	if in.Scope == "" {
+		in.Scope = models.PromotionScopeStore
+	}
+
+	if in.Name == "" || len(in.Name) > 150 {
+		return fmt.Errorf("name is required and must be at most 150 characters")
+	}
+	if in.Code != "" && !couponCodePattern.MatchString(in.Code) {
+		return fmt.Errorf("code must be 3-50 characters: letters, numbers, - or _")
+	}
+	switch in.DiscountType {
+	case models.DiscountPercent:
+		if in.Value <= 0 || in.Value > 100 {
+			return fmt.Errorf("percent discount must be between 0 and 100")
+		}
+	case models.DiscountFixed:
+		if in.Value <= 0 {
+			return fmt.Errorf("fixed discount must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("discount_type must be percent or fixed")
+	}
+	switch in.Scope {
+	case models.PromotionScopeStore:
+		in.CategoryID, in.ProductID = nil, nil
+	case models.PromotionScopeCategory:
+		if in.CategoryID == nil {
+			return fmt.Errorf("category_id is required for category promotions")
+		}
+		in.ProductID = nil
+	case models.PromotionScopeProduct:
+		if in.ProductID == nil {
+			return fmt.Errorf("product_id is required for product promotions")
+		}
+		in.CategoryID = nil
+	default:
+		return fmt.Errorf("scope must be store, category or product")
+	}
+	if in.MinSpend < 0 || in.UsageLimit < 0 || in.PerCustomerLimit < 0 {
+		return fmt.Errorf("min_spend and usage limits cannot be negative")
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// checkPromotionTargets revisa que la categoria o producto sean de la tienda y que el codigo este libre
+func checkPromotionTargets(tx *gorm.DB, promotionID uuid.UUID, in PromotionInput) error {
+	if in.CategoryID != nil {
+		var count int64
+		if err := tx.Model(&models.Category{}).Where("id = ? AND store_id = ?", *in.CategoryID, in.StoreID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("category not found")
+		}
+	}
+	if in.ProductID != nil {
+		var count int64
+		if err := tx.Model(&models.Product{}).Where("id = ? AND store_id = ?", *in.ProductID, in.StoreID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("product not found")
+		}
+	}
+	if in.Code != "" {
+		var count int64
+		if err := tx.Model(&models.Promotion{}).Where("code = ? AND id <> ?", in.Code, promotionID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("coupon code %s already exists", in.Code)
+		}
+	}
+	return nil
+}
+
+func (in PromotionInput) fields() map[string]interface{} {
+	var code *string
+	if in.Code != "" {
+		code = &in.Code
+	}
+	return map[string]interface{}{
+		"name":               in.Name,
+		"description":        in.Description,
+		"code":               code,
+		"discount_type":      in.DiscountType,
+		"value":              in.Value,
+		"scope":              in.Scope,
+		"category_id":        in.CategoryID,
+		"product_id":         in.ProductID,
+		"min_spend":          in.MinSpend,
+		"usage_limit":        in.UsageLimit,
+		"per_customer_limit": in.PerCustomerLimit,
+		"stackable":          in.Stackable,
+		"priority":           in.Priority,
+		"starts_at":          in.StartsAt,
+		"ends_at":            in.EndsAt,
+		"active":             in.Active,
+	}
+}
+
+// ListActivePromotions trae las promos automaticas vigentes, pa la pagina de promociones.
+// Los cupones no salen: el codigo lo reparte la tienda.
+func ListActivePromotions(storeID *uuid.UUID) ([]models.Promotion, error) {
+	now := time.Now()
+	query := database.DB.
+		Preload("Store").
+		Where("promotions.code IS NULL AND promotions.active = ?", true).
+		Where("(promotions.starts_at IS NULL OR promotions.starts_at <= ?) AND (promotions.ends_at IS NULL OR promotions.ends_at > ?)", now, now).
+		Where("promotions.store_id NOT IN (SELECT id FROM stores WHERE archived_at IS NOT NULL)")
+	if storeID != nil {
+		query = query.Where("promotions.store_id = ?", *storeID)
+	}
+
+	var promotions []models.Promotion
+	if err := query.Order("promotions.priority DESC, promotions.ends_at ASC NULLS LAST, promotions.name ASC").Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch promotions: %w", err)
+	}
+	return promotions, nil
+}
+
+// ListAdminPromotions lista promos y cupones de las tiendas del staff
+func ListAdminPromotions(access *StaffAccess, storeID *uuid.UUID) ([]models.Promotion, error) {
+	query := database.DB.Preload("Store")
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, ErrForbidden
+		}
+		query = query.Where("store_id = ?", *storeID)
+	} else if !access.IsAdmin() {
+		query = query.Where("store_id IN ?", access.StoreIDs)
+	}
+
+	var promotions []models.Promotion
+	if err := query.Order("created_at DESC").Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch promotions: %w", err)
+	}
+	return promotions, nil
+}
+
+// GetAdminPromotion trae una promo si el staff maneja su tienda
+func GetAdminPromotion(access *StaffAccess, promotionID uuid.UUID) (*models.Promotion, error) {
+	var promotion models.Promotion
+	if err := database.DB.Preload("Store").First(&promotion, "id = ?", promotionID).Error; err != nil {
+		return nil, fmt.Errorf("promotion not found")
+	}
+	if !access.CanManageStore(promotion.StoreID) {
+		return nil, ErrForbidden
+	}
+	return &promotion, nil
+}
+
+// CreatePromotion crea una promo automatica o un cupon
+func CreatePromotion(access *StaffAccess, in PromotionInput) (*models.Promotion, error) {
+	if err := validatePromotionInput(&in); err != nil {
+		return nil, err
+	}
+	if !access.CanManageStore(in.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	promotion := &models.Promotion{ID: uuid.New(), StoreID: in.StoreID}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var store models.Store
+		if err := tx.First(&store, "id = ?", in.StoreID).Error; err != nil {
+			return fmt.Errorf("store not found")
+		}
+		if err := checkPromotionTargets(tx, promotion.ID, in); err != nil {
+			return err
+		}
+
+		// Create con el mapa pa que los false y nil se guarden tal cual (no los defaults)
+		fields := in.fields()
+		fields["id"] = promotion.ID
+		fields["store_id"] = in.StoreID
+		fields["created_at"] = time.Now()
+		fields["updated_at"] = time.Now()
+		if err := tx.Model(&models.Promotion{}).Create(fields).Error; err != nil {
+			return fmt.Errorf("failed to create promotion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminPromotion(access, promotion.ID)
+}
+
+// UpdatePromotion cambia la promo; la tienda y el conteo de usos no se tocan
+func UpdatePromotion(access *StaffAccess, promotionID uuid.UUID, in PromotionInput) (*models.Promotion, error) {
+	promotion, err := GetAdminPromotion(access, promotionID)
+	if err != nil {
+		return nil, err
+	}
+	in.StoreID = promotion.StoreID
+	if err := validatePromotionInput(&in); err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkPromotionTargets(tx, promotion.ID, in); err != nil {
+			return err
+		}
+		fields := in.fields()
+		fields["updated_at"] = time.Now()
+		if err := tx.Model(&models.Promotion{}).Where("id = ?", promotion.ID).Updates(fields).Error; err != nil {
+			return fmt.Errorf("failed to update promotion: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminPromotion(access, promotion.ID)
+}
+
+// DeletePromotion borra una promo que nunca se uso
+func DeletePromotion(access *StaffAccess, promotionID uuid.UUID) error {
+	promotion, err := GetAdminPromotion(access, promotionID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var used int64
+		if err := tx.Model(&models.OrderDiscount{}).Where("promotion_id = ?", promotion.ID).Count(&used).Error; err != nil {
+			return err
+		}
+		if used > 0 {
+			return ErrPromotionInUse
+		}
+		if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&models.CartCoupon{}).Error; err != nil {
+			return fmt.Errorf("failed to remove coupon from carts: %w", err)
+		}
+		return tx.Delete(&models.Promotion{}, "id = ?", promotion.ID).Error
+	})
+}
+
+// ApplyCartCoupon mete un cupon en el carrito; si no aplica igual queda y el
+// carrito dice por que (puede que aplique cuando agreguen mas cosas)
+func ApplyCartCoupon(cartID uuid.UUID, code string) error {
+	code = normalizeCouponCode(code)
+	if code == "" {
+		return fmt.Errorf("coupon code is required")
+	}
+
+	var promotion models.Promotion
+	if err := database.DB.First(&promotion, "code = ?", code).Error; err != nil {
+		return fmt.Errorf("coupon not found")
+	}
+	if !promotion.ActiveAt(time.Now()) {
+		return fmt.Errorf("coupon is not active")
+	}
+
+	coupon := &models.CartCoupon{ID: uuid.New(), CartID: cartID, PromotionID: promotion.ID}
+	if err := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(coupon).Error; err != nil {
+		return fmt.Errorf("failed to apply coupon: %w", err)
+	}
+	return nil
+}
+
+// RemoveCartCoupon saca un cupon del carrito
+func RemoveCartCoupon(cartID uuid.UUID, code string) error {
+	result := database.DB.
+		Where("cart_id = ? AND promotion_id IN (SELECT id FROM promotions WHERE code = ?)", cartID, normalizeCouponCode(code)).
+		Delete(&models.CartCoupon{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove coupon: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("coupon not found")
+	}
+	return nil
+}
+
+// confirmPromotions suma al contador de cada promo que uso el pedido; se llama una
+// sola vez, cuando el pago queda completed
+func confirmPromotions(tx *gorm.DB, orderID uuid.UUID) error {
+	if err := tx.Model(&models.Promotion{}).
+		Where("id IN (?)", tx.Model(&models.PromotionRedemption{}).Select("promotion_id").Where("order_id = ?", orderID)).
+		Update("usage_count", gorm.Expr("usage_count + 1")).Error; err != nil {
+		return fmt.Errorf("failed to update promotion usage: %w", err)
+	}
+	return nil
+}
+
+// cartCoupons trae las promos de los cupones que tiene el carrito
+func cartCoupons(db *gorm.DB, cartID uuid.UUID) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	if err := db.
+		Joins("JOIN cart_coupons ON cart_coupons.promotion_id = promotions.id").
+		Where("cart_coupons.cart_id = ?", cartID).
+		Order("cart_coupons.created_at ASC").
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch cart coupons: %w", err)
+	}
+	return promotions, nil
+}
+
+// PriceCart calcula subtotal, descuentos linea por linea y total del carrito
+func PriceCart(cartID uuid.UUID, userID *uuid.UUID, sessionID *string) (*CartPricing, error) {
+	items, err := GetCartItems(cartID)
+	if err != nil {
+		return nil, err
+	}
+	coupons, err := cartCoupons(database.DB, cartID)
+	if err != nil {
+		return nil, err
+	}
+	return priceLines(database.DB, pricingLines(items), coupons, customerKey(userID, sessionID, ""), time.Now())
+}
+
+// redeemPromotions registra el uso de las promos del pedido. Bloquea cada promo
+// y vuelve a revisar los limites, asi dos pedidos no se pasan del tope a la vez.
+// El uso solo cuenta pa los topes cuando el pago pasa (confirmPromotions): un
+// pedido sin pagar o con pago fallido no gasta el cupon.
+func redeemPromotions(tx *gorm.DB, order *models.Order, pricing *CartPricing, customer string) error {
+	now := time.Now()
+	for _, applied := range pricing.applied {
+		var promo models.Promotion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&promo, "id = ?", applied.ID).Error; err != nil {
+			return fmt.Errorf("promotion %s is no longer available", applied.Name)
+		}
+		reason, err := promotionUnavailable(tx, promo, customer, now)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return fmt.Errorf("%s: %s", promo.Name, reason)
+		}
+
+		redemption := &models.PromotionRedemption{
+			ID:          uuid.New(),
+			PromotionID: promo.ID,
+			OrderID:     order.ID,
+			CustomerKey: customer,
+		}
+		if err := tx.Create(redemption).Error; err != nil {
+			return fmt.Errorf("failed to register promotion usage: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// maxRelatedProducts es el tope de recomendaciones por respuesta
+const maxRelatedProducts = 20
+
+// recommendationMu evita que dos recalculos se pisen
+var recommendationMu sync.Mutex
+
+// ComputeProductAffinities recalcula desde cero que productos se compran juntos.
+// Solo cuentan pedidos pagados; el score es coseno (juntos / sqrt(pedidos A * pedidos B))
+// pa que los productos super vendidos no salgan recomendados con todo.
+func ComputeProductAffinities() (int, error) {
+	recommendationMu.Lock()
+	defer recommendationMu.Unlock()
+
+	var pairs []struct {
+		ProductID        uuid.UUID
+		RelatedProductID uuid.UUID
+		Orders           int
+	}
+	if err := database.DB.Raw(`
+		SELECT a.product_id, b.product_id AS related_product_id, COUNT(DISTINCT a.order_id) AS orders
+		FROM order_items a
+		JOIN order_items b ON b.order_id = a.order_id AND b.product_id <> a.product_id
+		JOIN orders ON orders.id = a.order_id
+		WHERE orders.payment_status = ?
+		GROUP BY a.product_id, b.product_id`, "completed").
+		Scan(&pairs).Error; err != nil {
+		return 0, fmt.Errorf("failed to compute product pairs: %w", err)
+	}
+
+	var totals []struct {
+		ProductID uuid.UUID
+		Orders    int
+	}
+	if err := database.DB.Raw(`
+		SELECT order_items.product_id, COUNT(DISTINCT order_items.order_id) AS orders
+		FROM order_items
+		JOIN orders ON orders.id = order_items.order_id
+		WHERE orders.payment_status = ?
+		GROUP BY order_items.product_id`, "completed").
+		Scan(&totals).Error; err != nil {
+		return 0, fmt.Errorf("failed to count product orders: %w", err)
+	}
+	ordersByProduct := make(map[uuid.UUID]int, len(totals))
+	for _, t := range totals {
+		ordersByProduct[t.ProductID] = t.Orders
+	}
+
+	now := time.Now()
+	affinities := make([]models.ProductAffinity, 0, len(pairs))
+	for _, pair := range pairs {
+		a, b := ordersByProduct[pair.ProductID], ordersByProduct[pair.RelatedProductID]
+		if a == 0 || b == 0 {
+			continue
+		}
+		affinities = append(affinities, models.ProductAffinity{
+			ProductID:        pair.ProductID,
+			RelatedProductID: pair.RelatedProductID,
+			Orders:           pair.Orders,
+			Score:            math.Round(float64(pair.Orders)/math.Sqrt(float64(a*b))*10000) / 10000,
+			UpdatedAt:        now,
+		})
+	}
+
+	// Reemplazo completo en una transaccion: nadie ve la tabla a medias
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ProductAffinity{}).Error; err != nil {
+			return fmt.Errorf("failed to clear affinities: %w", err)
+		}
+		if len(affinities) == 0 {
+			return nil
+		}
+		if err := tx.Omit("Product", "RelatedProduct").CreateInBatches(affinities, 500).Error; err != nil {
+			return fmt.Errorf("failed to save affinities: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(affinities), nil
+}
+
+// StartRecommendationWorker recalcula las afinidades al arrancar y luego cada interval.
+// Devuelve la funcion pa pararlo.
+func StartRecommendationWorker(interval time.Duration) func() {
+	run := func() {
+		count, err := ComputeProductAffinities()
+		if err != nil {
+			log.Printf("Warning: failed to compute recommendations: %v", err)
+			return
+		}
+		log.Printf("✓ Recommendations updated (%d product pairs)", count)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// GetRelatedProducts trae lo que se compra junto con el producto.
+// Si el producto es nuevo y no tiene historial, se rellena con la misma categoria y luego la misma tienda.
+func GetRelatedProducts(productID uuid.UUID, limit int) ([]models.Product, error) {
+	if limit < 1 || limit > maxRelatedProducts {
+		limit = 8
+	}
+
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).First(&product, "id = ?", productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	var categoryIDs []uuid.UUID
+	if product.CategoryID != nil {
+		categoryIDs = append(categoryIDs, *product.CategoryID)
+	}
+	return recommendProducts([]uuid.UUID{product.ID}, categoryIDs, []uuid.UUID{product.StoreID}, limit)
+}
+
+// GetCartRecommendations suma las afinidades de todo lo que hay en el carrito
+// y no repite nada que ya este adentro
+func GetCartRecommendations(cartID uuid.UUID, limit int) ([]models.Product, error) {
+	if limit < 1 || limit > maxRelatedProducts {
+		limit = 4
+	}
+
+	var items []models.CartItem
+	if err := database.DB.Preload("Product").Where("cart_id = ?", cartID).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch cart items: %w", err)
+	}
+	if len(items) == 0 {
+		return []models.Product{}, nil
+	}
+
+	var productIDs, categoryIDs, storeIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, item := range items {
+		if !seen[item.ProductID] {
+			seen[item.ProductID] = true
+			productIDs = append(productIDs, item.ProductID)
+		}
+		if id := item.Product.CategoryID; id != nil && !seen[*id] {
+			seen[*id] = true
+			categoryIDs = append(categoryIDs, *id)
+		}
+		if id := item.Product.StoreID; !seen[id] {
+			seen[id] = true
+			storeIDs = append(storeIDs, id)
+		}
+	}
+	return recommendProducts(productIDs, categoryIDs, storeIDs, limit)
+}
+
+// recommendProducts arma la lista: primero afinidad, despues misma categoria, despues misma tienda.
+// Los productos base nunca se recomiendan a si mismos.
+func recommendProducts(productIDs, categoryIDs, storeIDs []uuid.UUID, limit int) ([]models.Product, error) {
+	var ids []uuid.UUID
+	exclude := append([]uuid.UUID{}, productIDs...)
+
+	var byAffinity []uuid.UUID
+	if err := database.DB.Model(&models.ProductAffinity{}).
+		Select("product_affinities.related_product_id").
+		Joins("JOIN products ON products.id = product_affinities.related_product_id").
+		Scopes(visibleProducts).
+		Where("product_affinities.product_id IN ? AND product_affinities.related_product_id NOT IN ?", productIDs, productIDs).
+		Group("product_affinities.related_product_id").
+		Order("SUM(product_affinities.score) DESC, SUM(product_affinities.orders) DESC").
+		Limit(limit).
+		Pluck("product_affinities.related_product_id", &byAffinity).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch related products: %w", err)
+	}
+	ids = append(ids, byAffinity...)
+	exclude = append(exclude, byAffinity...)
+
+	// Cold start: rellenamos con parecidos, los mejor calificados primero
+	fallbacks := []struct {
+		column string
+		values []uuid.UUID
+	}{
+		{"products.category_id", categoryIDs},
+		{"products.store_id", storeIDs},
+	}
+	for _, fb := range fallbacks {
+		if len(ids) >= limit || len(fb.values) == 0 {
+			continue
+		}
+		var similar []uuid.UUID
+		if err := database.DB.Model(&models.Product{}).
+			Scopes(visibleProducts).
+			Where(fb.column+" IN ? AND products.id NOT IN ?", fb.values, exclude).
+			Order("products.rating_average DESC, products.review_count DESC, products.created_at DESC").
+			Limit(limit-len(ids)).
+			Pluck("products.id", &similar).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch similar products: %w", err)
+		}
+		ids = append(ids, similar...)
+		exclude = append(exclude, similar...)
+	}
+
+	if len(ids) == 0 {
+		return []models.Product{}, nil
+	}
+
+	var products []models.Product
+	if err := database.DB.
+		Preload("Store").
+		Preload("Images", orderedImages).
+		Where("id IN ?", ids).
+		Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch related products: %w", err)
+	}
+
+	// Find no respeta el orden del IN, lo devolvemos en el orden del ranking
+	byID := make(map[uuid.UUID]models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	ordered := make([]models.Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// maxReviewPhotos es el limite de fotos por resena
+const maxReviewPhotos = 4
+
+// ReviewInput es lo que manda el cliente
+type ReviewInput struct {
+	Rating int
+	Title  string
+	Body   string
+	Fit    string
+	Size   string
+}
+
+func validateReviewInput(in *ReviewInput, photos int) error {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Body = strings.TrimSpace(in.Body)
+	in.Fit = strings.ToLower(strings.TrimSpace(in.Fit))
+	in.Size = strings.TrimSpace(in.Size)
+
+	if in.Rating < 1 || in.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if len(in.Title) > 150 {
+		return fmt.Errorf("title must be at most 150 characters")
+	}
+	if len(in.Body) > 5000 {
+		return fmt.Errorf("review must be at most 5000 characters")
+	}
+	switch in.Fit {
+	case "", models.FitSmall, models.FitTrue, models.FitLarge:
+	default:
+		return fmt.Errorf("fit must be small, true or large")
+	}
+	if len(in.Size) > 10 {
+		return fmt.Errorf("size must be at most 10 characters")
+	}
+	if photos > maxReviewPhotos {
+		return fmt.Errorf("at most %d photos per review", maxReviewPhotos)
+	}
+	return nil
+}
+
+// paidOrderItem busca una compra pagada del producto pa marcar "compra verificada".
+// Va por payment_status igual que las recomendaciones: ningun flujo deja pedidos en "delivered".
+func paidOrderItem(tx *gorm.DB, userID, productID uuid.UUID) *models.OrderItem {
+	var item models.OrderItem
+	err := tx.
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.payment_status = ? AND order_items.product_id = ?", userID, "completed", productID).
+		Order("order_items.created_at DESC").
+		First(&item).Error
+	if err != nil {
+		return nil
+	}
+	return &item
+}
+
+// CreateReview crea (o reemplaza) la resena del user sobre el producto.
+// Siempre queda pendiente hasta que alguien del staff la apruebe.
+func CreateReview(userID, productID uuid.UUID, in ReviewInput, photos []io.Reader) (*models.Review, error) {
+	if err := validateReviewInput(&in, len(photos)); err != nil {
+		return nil, err
+	}
+
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).First(&product, "id = ?", productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	// Primero validamos peso y dimensiones de todas, asi una foto gigante no deja
+	// las anteriores guardadas de huerfanas
+	maxSize := config.Get().MaxUploadSize
+	var photoData [][]byte
+	for _, photo := range photos {
+		data, err := io.ReadAll(io.LimitReader(photo, maxSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read photo: %w", err)
+		}
+		if int64(len(data)) > maxSize {
+			return nil, ErrImageTooLarge
+		}
+		if err := utils.CheckImageDimensions(data); err != nil {
+			return nil, err
+		}
+		photoData = append(photoData, data)
+	}
+
+	// Las fotos se limpian (EXIF fuera) y se guardan antes de abrir la transaccion
+	var paths []string
+	for _, data := range photoData {
+		path, _, _, err := storeUploadedImage(bytes.NewReader(data))
+		if err != nil {
+			cleanupImages(paths)
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	// Las fotos que se reemplazan se borran del disco cuando la transaccion pase
+	var replaced []string
+
+	var review models.Review
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("product_id = ? AND user_id = ?", product.ID, userID).First(&review).Error
+		isNew := err != nil
+		if isNew {
+			review = models.Review{ID: uuid.New(), ProductID: product.ID, UserID: userID}
+		}
+
+		review.Rating = in.Rating
+		review.Title = in.Title
+		review.Body = in.Body
+		review.Fit = in.Fit
+		review.Size = in.Size
+		review.Status = models.ReviewPending
+		review.ModeratedBy = nil
+		review.ModeratedAt = nil
+		review.ModerationNote = ""
+		review.OrderItemID = nil
+		if item := paidOrderItem(tx, userID, product.ID); item != nil {
+			review.OrderItemID = &item.ID
+			if review.Size == "" {
+				review.Size = item.Size
+			}
+		}
+
+		if isNew {
+			err = tx.Create(&review).Error
+		} else {
+			err = tx.Omit("Product", "User", "OrderItem", "Photos").Save(&review).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save review: %w", err)
+		}
+
+		// Si manda fotos nuevas reemplazan las anteriores
+		if len(paths) > 0 {
+			if err := tx.Model(&models.ReviewPhoto{}).Where("review_id = ?", review.ID).Pluck("path", &replaced).Error; err != nil {
+				return fmt.Errorf("failed to fetch review photos: %w", err)
+			}
+			if err := tx.Where("review_id = ?", review.ID).Delete(&models.ReviewPhoto{}).Error; err != nil {
+				return fmt.Errorf("failed to replace review photos: %w", err)
+			}
+			for i, path := range paths {
+				photo := &models.ReviewPhoto{ID: uuid.New(), ReviewID: review.ID, Path: path, Position: i}
+				if err := tx.Create(photo).Error; err != nil {
+					return fmt.Errorf("failed to save review photo: %w", err)
+				}
+			}
+		}
+
+		// Si estaba aprobada y la editaron, sale del promedio hasta que la revisen
+		return refreshProductRating(tx, product.ID)
+	})
+	if err != nil {
+		// Lo que subimos no quedo en ninguna resena
+		cleanupImages(paths)
+		return nil, err
+	}
+	cleanupImages(replaced)
+
+	return getReview(review.ID)
+}
+
+func getReview(reviewID uuid.UUID) (*models.Review, error) {
+	var review models.Review
+	if err := database.DB.
+		Preload("User").
+		Preload("Product").
+		Preload("Photos", orderedImages).
+		First(&review, "id = ?", reviewID).Error; err != nil {
+		return nil, fmt.Errorf("review not found")
+	}
+	return &review, nil
+}
+
+// refreshProductRating recalcula promedio y conteo con las resenas aprobadas
+func refreshProductRating(tx *gorm.DB, productID uuid.UUID) error {
+	var stats struct {
+		Average float64
+		Total   int
+	}
+	if err := tx.Model(&models.Review{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS total").
+		Where("product_id = ? AND status = ?", productID, models.ReviewApproved).
+		Scan(&stats).Error; err != nil {
+		return fmt.Errorf("failed to compute rating: %w", err)
+	}
+
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"rating_average": math.Round(stats.Average*100) / 100,
+		"review_count":   stats.Total,
+	}).Error
+}
+
+// GetProductReviews trae las resenas aprobadas, las mas nuevas primero
+func GetProductReviews(productID uuid.UUID, page, limit int) ([]models.Review, int64, error) {
+	var reviews []models.Review
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.Review{}).Where("product_id = ? AND status = ?", productID, models.ReviewApproved)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count reviews: %w", err)
+	}
+
+	if err := query.
+		Preload("User").
+		Preload("Photos", orderedImages).
+		Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch reviews: %w", err)
+	}
+
+	return reviews, total, nil
+}
+
+// ListReviewsForModeration lista la cola de moderacion de las tiendas del staff
+func ListReviewsForModeration(access *StaffAccess, status string, storeID *uuid.UUID, page, limit int) ([]models.Review, int64, error) {
+	var reviews []models.Review
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if status == "" {
+		status = models.ReviewPending
+	}
+
+	query := database.DB.Model(&models.Review{}).
+		Joins("JOIN products ON products.id = reviews.product_id").
+		Where("reviews.status = ?", status)
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, 0, ErrForbidden
+		}
+		query = query.Where("products.store_id = ?", *storeID)
+	} else if !access.IsAdmin() {
+		query = query.Where("products.store_id IN ?", access.StoreIDs)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count reviews: %w", err)
+	}
+
+	if err := query.
+		Preload("User").
+		Preload("Product").
+		Preload("Photos", orderedImages).
+		Order("reviews.created_at ASC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch reviews: %w", err)
+	}
+
+	return reviews, total, nil
+}
+
+// ModerateReview aprueba o rechaza una resena y actualiza el promedio del producto
+func ModerateReview(access *StaffAccess, reviewID uuid.UUID, status, note string) (*models.Review, error) {
+	if status != models.ReviewApproved && status != models.ReviewRejected {
+		return nil, fmt.Errorf("status must be approved or rejected")
+	}
+
+	review, err := getReview(reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.CanManageStore(review.Product.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Review{}).Where("id = ?", review.ID).Updates(map[string]interface{}{
+			"status":          status,
+			"moderated_by":    access.UserID,
+			"moderated_at":    now,
+			"moderation_note": strings.TrimSpace(note),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to moderate review: %w", err)
+		}
+		return refreshProductRating(tx, review.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getReview(review.ID)
+}
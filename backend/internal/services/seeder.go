@@ -3,80 +3,55 @@ package services
 import (
 	"log"
 	"os"
-	"path/filepath"
-
-	"github.com/google/uuid"
-	"github.com/leunameek/celestexmewave/internal/database"
-	"github.com/leunameek/celestexmewave/models"
 )
 
+// CatalogDir es la carpeta de los JSON del catalogo (asumimos backend/ como cwd)
+func CatalogDir() string {
+	assetsPath := "../assets/products"
+
+	// Si no existe, intentamos otra ruta
+	if _, err := os.Stat(assetsPath); os.IsNotExist(err) {
+		if _, err := os.Stat("assets/products"); err == nil {
+			assetsPath = "assets/products"
+		}
+	}
+	return assetsPath
+}
+
 // SeedDatabase populates the database with initial data if it's empty
 func SeedDatabase() error {
 	log.Println("Seeding/Syncing database...")
 
-	// Create Stores if they don't exist
-	celesteID := uuid.New()
-	mewaveID := uuid.New()
-
-	stores := []models.Store{
-		{
-			Name:        "Celeste",
-			Description: "Women's fashion brand",
-		},
-		{
-			Name:        "Mewave",
-			Description: "Streetwear brand",
-		},
+	// Branding y contacto de las tiendas (assets/stores.json); solo llena lo que este vacio
+	if err := EnsureStoreProfiles(StoreProfilesPath()); err != nil {
+		log.Printf("Warning: Failed to sync store profiles: %v", err)
 	}
 
-	for _, store := range stores {
-		var existingStore models.Store
-		if err := database.DB.Where("name = ?", store.Name).First(&existingStore).Error; err != nil {
-			// Si no existe, la creamos
-			store.ID = uuid.New()
-			if store.Name == "Celeste" {
-				celesteID = store.ID
-			} else {
-				mewaveID = store.ID
-			}
-			if err := database.DB.Create(&store).Error; err != nil {
-				return err
-			}
-		} else {
-			// Si existe, usamos el ID que ya tiene
-			if store.Name == "Celeste" {
-				celesteID = existingStore.ID
-			} else {
-				mewaveID = existingStore.ID
-			}
-		}
+	// Cada archivo de assets/products es una tienda; se crea si no existe
+	// (cada archivo deja su reporte en el log)
+	if _, err := SyncCatalogDir(CatalogDir()); err != nil {
+		log.Printf("Warning: Failed to sync catalog: %v", err)
 	}
-	log.Println("✓ Stores synced")
 
-	// Vemos la ruta de assets (asumimos backend/)
-	assetsPath := "../assets/products"
-	
-	// Si no existe, intentamos otra ruta
-	if _, err := os.Stat(assetsPath); os.IsNotExist(err) {
-		if _, err := os.Stat("assets/products"); err == nil {
-			assetsPath = "assets/products"
-		}
+	// Productos que quedaron sin variantes (data vieja) se migran aqui
+	if err := EnsureProductVariants(); err != nil {
+		log.Printf("Warning: Failed to migrate product variants: %v", err)
+	} else {
+		log.Println("✓ Product variants synced")
 	}
 
-	// Cargamos productos de Celeste
-	celesteJSON := filepath.Join(assetsPath, "celeste.json")
-	if err := LoadProductsFromJSON(celesteID, "Celeste", celesteJSON); err != nil {
-		log.Printf("Warning: Failed to load Celeste products: %v", err)
+	// Textos viejos de categoria pasan al arbol de categorias
+	if err := EnsureProductCategories(); err != nil {
+		log.Printf("Warning: Failed to migrate product categories: %v", err)
 	} else {
-		log.Println("✓ Celeste products loaded")
+		log.Println("✓ Product categories synced")
 	}
 
-	// Cargamos productos de Mewave
-	mewaveJSON := filepath.Join(assetsPath, "mewave.json")
-	if err := LoadProductsFromJSON(mewaveID, "Mewave", mewaveJSON); err != nil {
-		log.Printf("Warning: Failed to load Mewave products: %v", err)
+	// Igual con las fotos: image_path viejo pasa a la galeria
+	if err := EnsureProductImages(); err != nil {
+		log.Printf("Warning: Failed to migrate product images: %v", err)
 	} else {
-		log.Println("✓ Mewave products loaded")
+		log.Println("✓ Product images synced")
 	}
 
 	return nil
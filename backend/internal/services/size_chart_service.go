@@ -0,0 +1,483 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrSizeChartExists sale cuando la tienda ya tiene guia pa esa categoria
+var ErrSizeChartExists = errors.New("a size chart already exists for this store and category")
+
+// fitMinFeedback es cuantas resenas con talla hacen falta pa mover la recomendacion
+const fitMinFeedback = 5
+
+// Ajustes que puede hacer la recomendacion por lo que dicen las resenas
+const (
+	FitAdjustNone = ""
+	FitAdjustUp   = "size_up"   // el producto queda chico
+	FitAdjustDown = "size_down" // el producto queda grande
+)
+
+// SizeChartRowInput es una talla de la guia con sus medidas en cm
+type SizeChartRowInput struct {
+	Size         string                      `json:"size"`
+	Measurements map[string]models.SizeRange `json:"measurements"`
+}
+
+// SizeChartInput son los campos editables de una guia; Rows va de la talla mas chica a la mas grande
+type SizeChartInput struct {
+	StoreID    uuid.UUID
+	CategoryID *uuid.UUID
+	Name       string
+	Notes      string
+	Rows       []SizeChartRowInput
+}
+
+// FitSummary cuenta como les quedo la talla a los que dejaron resena
+type FitSummary struct {
+	Small int64
+	True  int64
+	Large int64
+	Total int64
+}
+
+// SizeScore es que tan lejos quedan las medidas del cliente de una talla (0 = adentro de todos los rangos)
+type SizeScore struct {
+	Size     string
+	Distance float64 // cm por fuera de los rangos, sumados
+	Matched  int     // cuantas medidas del cliente tiene la guia pa esa talla
+}
+
+// SizeRecommendation es la talla sugerida y como se llego a ella
+type SizeRecommendation struct {
+	Size       string
+	ChartSize  string // la que sale solo por medidas, antes de las resenas
+	Adjustment string
+	Fit        FitSummary
+	Scores     []SizeScore
+	Chart      *models.SizeChart
+}
+
+func orderedSizeChartRows(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+func validateSizeChartInput(in *SizeChartInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 150 {
+		return fmt.Errorf("name is required and must be at most 150 characters")
+	}
+	if len(in.Rows) == 0 {
+		return fmt.Errorf("at least one size is required")
+	}
+
+	seen := make(map[string]bool)
+	for i := range in.Rows {
+		row := &in.Rows[i]
+		row.Size = strings.TrimSpace(row.Size)
+		if row.Size == "" || len(row.Size) > 10 {
+			return fmt.Errorf("size is required and must be at most 10 characters")
+		}
+		if seen[strings.ToLower(row.Size)] {
+			return fmt.Errorf("size %s is repeated", row.Size)
+		}
+		seen[strings.ToLower(row.Size)] = true
+
+		if len(row.Measurements) == 0 {
+			return fmt.Errorf("size %s needs at least one measurement", row.Size)
+		}
+		for name, r := range row.Measurements {
+			if !slices.Contains(models.SizeMeasurements, name) {
+				return fmt.Errorf("unknown measurement %q, use one of: %s", name, strings.Join(models.SizeMeasurements, ", "))
+			}
+			if r.Min <= 0 || r.Max < r.Min {
+				return fmt.Errorf("measurement %s of size %s must have 0 < min <= max", name, row.Size)
+			}
+		}
+	}
+	return nil
+}
+
+// setSizeChartRows reemplaza las tallas de la guia en el orden que mandan
+func setSizeChartRows(tx *gorm.DB, chart *models.SizeChart, rows []SizeChartRowInput) error {
+	if err := tx.Where("size_chart_id = ?", chart.ID).Delete(&models.SizeChartRow{}).Error; err != nil {
+		return fmt.Errorf("failed to clear size chart rows: %w", err)
+	}
+	for i, in := range rows {
+		row := &models.SizeChartRow{ID: uuid.New(), SizeChartID: chart.ID, Size: in.Size, Position: i}
+		if err := row.SetMeasurements(in.Measurements); err != nil {
+			return fmt.Errorf("failed to encode measurements: %w", err)
+		}
+		if err := tx.Create(row).Error; err != nil {
+			return fmt.Errorf("failed to add size chart row: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkSizeChartScope revisa la categoria y que no haya otra guia pa la misma tienda + categoria
+func checkSizeChartScope(tx *gorm.DB, chartID, storeID uuid.UUID, categoryID *uuid.UUID) error {
+	query := tx.Model(&models.SizeChart{}).Where("store_id = ? AND id <> ?", storeID, chartID)
+	if categoryID != nil {
+		var category models.Category
+		if err := tx.First(&category, "id = ? AND store_id = ?", *categoryID, storeID).Error; err != nil {
+			return fmt.Errorf("category not found")
+		}
+		query = query.Where("category_id = ?", *categoryID)
+	} else {
+		query = query.Where("category_id IS NULL")
+	}
+
+	var taken int64
+	if err := query.Count(&taken).Error; err != nil {
+		return fmt.Errorf("failed to check size charts: %w", err)
+	}
+	if taken > 0 {
+		return ErrSizeChartExists
+	}
+	return nil
+}
+
+// ListAdminSizeCharts lista las guias de las tiendas del staff
+func ListAdminSizeCharts(access *StaffAccess, storeID *uuid.UUID) ([]models.SizeChart, error) {
+	query := database.DB.Model(&models.SizeChart{})
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, ErrForbidden
+		}
+		query = query.Where("store_id = ?", *storeID)
+	} else if !access.IsAdmin() {
+		query = query.Where("store_id IN ?", access.StoreIDs)
+	}
+
+	var charts []models.SizeChart
+	if err := query.
+		Preload("Store").
+		Preload("Category").
+		Preload("Rows", orderedSizeChartRows).
+		Order("store_id, name ASC").
+		Find(&charts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch size charts: %w", err)
+	}
+	return charts, nil
+}
+
+// GetAdminSizeChart trae la guia con sus tallas
+func GetAdminSizeChart(access *StaffAccess, chartID uuid.UUID) (*models.SizeChart, error) {
+	var chart models.SizeChart
+	if err := database.DB.
+		Preload("Store").
+		Preload("Category").
+		Preload("Rows", orderedSizeChartRows).
+		First(&chart, "id = ?", chartID).Error; err != nil {
+		return nil, fmt.Errorf("size chart not found")
+	}
+	if !access.CanManageStore(chart.StoreID) {
+		return nil, ErrForbidden
+	}
+	return &chart, nil
+}
+
+// CreateSizeChart crea la guia de una tienda (o de una categoria de la tienda)
+func CreateSizeChart(access *StaffAccess, in SizeChartInput) (*models.SizeChart, error) {
+	if err := validateSizeChartInput(&in); err != nil {
+		return nil, err
+	}
+	if !access.CanManageStore(in.StoreID) {
+		return nil, ErrForbidden
+	}
+
+	chart := &models.SizeChart{
+		ID:         uuid.New(),
+		StoreID:    in.StoreID,
+		CategoryID: in.CategoryID,
+		Name:       in.Name,
+		Notes:      in.Notes,
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var store models.Store
+		if err := tx.First(&store, "id = ?", in.StoreID).Error; err != nil {
+			return fmt.Errorf("store not found")
+		}
+		if err := checkSizeChartScope(tx, chart.ID, in.StoreID, in.CategoryID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(chart).Error; err != nil {
+			return fmt.Errorf("failed to create size chart: %w", err)
+		}
+		return setSizeChartRows(tx, chart, in.Rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminSizeChart(access, chart.ID)
+}
+
+// UpdateSizeChart cambia la guia y reemplaza sus tallas; la tienda no se puede cambiar
+func UpdateSizeChart(access *StaffAccess, chartID uuid.UUID, in SizeChartInput) (*models.SizeChart, error) {
+	if err := validateSizeChartInput(&in); err != nil {
+		return nil, err
+	}
+
+	chart, err := GetAdminSizeChart(access, chartID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkSizeChartScope(tx, chart.ID, chart.StoreID, in.CategoryID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.SizeChart{}).Where("id = ?", chart.ID).Updates(map[string]interface{}{
+			"category_id": in.CategoryID,
+			"name":        in.Name,
+			"notes":       in.Notes,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update size chart: %w", err)
+		}
+		return setSizeChartRows(tx, chart, in.Rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetAdminSizeChart(access, chart.ID)
+}
+
+// DeleteSizeChart borra la guia con sus tallas
+func DeleteSizeChart(access *StaffAccess, chartID uuid.UUID) error {
+	chart, err := GetAdminSizeChart(access, chartID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("size_chart_id = ?", chart.ID).Delete(&models.SizeChartRow{}).Error; err != nil {
+			return fmt.Errorf("failed to delete size chart rows: %w", err)
+		}
+		if err := tx.Delete(&models.SizeChart{}, "id = ?", chart.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete size chart: %w", err)
+		}
+		return nil
+	})
+}
+
+// ProductSizeChart busca la guia que aplica al producto: la de su categoria o la del
+// ancestro mas cercano que tenga, y si no la general de la tienda. nil si no hay ninguna.
+func ProductSizeChart(product *models.Product) (*models.SizeChart, error) {
+	var charts []models.SizeChart
+	if err := database.DB.
+		Preload("Rows", orderedSizeChartRows).
+		Where("store_id = ?", product.StoreID).
+		Find(&charts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch size charts: %w", err)
+	}
+	if len(charts) == 0 {
+		return nil, nil
+	}
+
+	var storeChart *models.SizeChart
+	byCategory := make(map[uuid.UUID]*models.SizeChart)
+	for i := range charts {
+		if charts[i].CategoryID == nil {
+			storeChart = &charts[i]
+		} else {
+			byCategory[*charts[i].CategoryID] = &charts[i]
+		}
+	}
+
+	if product.CategoryID != nil && len(byCategory) > 0 {
+		ancestors, err := categoryAncestors(database.DB, []uuid.UUID{product.StoreID})
+		if err != nil {
+			return nil, err
+		}
+		// ancestors arranca por la categoria misma y sube
+		for _, id := range ancestors[*product.CategoryID] {
+			if chart, ok := byCategory[id]; ok {
+				return chart, nil
+			}
+		}
+	}
+	return storeChart, nil
+}
+
+// productFitFeedback junta el fit de las resenas aprobadas del producto; si son muy pocas
+// suma las de los productos de la misma categoria, que suelen compartir horma
+func productFitFeedback(product *models.Product) (FitSummary, error) {
+	count := func(scope func(*gorm.DB) *gorm.DB) (FitSummary, error) {
+		var rows []struct {
+			Fit   string
+			Count int64
+		}
+		if err := database.DB.Model(&models.Review{}).
+			Scopes(scope).
+			Where("reviews.status = ? AND reviews.fit <> ''", models.ReviewApproved).
+			Select("reviews.fit AS fit, COUNT(*) AS count").
+			Group("reviews.fit").
+			Scan(&rows).Error; err != nil {
+			return FitSummary{}, fmt.Errorf("failed to fetch fit feedback: %w", err)
+		}
+
+		var summary FitSummary
+		for _, row := range rows {
+			switch row.Fit {
+			case models.FitSmall:
+				summary.Small = row.Count
+			case models.FitTrue:
+				summary.True = row.Count
+			case models.FitLarge:
+				summary.Large = row.Count
+			}
+		}
+		summary.Total = summary.Small + summary.True + summary.Large
+		return summary, nil
+	}
+
+	summary, err := count(func(db *gorm.DB) *gorm.DB {
+		return db.Where("reviews.product_id = ?", product.ID)
+	})
+	if err != nil || summary.Total >= fitMinFeedback || product.CategoryID == nil {
+		return summary, err
+	}
+
+	return count(func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN products ON products.id = reviews.product_id").
+			Where("products.store_id = ? AND products.category_id = ?", product.StoreID, *product.CategoryID)
+	})
+}
+
+// fitAdjustment dice pa donde mover la talla si la mayoria dice que queda chico o grande
+func fitAdjustment(fit FitSummary) string {
+	if fit.Total < fitMinFeedback {
+		return FitAdjustNone
+	}
+	switch {
+	case fit.Small*2 > fit.Total:
+		return FitAdjustUp
+	case fit.Large*2 > fit.Total:
+		return FitAdjustDown
+	}
+	return FitAdjustNone
+}
+
+// scoreSizeRow mide que tan lejos quedan las medidas del cliente de los rangos de la talla
+func scoreSizeRow(row models.SizeChartRow, measurements map[string]float64) (SizeScore, error) {
+	ranges, err := row.GetMeasurements()
+	if err != nil {
+		return SizeScore{}, fmt.Errorf("failed to read size chart: %w", err)
+	}
+
+	score := SizeScore{Size: row.Size}
+	for name, value := range measurements {
+		r, ok := ranges[name]
+		if !ok {
+			continue
+		}
+		score.Matched++
+		switch {
+		case value < r.Min:
+			score.Distance += r.Min - value
+		case value > r.Max:
+			score.Distance += value - r.Max
+		}
+	}
+	return score, nil
+}
+
+// RecommendSize sugiere la talla del producto segun las medidas del cliente (cm)
+// y la corre una talla si las resenas dicen que el producto queda chico o grande
+func RecommendSize(productID uuid.UUID, measurements map[string]float64) (*SizeRecommendation, error) {
+	if len(measurements) == 0 {
+		return nil, fmt.Errorf("at least one measurement is required")
+	}
+	for name, value := range measurements {
+		if !slices.Contains(models.SizeMeasurements, name) {
+			return nil, fmt.Errorf("unknown measurement %q, use one of: %s", name, strings.Join(models.SizeMeasurements, ", "))
+		}
+		if value <= 0 || value > 300 {
+			return nil, fmt.Errorf("measurement %s must be between 0 and 300 cm", name)
+		}
+	}
+
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).First(&product, "id = ?", productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	chart, err := ProductSizeChart(&product)
+	if err != nil {
+		return nil, err
+	}
+	if chart == nil {
+		return nil, fmt.Errorf("size chart not found")
+	}
+
+	// Solo las tallas que el producto trae; si no dice, todas las de la guia
+	sizes, _ := product.GetSizes()
+	var rows []models.SizeChartRow
+	for _, row := range chart.Rows {
+		if len(sizes) == 0 || slices.ContainsFunc(sizes, func(s string) bool { return strings.EqualFold(s, row.Size) }) {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("size chart has none of the product's sizes")
+	}
+
+	best := -1
+	scores := make([]SizeScore, 0, len(rows))
+	for i, row := range rows {
+		score, err := scoreSizeRow(row, measurements)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, score)
+		if score.Matched == 0 {
+			continue
+		}
+		// En empate gana la mas grande: mejor holgado que apretado
+		if best == -1 || score.Distance <= scores[best].Distance {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil, fmt.Errorf("none of the measurements are in the size chart")
+	}
+
+	fit, err := productFitFeedback(&product)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendation := &SizeRecommendation{
+		Size:      rows[best].Size,
+		ChartSize: rows[best].Size,
+		Fit:       fit,
+		Scores:    scores,
+		Chart:     chart,
+	}
+
+	switch adjustment := fitAdjustment(fit); {
+	case adjustment == FitAdjustUp && best+1 < len(rows):
+		recommendation.Size = rows[best+1].Size
+		recommendation.Adjustment = adjustment
+	case adjustment == FitAdjustDown && best > 0:
+		recommendation.Size = rows[best-1].Size
+		recommendation.Adjustment = adjustment
+	}
+
+	return recommendation, nil
+}
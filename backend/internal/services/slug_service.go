@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// uniqueProductSlug arma el slug desde el nombre; si ya lo usa otro producto
+// de la tienda (ahora o en su historial) le pegamos -2, -3...
+func uniqueProductSlug(tx *gorm.DB, storeID, productID uuid.UUID, name string) (string, error) {
+	base, err := utils.NewSlug(name)
+	if err != nil {
+		return "", err
+	}
+	if len(base) > 240 {
+		base = strings.TrimSuffix(base[:240], "-")
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Model(&models.Product{}).
+			Where("store_id = ? AND slug = ? AND id <> ?", storeID, slug, productID).
+			Count(&taken).Error; err != nil {
+			return "", fmt.Errorf("failed to check product slug: %w", err)
+		}
+		if taken == 0 {
+			if err := tx.Model(&models.ProductSlug{}).
+				Where("store_id = ? AND slug = ? AND product_id <> ?", storeID, slug, productID).
+				Count(&taken).Error; err != nil {
+				return "", fmt.Errorf("failed to check product slug: %w", err)
+			}
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// renameProductSlug recalcula el slug de un producto que ya existe; el viejo
+// queda en el historial pa que los links compartidos redirijan
+func renameProductSlug(tx *gorm.DB, product *models.Product) error {
+	slug, err := uniqueProductSlug(tx, product.StoreID, product.ID, product.Name)
+	if err != nil {
+		return err
+	}
+	if slug == product.Slug {
+		return nil
+	}
+
+	if product.Slug != "" {
+		old := &models.ProductSlug{ProductID: product.ID, StoreID: product.StoreID, Slug: product.Slug}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(old).Error; err != nil {
+			return fmt.Errorf("failed to keep old slug: %w", err)
+		}
+	}
+
+	// Si vuelve a un nombre que ya tuvo, el slug sale del historial
+	if err := tx.Where("product_id = ? AND slug = ?", product.ID, slug).Delete(&models.ProductSlug{}).Error; err != nil {
+		return fmt.Errorf("failed to update slug history: %w", err)
+	}
+
+	if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).Update("slug", slug).Error; err != nil {
+		return fmt.Errorf("failed to update product slug: %w", err)
+	}
+	product.Slug = slug
+	return nil
+}
+
+// GetProductBySlug busca por tienda (slug o nombre) y slug. Si el slug es viejo devuelve
+// tambien el slug actual pa que el handler mande la redireccion.
+func GetProductBySlug(storeName, slug string) (*models.Product, string, error) {
+	store, err := FindStore(storeName)
+	if err != nil {
+		return nil, "", fmt.Errorf("product not found")
+	}
+
+	var product models.Product
+	err = database.DB.Scopes(productDetail, resolvableProducts).First(&product, "store_id = ? AND slug = ?", store.ID, slug).Error
+	if err == nil {
+		return &product, "", nil
+	}
+
+	var old models.ProductSlug
+	if err := database.DB.Where("store_id = ? AND slug = ?", store.ID, slug).First(&old).Error; err != nil {
+		return nil, "", fmt.Errorf("product not found")
+	}
+	if err := database.DB.Scopes(productDetail, resolvableProducts).First(&product, "id = ?", old.ProductID).Error; err != nil {
+		return nil, "", fmt.Errorf("product not found")
+	}
+
+	return &product, product.Slug, nil
+}
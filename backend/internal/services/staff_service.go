@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/models"
+)
+
+// ErrForbidden sale cuando el staff intenta tocar algo que no es de su tienda
+var ErrForbidden = errors.New("you don't have access to this store")
+
+// StaffAccess resume que puede hacer un user en el panel admin
+type StaffAccess struct {
+	UserID   uuid.UUID
+	Role     string
+	StoreIDs []uuid.UUID
+}
+
+// IsAdmin dice si es admin general (todas las tiendas)
+func (a *StaffAccess) IsAdmin() bool {
+	return a.Role == models.RoleAdmin
+}
+
+// CanManageStore dice si puede editar cosas de esa tienda
+func (a *StaffAccess) CanManageStore(storeID uuid.UUID) bool {
+	if a.IsAdmin() {
+		return true
+	}
+	for _, id := range a.StoreIDs {
+		if id == storeID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStaffAccess carga rol y tiendas del user; error si no es staff ni admin
+func GetStaffAccess(userID uuid.UUID) (*StaffAccess, error) {
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if user.Role != models.RoleStaff && user.Role != models.RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	access := &StaffAccess{UserID: user.ID, Role: user.Role}
+	if err := database.DB.Model(&models.StoreStaff{}).
+		Where("user_id = ?", user.ID).
+		Pluck("store_id", &access.StoreIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch staff stores: %w", err)
+	}
+
+	return access, nil
+}
+
+// AssignStaff le da acceso a un user sobre una tienda (solo admin)
+func AssignStaff(access *StaffAccess, email string, storeID uuid.UUID) (*models.StoreStaff, error) {
+	if !access.IsAdmin() {
+		return nil, ErrForbidden
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	var store models.Store
+	if err := database.DB.First(&store, "id = ?", storeID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+
+	if user.Role == models.RoleCustomer || user.Role == "" {
+		if err := database.DB.Model(&user).Update("role", models.RoleStaff).Error; err != nil {
+			return nil, fmt.Errorf("failed to update user role: %w", err)
+		}
+	}
+
+	var staff models.StoreStaff
+	if err := database.DB.Where("store_id = ? AND user_id = ?", storeID, user.ID).First(&staff).Error; err == nil {
+		return &staff, nil
+	}
+
+	staff = models.StoreStaff{StoreID: storeID, UserID: user.ID}
+	if err := database.DB.Create(&staff).Error; err != nil {
+		return nil, fmt.Errorf("failed to assign staff: %w", err)
+	}
+
+	return &staff, nil
+}
+
+// PromoteAdmin sube a admin la cuenta con ese correo. Solo lo usa el CLI (cmd/admin):
+// los correos no se verifican, asi que no promovemos nada solo porque alguien se registro.
+func PromoteAdmin(email string) (*models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	var user models.User
+	if err := database.DB.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if err := database.DB.Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+		return nil, fmt.Errorf("failed to promote admin: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// LowStockItem es un producto (o variante) en o bajo su umbral
+type LowStockItem struct {
+	StoreID     uuid.UUID  `json:"store_id"`
+	StoreName   string     `json:"store_name"`
+	ProductID   uuid.UUID  `json:"product_id"`
+	ProductName string     `json:"product_name"`
+	VariantID   *uuid.UUID `json:"variant_id"` // nil = el total del producto
+	SKU         string     `json:"sku,omitempty"`
+	Size        string     `json:"size,omitempty"`
+	Color       string     `json:"color,omitempty"`
+	Stock       int        `json:"stock"`
+	Threshold   int        `json:"threshold"`
+	Level       string     `json:"level"`
+	NotifiedAt  *time.Time `json:"notified_at"` // cuando se aviso este cruce; nil = va en el proximo digest
+}
+
+// label arma "Vestido Azul" o "Vestido Azul (M / Negro)" pa los correos
+func (item LowStockItem) label() string {
+	if item.VariantID == nil {
+		return item.ProductName
+	}
+	var parts []string
+	for _, part := range []string{item.Size, item.Color} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, item.SKU)
+	}
+	return fmt.Sprintf("%s (%s)", item.ProductName, strings.Join(parts, " / "))
+}
+
+// stockThreshold: el del producto, si no el de la tienda, si no el de la config
+func stockThreshold(product *models.Product) int {
+	if product.LowStockThreshold != nil {
+		return *product.LowStockThreshold
+	}
+	if product.Store.LowStockThreshold != nil {
+		return *product.Store.LowStockThreshold
+	}
+	return config.Get().LowStockThreshold
+}
+
+// stockLevel devuelve "" si el stock esta bien
+func stockLevel(stock, threshold int) string {
+	switch {
+	case stock <= 0:
+		return models.StockLevelSoldOut
+	case stock <= threshold:
+		return models.StockLevelLow
+	}
+	return ""
+}
+
+func stockAlertKey(productID uuid.UUID, variantID *uuid.UUID) string {
+	if variantID == nil {
+		return productID.String()
+	}
+	return productID.String() + ":" + variantID.String()
+}
+
+// lowStockItems revisa productos y variantes activas de las tiendas pedidas (nil = todas).
+// Los archivados no cuentan; borradores si, pa poder reabastecer antes de publicar.
+func lowStockItems(db *gorm.DB, storeIDs []uuid.UUID) ([]LowStockItem, error) {
+	query := db.
+		Preload("Store").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Where("active = ?", true).Order("created_at ASC")
+		}).
+		Where("products.status <> ?", models.ProductArchived).
+		Where("products.store_id NOT IN (SELECT id FROM stores WHERE archived_at IS NOT NULL)")
+	if storeIDs != nil {
+		query = query.Where("products.store_id IN ?", storeIDs)
+	}
+
+	var products []models.Product
+	if err := query.Order("products.name ASC").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	items := []LowStockItem{}
+	for i := range products {
+		product := &products[i]
+		threshold := stockThreshold(product)
+		base := LowStockItem{
+			StoreID:     product.StoreID,
+			StoreName:   product.Store.Name,
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Threshold:   threshold,
+		}
+
+		if level := stockLevel(product.AvailableUnits, threshold); level != "" {
+			item := base
+			item.Stock = product.AvailableUnits
+			item.Level = level
+			items = append(items, item)
+		}
+
+		for _, variant := range product.Variants {
+			level := stockLevel(variant.Stock, threshold)
+			if level == "" {
+				continue
+			}
+			variantID := variant.ID
+			item := base
+			item.VariantID = &variantID
+			item.SKU = variant.SKU
+			item.Size = variant.Size
+			item.Color = variant.Color
+			item.Stock = variant.Stock
+			item.Level = level
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// ListLowStock lista lo que esta bajo el umbral ahora mismo en las tiendas del staff
+func ListLowStock(access *StaffAccess, storeID *uuid.UUID) ([]LowStockItem, error) {
+	var storeIDs []uuid.UUID
+	if storeID != nil {
+		if !access.CanManageStore(*storeID) {
+			return nil, ErrForbidden
+		}
+		storeIDs = []uuid.UUID{*storeID}
+	} else if !access.IsAdmin() {
+		storeIDs = append([]uuid.UUID{}, access.StoreIDs...)
+	}
+
+	items, err := lowStockItems(database.DB, storeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Le pegamos cuando se aviso, si ya se aviso
+	var alerts []models.StockAlert
+	if err := database.DB.Where("resolved_at IS NULL").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock alerts: %w", err)
+	}
+	notified := make(map[string]*time.Time, len(alerts))
+	for _, alert := range alerts {
+		notified[stockAlertKey(alert.ProductID, alert.VariantID)] = alert.NotifiedAt
+	}
+	for i := range items {
+		items[i].NotifiedAt = notified[stockAlertKey(items[i].ProductID, items[i].VariantID)]
+	}
+
+	return items, nil
+}
+
+// EvaluateStockAlerts abre alertas pa los cruces nuevos y cierra las que ya se recuperaron.
+// Pasar de low a sold_out cuenta como cruce nuevo y se vuelve a avisar.
+func EvaluateStockAlerts(now time.Time) (opened, resolved int, err error) {
+	items, err := lowStockItems(database.DB, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var alerts []models.StockAlert
+		if err := tx.Where("resolved_at IS NULL").Find(&alerts).Error; err != nil {
+			return fmt.Errorf("failed to fetch stock alerts: %w", err)
+		}
+		open := make(map[string]*models.StockAlert, len(alerts))
+		for i := range alerts {
+			open[stockAlertKey(alerts[i].ProductID, alerts[i].VariantID)] = &alerts[i]
+		}
+
+		for _, item := range items {
+			key := stockAlertKey(item.ProductID, item.VariantID)
+			alert, ok := open[key]
+			delete(open, key)
+
+			if !ok {
+				alert := &models.StockAlert{
+					StoreID:   item.StoreID,
+					ProductID: item.ProductID,
+					VariantID: item.VariantID,
+					Level:     item.Level,
+					Stock:     item.Stock,
+					Threshold: item.Threshold,
+				}
+				if err := tx.Create(alert).Error; err != nil {
+					return fmt.Errorf("failed to create stock alert: %w", err)
+				}
+				opened++
+				continue
+			}
+
+			if alert.Level == item.Level && alert.Stock == item.Stock && alert.Threshold == item.Threshold {
+				continue
+			}
+			updates := map[string]interface{}{
+				"level":     item.Level,
+				"stock":     item.Stock,
+				"threshold": item.Threshold,
+			}
+			if alert.Level == models.StockLevelLow && item.Level == models.StockLevelSoldOut {
+				updates["notified_at"] = nil
+				opened++
+			}
+			if err := tx.Model(alert).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update stock alert: %w", err)
+			}
+		}
+
+		// Lo que quedo abierto ya no esta bajo el umbral
+		for _, alert := range open {
+			if err := tx.Model(alert).Update("resolved_at", now).Error; err != nil {
+				return fmt.Errorf("failed to resolve stock alert: %w", err)
+			}
+			resolved++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return opened, resolved, nil
+}
+
+// stockAlertRecipients son los correos del staff de la tienda; si no tiene, los admins
+func stockAlertRecipients(storeID uuid.UUID) ([]string, error) {
+	var emails []string
+	if err := database.DB.Model(&models.User{}).
+		Joins("JOIN store_staffs ON store_staffs.user_id = users.id").
+		Where("store_staffs.store_id = ? AND users.email IS NOT NULL", storeID).
+		Pluck("users.email", &emails).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch store staff: %w", err)
+	}
+	if len(emails) > 0 {
+		return emails, nil
+	}
+
+	if err := database.DB.Model(&models.User{}).
+		Where("role = ? AND email IS NOT NULL", models.RoleAdmin).
+		Pluck("email", &emails).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch admins: %w", err)
+	}
+	return emails, nil
+}
+
+// SendStockDigests manda un correo por tienda con las alertas que aun no se avisaron
+func SendStockDigests(now time.Time) (int, error) {
+	var alerts []models.StockAlert
+	if err := database.DB.
+		Preload("Store").
+		Preload("Product").
+		Preload("Variant").
+		Where("resolved_at IS NULL AND notified_at IS NULL").
+		Order("level DESC, created_at ASC"). // sold_out primero
+		Find(&alerts).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch stock alerts: %w", err)
+	}
+
+	byStore := make(map[uuid.UUID][]models.StockAlert)
+	var storeOrder []uuid.UUID
+	for _, alert := range alerts {
+		if _, ok := byStore[alert.StoreID]; !ok {
+			storeOrder = append(storeOrder, alert.StoreID)
+		}
+		byStore[alert.StoreID] = append(byStore[alert.StoreID], alert)
+	}
+
+	sent := 0
+	for _, storeID := range storeOrder {
+		storeAlerts := byStore[storeID]
+		recipients, err := stockAlertRecipients(storeID)
+		if err != nil {
+			return sent, err
+		}
+
+		var lines []map[string]interface{}
+		ids := make([]uuid.UUID, 0, len(storeAlerts))
+		for _, alert := range storeAlerts {
+			item := LowStockItem{ProductName: alert.Product.Name, VariantID: alert.VariantID}
+			if alert.Variant != nil {
+				item.SKU, item.Size, item.Color = alert.Variant.SKU, alert.Variant.Size, alert.Variant.Color
+			}
+			lines = append(lines, map[string]interface{}{
+				"name":      item.label(),
+				"stock":     alert.Stock,
+				"threshold": alert.Threshold,
+				"level":     alert.Level,
+			})
+			ids = append(ids, alert.ID)
+		}
+
+		storeName := storeAlerts[0].Store.Name
+		if len(recipients) == 0 {
+			// Nadie a quien avisarle; queda en el endpoint del admin y se reintenta cuando haya
+			log.Printf("Warning: no recipients for low stock digest of %s", storeName)
+			continue
+		}
+		delivered := false
+		for _, to := range recipients {
+			if err := utils.SendLowStockDigestEmail(to, storeName, lines); err != nil {
+				log.Printf("Warning: failed to send low stock digest to %s: %v", to, err)
+				continue
+			}
+			delivered = true
+		}
+		// Si el SMTP estaba caido no marcamos nada, la proxima vuelta lo reintenta
+		if !delivered {
+			continue
+		}
+
+		if err := database.DB.Model(&models.StockAlert{}).
+			Where("id IN ?", ids).
+			Update("notified_at", now).Error; err != nil {
+			return sent, fmt.Errorf("failed to mark stock alerts: %w", err)
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// StartStockAlertWorker revisa el stock cada interval y manda los digest.
+// Devuelve una funcion pa pararlo.
+func StartStockAlertWorker(interval time.Duration) func() {
+	run := func() {
+		now := time.Now()
+		opened, resolved, err := EvaluateStockAlerts(now)
+		if err != nil {
+			log.Printf("Warning: failed to evaluate stock alerts: %v", err)
+			return
+		}
+		sent, err := SendStockDigests(now)
+		if err != nil {
+			log.Printf("Warning: failed to send stock digests: %v", err)
+		}
+		if opened > 0 || resolved > 0 || sent > 0 {
+			log.Printf("✓ Stock alerts: %d opened, %d resolved, %d digests sent", opened, resolved, sent)
+		}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				run()
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrProductInStock: no hay nada que esperar, se puede comprar ya
+var ErrProductInStock = errors.New("product is in stock")
+
+// restockMu evita que dos corridas seguidas (sync + admin) avisen a la misma gente
+var restockMu sync.Mutex
+
+// activeVariants precarga solo las variantes que se venden
+func activeVariants(db *gorm.DB) *gorm.DB {
+	return db.Where("active = ?", true)
+}
+
+// availableFor devuelve el stock de la talla (o el total si size viene vacio)
+// y si la talla existe en el producto
+func availableFor(product *models.Product, size string) (int, bool) {
+	if size == "" {
+		return product.AvailableUnits, true
+	}
+
+	found := false
+	total := 0
+	for _, variant := range product.Variants {
+		if strings.EqualFold(variant.Size, size) {
+			found = true
+			total += variant.Stock
+		}
+	}
+	return total, found
+}
+
+// stockIncreased dice si alguna talla (contando solo variantes activas) subio de stock
+func stockIncreased(before, after *models.Product) bool {
+	previous := make(map[string]int)
+	for _, variant := range before.Variants {
+		if variant.Active {
+			previous[strings.ToLower(variant.Size)] += variant.Stock
+		}
+	}
+
+	current := make(map[string]int)
+	for _, variant := range after.Variants {
+		if variant.Active {
+			current[strings.ToLower(variant.Size)] += variant.Stock
+		}
+	}
+
+	for size, stock := range current {
+		if stock > previous[size] {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeBackInStock anota el correo pa avisar cuando el producto (o la talla) vuelva.
+// Si ya estaba anotado devuelve la misma suscripcion.
+func SubscribeBackInStock(productID uuid.UUID, userID *uuid.UUID, email, size string) (*models.StockSubscription, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	size = strings.TrimSpace(size)
+
+	// Logueado sin correo en la peti: usamos el de la cuenta
+	if email == "" && userID != nil {
+		var user models.User
+		if err := database.DB.First(&user, "id = ?", *userID).Error; err == nil && user.Email != nil {
+			email = strings.ToLower(*user.Email)
+		}
+	}
+	if !utils.ValidateEmail(email) {
+		return nil, fmt.Errorf("a valid email is required")
+	}
+
+	var product models.Product
+	if err := database.DB.Scopes(visibleProducts).
+		Preload("Variants", activeVariants).
+		First(&product, "id = ?", productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+
+	available, found := availableFor(&product, size)
+	if !found {
+		return nil, fmt.Errorf("size %s is not available for this product", size)
+	}
+	if available > 0 {
+		return nil, ErrProductInStock
+	}
+
+	// Aca el stock esta en 0: arrancamos la marca desde ahi pa que la proxima subida cuente entera
+	if err := saveRestockWatermark(database.DB, &models.RestockWatermark{ProductID: product.ID, Size: strings.ToLower(size)}); err != nil {
+		return nil, err
+	}
+
+	var existing models.StockSubscription
+	err := database.DB.
+		Where("product_id = ? AND LOWER(size) = LOWER(?) AND email = ? AND notified_at IS NULL", product.ID, size, email).
+		First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+
+	subscription := &models.StockSubscription{
+		ProductID: product.ID,
+		Size:      size,
+		Email:     email,
+		UserID:    userID,
+	}
+	if err := database.DB.Create(subscription).Error; err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// saveRestockWatermark crea o pisa la marca del producto/talla
+func saveRestockWatermark(db *gorm.DB, watermark *models.RestockWatermark) error {
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "size"}},
+		DoUpdates: clause.AssignmentColumns([]string{"stock", "remaining", "updated_at"}),
+	}).Create(watermark).Error
+	if err != nil {
+		return fmt.Errorf("failed to save restock watermark: %w", err)
+	}
+	return nil
+}
+
+// resetRestockWatermarks pone en 0 la marca de los productos que vuelven a la vitrina:
+// el stock que tenian guardado cuenta como reposicion. productIDs puede ser subquery.
+func resetRestockWatermarks(db *gorm.DB, productIDs interface{}) error {
+	if err := db.Model(&models.RestockWatermark{}).
+		Where("product_id IN (?)", productIDs).
+		Updates(map[string]interface{}{"stock": 0, "remaining": 0, "updated_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("failed to reset restock watermarks: %w", err)
+	}
+	return nil
+}
+
+// lowerRestockWatermarks baja la marca cuando el stock baja (ventas, ajustes), asi la
+// proxima reposicion cuenta desde lo mas bajo que llego y no desde el stock viejo
+func lowerRestockWatermarks(tx *gorm.DB, productID uuid.UUID, bySize map[string]int, total int) error {
+	var watermarks []models.RestockWatermark
+	if err := tx.Where("product_id = ?", productID).Find(&watermarks).Error; err != nil {
+		return fmt.Errorf("failed to fetch restock watermarks: %w", err)
+	}
+
+	for i := range watermarks {
+		watermark := &watermarks[i]
+		current := total
+		if watermark.Size != "" {
+			current = bySize[watermark.Size]
+		}
+		if current >= watermark.Stock {
+			continue
+		}
+
+		watermark.Stock = current
+		if watermark.Remaining > current {
+			watermark.Remaining = current
+		}
+		if err := saveRestockWatermark(tx, watermark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyBackInStock avisa a los suscritos de lo que volvio a tener stock.
+// Por producto/talla el lote es de maximo tantas personas como unidades repusieron
+// (la subida de stock menos los ya avisados por esa reposicion), en orden de
+// llegada; los que no alcanzan se quedan pa la proxima reposicion.
+func NotifyBackInStock() (int, error) {
+	restockMu.Lock()
+	defer restockMu.Unlock()
+
+	var pending []models.StockSubscription
+	if err := database.DB.
+		Where("notified_at IS NULL").
+		Where("product_id IN (?)", database.DB.Model(&models.Product{}).
+			Scopes(visibleProducts).
+			Select("products.id")).
+		Order("created_at ASC").
+		Find(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch stock subscriptions: %w", err)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	// Agrupamos por producto + talla sin perder el orden de llegada
+	groups := make(map[string][]models.StockSubscription)
+	var order []string
+	productIDs := make(map[uuid.UUID]bool)
+	for _, subscription := range pending {
+		key := subscription.ProductID.String() + "|" + strings.ToLower(subscription.Size)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], subscription)
+		productIDs[subscription.ProductID] = true
+	}
+
+	ids := make([]uuid.UUID, 0, len(productIDs))
+	for id := range productIDs {
+		ids = append(ids, id)
+	}
+	var products []models.Product
+	if err := database.DB.
+		Preload("Store").
+		Preload("Variants", activeVariants).
+		Where("id IN ?", ids).
+		Find(&products).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	byID := make(map[uuid.UUID]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	now := time.Now()
+	notified := 0
+	for _, key := range order {
+		subscriptions := groups[key]
+		product, ok := byID[subscriptions[0].ProductID]
+		if !ok {
+			continue
+		}
+
+		// Comparamos con la ultima marca: solo lo que subio cuenta como reposicion.
+		// Si vendieron, lo pendiente no puede pasar del stock que queda.
+		size := strings.ToLower(subscriptions[0].Size)
+		available, _ := availableFor(product, size)
+		watermark := models.RestockWatermark{ProductID: product.ID, Size: size}
+		if err := database.DB.Where("product_id = ? AND size = ?", product.ID, size).Limit(1).Find(&watermark).Error; err != nil {
+			return notified, fmt.Errorf("failed to fetch restock watermark: %w", err)
+		}
+		if available > watermark.Stock {
+			watermark.Remaining += available - watermark.Stock
+		}
+		if watermark.Remaining > available {
+			watermark.Remaining = available
+		}
+		watermark.Stock = available
+
+		if watermark.Remaining < len(subscriptions) {
+			subscriptions = subscriptions[:watermark.Remaining]
+		}
+
+		for _, subscription := range subscriptions {
+			// Reclamamos la fila antes de mandar, asi nadie recibe dos correos por la misma reposicion
+			result := database.DB.Model(&models.StockSubscription{}).
+				Where("id = ? AND notified_at IS NULL", subscription.ID).
+				Update("notified_at", now)
+			if result.Error != nil {
+				return notified, fmt.Errorf("failed to update stock subscription: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			if err := utils.SendBackInStockEmail(subscription.Email, product.Name, subscription.Size, productPageURL(product)); err != nil {
+				log.Printf("Warning: failed to send back in stock email to %s: %v", subscription.Email, err)
+			}
+			watermark.Remaining--
+			notified++
+		}
+
+		if err := saveRestockWatermark(database.DB, &watermark); err != nil {
+			return notified, err
+		}
+	}
+
+	return notified, nil
+}
+
+// notifyBackInStockAsync corre los avisos sin frenar la respuesta del admin o del sync
+func notifyBackInStockAsync() {
+	go func() {
+		notified, err := NotifyBackInStock()
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		if notified > 0 {
+			log.Printf("✓ Sent %d back in stock notifications", notified)
+		}
+	}()
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrStoreInUse sale al borrar una tienda que todavia tiene productos o promos con historial
+var ErrStoreInUse = errors.New("store still has products or promotions")
+
+// maxStoreBanners es el tope del carrusel de cada tienda
+const maxStoreBanners = 10
+
+// StoreProfile es una tienda en assets/stores.json: branding y contacto de cada marca.
+// Pa sumar una marca nueva basta con agregarla ahi (o crearla desde el admin).
+type StoreProfile struct {
+	Name               string            `json:"name"`
+	Slug               string            `json:"slug"`
+	Description        string            `json:"description"`
+	LogoPath           string            `json:"logo_path"`
+	Banners            []string          `json:"banners"`
+	ContactEmail       string            `json:"contact_email"`
+	ContactPhone       string            `json:"contact_phone"`
+	WhatsApp           string            `json:"whatsapp"`
+	SocialLinks        map[string]string `json:"social_links"`
+	ShippingOriginCity string            `json:"shipping_origin_city"`
+	ShippingPolicy     string            `json:"shipping_policy"`
+	ReturnPolicy       string            `json:"return_policy"`
+	SortOrder          int               `json:"sort_order"`
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// validateStoreInput limpia y revisa los campos de la tienda
+func validateStoreInput(in *StoreInput) error {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Slug = strings.TrimSpace(in.Slug)
+	in.LogoPath = strings.TrimSpace(in.LogoPath)
+	in.ContactEmail = strings.ToLower(strings.TrimSpace(in.ContactEmail))
+	in.ContactPhone = strings.TrimSpace(in.ContactPhone)
+	in.WhatsApp = strings.TrimSpace(in.WhatsApp)
+	in.ShippingOriginCity = strings.TrimSpace(in.ShippingOriginCity)
+
+	if in.Name == "" || len(in.Name) > 255 {
+		return fmt.Errorf("name is required and must be at most 255 characters")
+	}
+	if len(in.Slug) > 100 {
+		return fmt.Errorf("slug must be at most 100 characters")
+	}
+	if len(in.LogoPath) > 255 {
+		return fmt.Errorf("logo path must be at most 255 characters")
+	}
+	if len(in.Banners) > maxStoreBanners {
+		return fmt.Errorf("a store can have at most %d banners", maxStoreBanners)
+	}
+	for i, banner := range in.Banners {
+		in.Banners[i] = strings.TrimSpace(banner)
+		if in.Banners[i] == "" || len(in.Banners[i]) > 255 {
+			return fmt.Errorf("banner paths are required and must be at most 255 characters")
+		}
+	}
+	if in.ContactEmail != "" && !utils.ValidateEmail(in.ContactEmail) {
+		return fmt.Errorf("contact_email is not a valid email")
+	}
+	if len(in.ContactPhone) > 20 || len(in.WhatsApp) > 20 {
+		return fmt.Errorf("phone numbers must be at most 20 characters")
+	}
+	for network, link := range in.SocialLinks {
+		if network == "" || len(network) > 30 || strings.TrimSpace(link) == "" || len(link) > 255 {
+			return fmt.Errorf("social links need a network name (max 30) and a link (max 255)")
+		}
+		in.SocialLinks[network] = strings.TrimSpace(link)
+	}
+	if len(in.ShippingOriginCity) > 100 {
+		return fmt.Errorf("shipping_origin_city must be at most 100 characters")
+	}
+	if in.LowStockThreshold != nil && *in.LowStockThreshold < 0 {
+		return fmt.Errorf("low_stock_threshold must be zero or positive")
+	}
+	return nil
+}
+
+// checkStoreName evita dos tiendas con el mismo nombre (sin importar mayusculas)
+func checkStoreName(tx *gorm.DB, storeID uuid.UUID, name string) error {
+	var count int64
+	if err := tx.Model(&models.Store{}).
+		Where("LOWER(name) = LOWER(?) AND id <> ?", name, storeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check store name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("store %q already exists", name)
+	}
+	return nil
+}
+
+// uniqueStoreSlug arma el slug de la tienda y le suma -2, -3... si ya esta usado
+func uniqueStoreSlug(tx *gorm.DB, storeID uuid.UUID, base string) (string, error) {
+	base, err := utils.NewSlug(base)
+	if err != nil {
+		return "", err
+	}
+
+	slug := base
+	for n := 2; ; n++ {
+		var taken int64
+		if err := tx.Model(&models.Store{}).
+			Where("slug = ? AND id <> ?", slug, storeID).
+			Count(&taken).Error; err != nil {
+			return "", fmt.Errorf("failed to check store slug: %w", err)
+		}
+		if taken == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// createStoreRecord crea la tienda pelada (nombre + slug); el resto se llena despues
+func createStoreRecord(name, slug string) (*models.Store, error) {
+	store := &models.Store{ID: uuid.New(), Name: name, Version: 1}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		unique, err := uniqueStoreSlug(tx, store.ID, firstNonEmpty(slug, name))
+		if err != nil {
+			return err
+		}
+		store.Slug = unique
+		store.SetBanners([]string{})
+		store.SetSocialLinks(map[string]string{})
+		if err := tx.Create(store).Error; err != nil {
+			return fmt.Errorf("failed to create store %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// FindStore busca la tienda por slug o, pa los links viejos, por nombre
+func FindStore(ref string) (*models.Store, error) {
+	ref = strings.TrimSpace(ref)
+	var store models.Store
+	if err := database.DB.
+		Where("slug = ? OR LOWER(name) = ?", utils.Slugify(ref), strings.ToLower(ref)).
+		First(&store).Error; err != nil {
+		return nil, fmt.Errorf("store %q not found", ref)
+	}
+	return &store, nil
+}
+
+// ListStores trae las tiendas visibles en el orden de la vitrina
+func ListStores() ([]models.Store, error) {
+	var stores []models.Store
+	if err := database.DB.
+		Where("archived_at IS NULL").
+		Order("sort_order ASC, name ASC").
+		Find(&stores).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stores: %w", err)
+	}
+	return stores, nil
+}
+
+// GetStoreBySlug trae una tienda visible por su slug
+func GetStoreBySlug(slug string) (*models.Store, error) {
+	var store models.Store
+	if err := database.DB.
+		First(&store, "slug = ? AND archived_at IS NULL", strings.ToLower(strings.TrimSpace(slug))).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+	return &store, nil
+}
+
+// GetAdminStore trae una tienda (archivada o no) si el staff la puede ver
+func GetAdminStore(access *StaffAccess, storeID uuid.UUID) (*models.Store, error) {
+	if !access.CanManageStore(storeID) {
+		return nil, ErrForbidden
+	}
+	var store models.Store
+	if err := database.DB.First(&store, "id = ?", storeID).Error; err != nil {
+		return nil, fmt.Errorf("store not found")
+	}
+	return &store, nil
+}
+
+// DeleteStore borra una tienda vacia (solo admin); si tiene productos toca archivarla
+func DeleteStore(access *StaffAccess, storeID uuid.UUID) error {
+	if !access.IsAdmin() {
+		return ErrForbidden
+	}
+	store, err := GetAdminStore(access, storeID)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.Product{}, &models.Promotion{}} {
+			var count int64
+			if err := tx.Model(model).Where("store_id = ?", store.ID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check store usage: %w", err)
+			}
+			if count > 0 {
+				return ErrStoreInUse
+			}
+		}
+
+		// Lo que cuelga de la tienda sin productos: staff, categorias, colecciones, guias
+		for _, model := range []interface{}{&models.StoreStaff{}, &models.Category{}, &models.Collection{}, &models.SizeChart{}} {
+			if err := tx.Where("store_id = ?", store.ID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete store data: %w", err)
+			}
+		}
+		if err := tx.Delete(&models.Store{}, "id = ?", store.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete store: %w", err)
+		}
+		return nil
+	})
+}
+
+// StoreProfilesPath es assets/stores.json (asumimos backend/ como cwd, igual que el catalogo)
+func StoreProfilesPath() string {
+	return filepath.Join(filepath.Dir(CatalogDir()), "stores.json")
+}
+
+// EnsureStoreProfiles crea las tiendas de assets/stores.json que falten y les llena
+// el branding vacio. Lo que ya se edito desde el admin no se pisa.
+func EnsureStoreProfiles(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var profiles []StoreProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, profile := range profiles {
+		if err := ensureStoreProfile(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureStoreProfile(profile StoreProfile) error {
+	in := StoreInput{
+		Name:               profile.Name,
+		Slug:               profile.Slug,
+		Description:        profile.Description,
+		LogoPath:           profile.LogoPath,
+		Banners:            profile.Banners,
+		ContactEmail:       profile.ContactEmail,
+		ContactPhone:       profile.ContactPhone,
+		WhatsApp:           profile.WhatsApp,
+		SocialLinks:        profile.SocialLinks,
+		ShippingOriginCity: profile.ShippingOriginCity,
+		ShippingPolicy:     profile.ShippingPolicy,
+		ReturnPolicy:       profile.ReturnPolicy,
+		SortOrder:          profile.SortOrder,
+	}
+	if err := validateStoreInput(&in); err != nil {
+		return fmt.Errorf("store profile %q: %w", profile.Name, err)
+	}
+
+	store, err := FindStore(firstNonEmpty(in.Slug, in.Name))
+	if err != nil {
+		store, err = createStoreRecord(in.Name, in.Slug)
+		if err != nil {
+			return err
+		}
+		log.Printf("✓ Store %s created from store profiles", store.Name)
+	}
+
+	updates := make(map[string]interface{})
+	fill := func(column, current, value string) {
+		if current == "" && value != "" {
+			updates[column] = value
+		}
+	}
+	fill("description", store.Description, in.Description)
+	fill("logo_path", store.LogoPath, in.LogoPath)
+	fill("contact_email", store.ContactEmail, in.ContactEmail)
+	fill("contact_phone", store.ContactPhone, in.ContactPhone)
+	fill("whatsapp", store.WhatsApp, in.WhatsApp)
+	fill("shipping_origin_city", store.ShippingOriginCity, in.ShippingOriginCity)
+	fill("shipping_policy", store.ShippingPolicy, in.ShippingPolicy)
+	fill("return_policy", store.ReturnPolicy, in.ReturnPolicy)
+
+	if banners, _ := store.GetBanners(); len(banners) == 0 && len(in.Banners) > 0 {
+		var encoded models.Store
+		if err := encoded.SetBanners(in.Banners); err != nil {
+			return fmt.Errorf("failed to encode banners: %w", err)
+		}
+		updates["banners"] = encoded.Banners
+	}
+	if links, _ := store.GetSocialLinks(); len(links) == 0 && len(in.SocialLinks) > 0 {
+		var encoded models.Store
+		if err := encoded.SetSocialLinks(in.SocialLinks); err != nil {
+			return fmt.Errorf("failed to encode social links: %w", err)
+		}
+		updates["social_links"] = encoded.SocialLinks
+	}
+	if store.SortOrder == 0 && in.SortOrder != 0 {
+		updates["sort_order"] = in.SortOrder
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	updates["version"] = gorm.Expr("version + 1")
+	if err := database.DB.Model(&models.Store{}).Where("id = ?", store.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update store %s: %w", store.Name, err)
+	}
+	log.Printf("✓ Store profile for %s synced", store.Name)
+	return nil
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/config"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ErrImageTooLarge sale cuando el archivo pasa de MaxUploadSize
+var ErrImageTooLarge = errors.New("image exceeds the maximum upload size")
+
+// uploadsSubdir es la carpeta dentro de UploadDir donde quedan las subidas
+const uploadsSubdir = "uploads"
+
+// storeUploadedImage limpia la imagen y la guarda con nombre = hash del contenido
+// Devuelve el path relativo a UploadDir
+func storeUploadedImage(r io.Reader) (string, string, int64, error) {
+	cfg := config.Get()
+
+	// Leemos maximo MaxUploadSize + 1 pa saber si se paso
+	data, err := io.ReadAll(io.LimitReader(r, cfg.MaxUploadSize+1))
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > cfg.MaxUploadSize {
+		return "", "", 0, ErrImageTooLarge
+	}
+
+	// Re-codificamos pa botar EXIF y cualquier cosa rara que venga pegada
+	clean, contentType, err := utils.StripImageMetadata(data)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	sum := sha256.Sum256(clean)
+	name := hex.EncodeToString(sum[:]) + utils.ImageExtension(contentType)
+	relPath := path.Join(uploadsSubdir, name)
+	fullPath := filepath.Join(cfg.UploadDir, uploadsSubdir, name)
+
+	// Si ya existe el mismo contenido, no lo escribimos otra vez
+	if _, err := os.Stat(fullPath); err == nil {
+		return relPath, contentType, int64(len(clean)), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return "", "", 0, fmt.Errorf("failed to create upload dir: %w", err)
+	}
+
+	// Escribimos a un temporal y renombramos pa no dejar archivos a medias
+	tmp, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to save image: %w", err)
+	}
+	if _, err := tmp.Write(clean); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", "", 0, fmt.Errorf("failed to save image: %w", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), fullPath); err != nil {
+		os.Remove(tmp.Name())
+		return "", "", 0, fmt.Errorf("failed to save image: %w", err)
+	}
+
+	return relPath, contentType, int64(len(clean)), nil
+}
+
+// UploadProductImage sube una imagen y la agrega a la galeria del producto
+func UploadProductImage(access *StaffAccess, productID uuid.UUID, r io.Reader, primary bool) (*models.ProductUpload, error) {
+	product, err := GetAdminProduct(access, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	relPath, contentType, size, err := storeUploadedImage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &models.ProductUpload{
+		ID:          uuid.New(),
+		ProductID:   product.ID,
+		UploadedBy:  access.UserID,
+		Path:        relPath,
+		ContentType: contentType,
+		Size:        size,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(upload).Error; err != nil {
+			return fmt.Errorf("failed to register upload: %w", err)
+		}
+		if err := appendProductImage(tx, product.ID, relPath, primary); err != nil {
+			return err
+		}
+		return bumpProductVersion(tx, product.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// isImageReferenced dice si algun producto (principal o galeria), banner o foto de resena
+// todavia usa ese path. Los nombres son el hash del contenido, asi que se comparten.
+func isImageReferenced(relPath string) (bool, error) {
+	var count int64
+	if err := database.DB.Model(&models.Product{}).Where("image_path = ?", relPath).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := database.DB.Model(&models.ProductImage{}).Where("path = ?", relPath).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := database.DB.Model(&models.Collection{}).Where("banner_path = ?", relPath).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := database.DB.Model(&models.ReviewPhoto{}).Where("path = ?", relPath).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// removeImageIfUnused borra la subida del disco si nadie la referencia; true si la borro
+func removeImageIfUnused(relPath string) (bool, error) {
+	referenced, err := isImageReferenced(relPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to check image references: %w", err)
+	}
+	if referenced {
+		return false, nil
+	}
+
+	fullPath := filepath.Join(config.Get().UploadDir, filepath.FromSlash(relPath))
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove orphaned image %s: %v", relPath, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// cleanupImages intenta borrar varias subidas; aca no hay a quien devolverle el error
+func cleanupImages(paths []string) {
+	for _, relPath := range paths {
+		if _, err := removeImageIfUnused(relPath); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// CleanupProductUploads borra las subidas del producto que ya nadie referencia.
+// La galeria actual se queda pa que el producto se pueda restaurar con fotos.
+func CleanupProductUploads(productID uuid.UUID) error {
+	var uploads []models.ProductUpload
+	if err := database.DB.Where("product_id = ?", productID).Find(&uploads).Error; err != nil {
+		return fmt.Errorf("failed to fetch uploads: %w", err)
+	}
+
+	for _, upload := range uploads {
+		removed, err := removeImageIfUnused(upload.Path)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		if err := database.DB.Delete(&models.ProductUpload{}, "path = ?", upload.Path).Error; err != nil {
+			return fmt.Errorf("failed to remove upload record: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/leunameek/celestexmewave/internal/database"
+	"github.com/leunameek/celestexmewave/internal/utils"
+	"github.com/leunameek/celestexmewave/models"
+	"gorm.io/gorm"
+)
+
+// ProductVariantJSON es la forma de una variante en el JSON
+type ProductVariantJSON struct {
+	Size    string   `json:"size"`
+	Color   string   `json:"color"`
+	SKU     string   `json:"sku"`
+	Barcode string   `json:"barcode"`
+	Stock   int      `json:"stock"`
+	Price   *float64 `json:"price"`
+
+	imported bool // viene del archivo del catalogo: el stock se aplica solo si cambio
+}
+
+// variantsFromJSON devuelve las variantes del producto
+// Si el JSON viejo no trae variantes, las armamos desde sizes repartiendo available_units
+func variantsFromJSON(p ProductJSON) []ProductVariantJSON {
+	if len(p.Variants) > 0 {
+		return p.Variants
+	}
+	return splitStockBySize(p.Sizes, p.AvailableUnits)
+}
+
+// splitStockBySize reparte el stock parejo entre tallas, el sobrante va a las primeras
+func splitStockBySize(sizes []string, units int) []ProductVariantJSON {
+	if len(sizes) == 0 {
+		return []ProductVariantJSON{{Stock: units}}
+	}
+
+	variants := make([]ProductVariantJSON, 0, len(sizes))
+	per := units / len(sizes)
+	extra := units % len(sizes)
+	for i, size := range sizes {
+		stock := per
+		if i < extra {
+			stock++
+		}
+		variants = append(variants, ProductVariantJSON{Size: size, Stock: stock})
+	}
+	return variants
+}
+
+// catalogStockChanged dice si el stock del archivo hay que aplicarlo a la variante:
+// si el archivo trae el mismo numero del ultimo sync, manda la DB (con las ventas)
+func catalogStockChanged(variant *models.ProductVariant, fileStock int) bool {
+	if !variant.Active || variant.ImportedStock == nil {
+		return true
+	}
+	return *variant.ImportedStock != fileStock
+}
+
+// generateSKU arma un SKU legible: TIENDA-PRODUCTO-TALLA-COLOR
+func generateSKU(storeName, productName, size, color string) string {
+	prefix := strings.ToUpper(utils.Slugify(storeName))
+	if len(prefix) > 3 {
+		prefix = prefix[:3]
+	}
+
+	parts := []string{prefix, utils.Slugify(productName)}
+	if size != "" {
+		parts = append(parts, utils.Slugify(size))
+	}
+	if color != "" {
+		parts = append(parts, utils.Slugify(color))
+	}
+	return strings.ToUpper(strings.Join(parts, "-"))
+}
+
+// ErrSKUInUse sale cuando un SKU escrito a mano ya lo tiene otro producto (el indice es global)
+var ErrSKUInUse = errors.New("sku already in use")
+
+// skuTaken dice si otro producto ya usa ese SKU
+func skuTaken(tx *gorm.DB, sku string, productID uuid.UUID) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.ProductVariant{}).
+		Where("sku = ? AND product_id <> ?", sku, productID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check sku %s: %w", sku, err)
+	}
+	return count > 0, nil
+}
+
+// uniqueSKU le pega -2, -3... si otro producto ya usa ese SKU. El prefijo de la
+// tienda son 3 letras, asi que "Celeste" y "Celulares" pueden generar el mismo.
+func uniqueSKU(tx *gorm.DB, sku string, productID uuid.UUID) (string, error) {
+	candidate := sku
+	for n := 2; ; n++ {
+		taken, err := skuTaken(tx, candidate, productID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", sku, n)
+	}
+}
+
+// syncProductVariants crea/actualiza las variantes y desactiva las que ya no vienen
+// Tambien recalcula sizes y available_units del producto pa que cuadren con las variantes
+func syncProductVariants(tx *gorm.DB, product *models.Product, storeName string, variants []ProductVariantJSON) error {
+	var existing []models.ProductVariant
+	if err := tx.Where("product_id = ?", product.ID).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch variants: %w", err)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, v := range variants {
+		sku := strings.TrimSpace(v.SKU)
+		generated := sku == ""
+		if generated {
+			sku = generateSKU(storeName, product.Name, v.Size, v.Color)
+		}
+
+		// Buscamos por SKU y si no por talla/color
+		var match *models.ProductVariant
+		for i := range existing {
+			if existing[i].SKU == sku || (generated && existing[i].Size == v.Size && existing[i].Color == v.Color) {
+				match = &existing[i]
+				break
+			}
+		}
+
+		// El SKU generado puede chocar con el de otra tienda; si la variante ya
+		// tenia uno con sufijo se lo dejamos pa que no cambie en cada sync.
+		// Uno escrito a mano no lo cambiamos: si esta ocupado es error claro.
+		if !generated {
+			taken, err := skuTaken(tx, sku, product.ID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return fmt.Errorf("%w: %s", ErrSKUInUse, sku)
+			}
+		} else {
+			if match != nil && strings.HasPrefix(match.SKU, sku) {
+				sku = match.SKU
+			} else {
+				unique, err := uniqueSKU(tx, sku, product.ID)
+				if err != nil {
+					return err
+				}
+				sku = unique
+			}
+		}
+
+		if match == nil {
+			variant := &models.ProductVariant{
+				ID:        uuid.New(),
+				ProductID: product.ID,
+				Size:      v.Size,
+				Color:     v.Color,
+				SKU:       sku,
+				Barcode:   v.Barcode,
+				Stock:     v.Stock,
+				Price:     v.Price,
+				Active:    true,
+			}
+			if v.imported {
+				variant.ImportedStock = &v.Stock
+			}
+			if err := tx.Create(variant).Error; err != nil {
+				return fmt.Errorf("failed to create variant %s: %w", sku, err)
+			}
+			seen[variant.ID] = true
+			continue
+		}
+
+		match.Size = v.Size
+		match.Color = v.Color
+		match.SKU = sku
+		match.Barcode = v.Barcode
+		if !v.imported || catalogStockChanged(match, v.Stock) {
+			match.Stock = v.Stock
+		}
+		if v.imported {
+			fileStock := v.Stock
+			match.ImportedStock = &fileStock
+		}
+		match.Price = v.Price
+		match.Active = true
+		if err := tx.Save(match).Error; err != nil {
+			return fmt.Errorf("failed to update variant %s: %w", sku, err)
+		}
+		seen[match.ID] = true
+	}
+
+	// Las que no vinieron quedan inactivas y sin stock (no las borramos por los pedidos viejos)
+	for i := range existing {
+		if seen[existing[i].ID] || !existing[i].Active {
+			continue
+		}
+		if err := tx.Model(&existing[i]).Updates(map[string]interface{}{"active": false, "stock": 0}).Error; err != nil {
+			return fmt.Errorf("failed to deactivate variant %s: %w", existing[i].SKU, err)
+		}
+	}
+
+	return refreshProductStock(tx, product.ID)
+}
+
+// refreshProductStock recalcula available_units y sizes desde las variantes activas
+func refreshProductStock(tx *gorm.DB, productID uuid.UUID) error {
+	var variants []models.ProductVariant
+	if err := tx.Where("product_id = ? AND active = ?", productID, true).
+		Order("created_at ASC").
+		Find(&variants).Error; err != nil {
+		return fmt.Errorf("failed to fetch variants: %w", err)
+	}
+
+	total := 0
+	sizes := []string{}
+	seenSize := make(map[string]bool)
+	bySize := make(map[string]int)
+	for _, v := range variants {
+		total += v.Stock
+		bySize[strings.ToLower(v.Size)] += v.Stock
+		if v.Size != "" && !seenSize[v.Size] {
+			seenSize[v.Size] = true
+			sizes = append(sizes, v.Size)
+		}
+	}
+
+	sizesJSON, _ := json.Marshal(sizes)
+	if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+		"available_units": total,
+		"sizes":           sizesJSON,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update product stock: %w", err)
+	}
+	return lowerRestockWatermarks(tx, productID, bySize, total)
+}
+
+// EnsureProductVariants migra productos viejos sin variantes, usando sus sizes y available_units
+func EnsureProductVariants() error {
+	var products []models.Product
+	if err := database.DB.
+		Preload("Store").
+		Where("NOT EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id)").
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products without variants: %w", err)
+	}
+
+	for i := range products {
+		product := &products[i]
+		sizes, _ := product.GetSizes()
+		variants := splitStockBySize(sizes, product.AvailableUnits)
+		if err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return syncProductVariants(tx, product, product.Store.Name, variants)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetProductVariants trae las variantes activas de un producto
+func GetProductVariants(productID uuid.UUID) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	if err := database.DB.
+		Where("product_id = ? AND active = ?", productID, true).
+		Order("created_at ASC").
+		Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch variants: %w", err)
+	}
+	return variants, nil
+}
+
+// resolveVariant encuentra la variante pedida: por ID o por talla/color
+func resolveVariant(db *gorm.DB, productID uuid.UUID, variantID *uuid.UUID, size, color string) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+
+	if variantID != nil {
+		if err := db.Where("id = ? AND product_id = ? AND active = ?", *variantID, productID, true).
+			First(&variant).Error; err != nil {
+			return nil, fmt.Errorf("variant not found")
+		}
+		return &variant, nil
+	}
+
+	var candidates []models.ProductVariant
+	query := db.Where("product_id = ? AND active = ?", productID, true)
+	if size != "" {
+		query = query.Where("size = ?", size)
+	}
+	if color != "" {
+		query = query.Where("color = ?", color)
+	}
+	if err := query.Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch variants: %w", err)
+	}
+
+	switch len(candidates) {
+	case 0:
+		if size != "" {
+			return nil, fmt.Errorf("size %s not available for this product", size)
+		}
+		return nil, fmt.Errorf("variant not found")
+	case 1:
+		return &candidates[0], nil
+	default:
+		return nil, fmt.Errorf("please select a size or variant")
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/leunameek/celestexmewave/internal/config"
+)
+
+// cartTokenAudience separa los tokens de carrito de los de login: uno no sirve por el otro
+const cartTokenAudience = "cart"
+
+func cartTokenSecret() []byte {
+	cfg := config.Get()
+	if cfg.CartTokenSecret != "" {
+		return []byte(cfg.CartTokenSecret)
+	}
+	return []byte(cfg.JWTSecret)
+}
+
+// GenerateCartToken firma la sesion de invitado; el cliente nunca elige el session id
+func GenerateCartToken(sessionID string) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   sessionID,
+		Audience:  jwt.ClaimStrings{cartTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(config.Get().CartTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(cartTokenSecret())
+}
+
+// ValidateCartToken revisa firma, audiencia y vencimiento y devuelve el session id
+func ValidateCartToken(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return cartTokenSecret(), nil
+	}, jwt.WithAudience(cartTokenAudience))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid || claims.Subject == "" {
+		return "", fmt.Errorf("invalid cart token")
+	}
+	return claims.Subject, nil
+}
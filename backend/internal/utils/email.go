@@ -3,8 +3,6 @@ package utils
 import (
 	"fmt"
 	"net/smtp"
-	"strconv"
-	"strings"
 
 	"github.com/leunameek/celestexmewave/internal/config"
 )
@@ -31,23 +29,6 @@ func SendEmail(to, subject, body string) error {
 	return nil
 }
 
-func formatPriceColombian(price float64) string {
-	intPrice := int(price)
-	str := strconv.Itoa(intPrice)
-
-	var parts []string
-	for len(str) > 3 {
-		parts = append([]string{str[len(str)-3:]}, parts...)
-		str = str[:len(str)-3]
-	}
-	if str != "" {
-		parts = append([]string{str}, parts...)
-	}
-	result := strings.Join(parts, ".")
-
-	return result
-}
-
 func SendPasswordResetEmail(to, resetCode string) error {
 	subject := "Código de Restablecimiento de Contraseña - CelestexMewave"
 	body := fmt.Sprintf(`
@@ -74,11 +55,11 @@ func SendOrderConfirmationEmail(to, orderID string, totalAmount float64, items [
 	itemsHTML := ""
 	for _, item := range items {
 		price := item["unit_price"].(float64)
-		itemsHTML += fmt.Sprintf("- %v x %v (Talla: %v) - $%s\n",
+		itemsHTML += fmt.Sprintf("- %v x %v (Talla: %v) - %s\n",
 			item["product_name"],
 			item["quantity"],
 			item["size"],
-			formatPriceColombian(price),
+			FormatMoney(price, CurrencyCOP),
 		)
 	}
 
@@ -88,7 +69,7 @@ Hola,
 ¡Gracias por tu compra!
 
 ID del Pedido: %s
-Monto Total: $%s
+Monto Total: %s
 
 Productos:
 %s
@@ -97,7 +78,7 @@ Tu pedido ha sido confirmado y será procesado en breve.
 
 Saludos,
 Equipo de CelestexMewave
-`, orderID, formatPriceColombian(totalAmount), itemsHTML)
+`, orderID, FormatMoney(totalAmount, CurrencyCOP), itemsHTML)
 
 	return SendEmail(to, subject, body)
 }
@@ -117,3 +98,53 @@ Equipo de CelestexMewave
 
 	return SendEmail(to, subject, body)
 }
+
+func SendLowStockDigestEmail(to, storeName string, items []map[string]interface{}) error {
+	subject := fmt.Sprintf("Alerta de Inventario - %s", storeName)
+
+	itemsText := ""
+	for _, item := range items {
+		status := fmt.Sprintf("quedan %v (umbral %v)", item["stock"], item["threshold"])
+		if item["level"] == "sold_out" {
+			status = "AGOTADO"
+		}
+		itemsText += fmt.Sprintf("- %v: %s\n", item["name"], status)
+	}
+
+	body := fmt.Sprintf(`
+Hola,
+
+Estos productos de %s están bajos de inventario o agotados:
+
+%s
+Revisa el panel de administración para reabastecerlos.
+
+Saludos,
+Equipo de CelestexMewave
+`, storeName, itemsText)
+
+	return SendEmail(to, subject, body)
+}
+
+func SendBackInStockEmail(to, productName, size, link string) error {
+	subject := fmt.Sprintf("¡%s volvió! - CelestexMewave", productName)
+
+	item := productName
+	if size != "" {
+		item = fmt.Sprintf("%s (Talla: %s)", productName, size)
+	}
+
+	body := fmt.Sprintf(`
+Hola,
+
+Buenas noticias: %s ya está disponible otra vez.
+
+Las unidades son limitadas, así que no te demores:
+%s
+
+Saludos,
+Equipo de CelestexMewave
+`, item, link)
+
+	return SendEmail(to, subject, body)
+}
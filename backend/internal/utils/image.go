@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// ErrUnsupportedImage sale cuando el archivo no es JPEG ni PNG
+var ErrUnsupportedImage = errors.New("only JPEG and PNG images are allowed")
+
+// ErrImageDimensions sale cuando la imagen declara mas pixeles de los que dejamos decodificar
+var ErrImageDimensions = errors.New("image dimensions exceed the maximum allowed")
+
+// MaxImagePixels es el tope de ancho x alto (~40 MP). Un PNG chiquito puede decir
+// que mide 50000x50000 y al decodificarlo se come gigas de RAM.
+const MaxImagePixels = 40_000_000
+
+// allowedImageTypes son los tipos que aceptamos y su extension
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// SniffImageType mira los bytes (no la extension ni el header) pa saber el tipo real
+func SniffImageType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return "", ErrUnsupportedImage
+	}
+	return contentType, nil
+}
+
+// ImageExtension devuelve la extension que usamos pa ese tipo
+func ImageExtension(contentType string) string {
+	return allowedImageTypes[contentType]
+}
+
+// CheckImageDimensions lee solo el header y bota la imagen si pasa de MaxImagePixels
+func CheckImageDimensions(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return ErrImageDimensions
+	}
+	return nil
+}
+
+// DecodeImage decodifica la imagen completa, pero solo si las dimensiones dan
+func DecodeImage(data []byte) (image.Image, error) {
+	if err := CheckImageDimensions(data); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// StripImageMetadata decodifica y vuelve a codificar la imagen, asi se pierde
+// todo el EXIF (GPS, camara, etc). La orientacion EXIF se aplica antes pa que
+// la foto no quede de lado.
+func StripImageMetadata(data []byte) ([]byte, string, error) {
+	contentType, err := SniffImageType(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img, err := DecodeImage(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	out, err := EncodeImage(img, contentType)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, contentType, nil
+}
+
+// EncodeImage codifica en JPEG o PNG
+func EncodeImage(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 88}); err != nil {
+			return nil, err
+		}
+	case "image/png":
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	return buf.Bytes(), nil
+}
+
+// jpegOrientation busca el tag Orientation (0x0112) en el APP1 EXIF; 1 si no hay
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		size := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if marker == 0xDA || size < 2 || pos+2+size > len(data) {
+			return 1
+		}
+
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xE1 && len(segment) > 14 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+	return 1
+}
+
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation rota/voltea segun el valor EXIF (1-8)
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	swap := orientation >= 5
+	dw, dh := w, h
+	if swap {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// ResizeImage escala la imagen pa que quepa en width x height sin deformarla.
+// Si width o height es 0 se calcula con la proporcion. Nunca agranda.
+// Usa promedio por area (box filter), que se ve bien al reducir.
+func ResizeImage(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 {
+		return src
+	}
+
+	dw, dh := FitSize(sw, sh, width, height)
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	xRatio := float64(sw) / float64(dw)
+	yRatio := float64(sh) / float64(dh)
+
+	for y := 0; y < dh; y++ {
+		y0 := int(float64(y) * yRatio)
+		y1 := int(float64(y+1) * yRatio)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := int(float64(x) * xRatio)
+			x1 := int(float64(x+1) * xRatio)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1 && sy < sh; sy++ {
+				for sx := x0; sx < x1 && sx < sw; sx++ {
+					pr, pg, pb, pa := src.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+
+			off := dst.PixOffset(x, y)
+			dst.Pix[off+0] = uint8(r / n >> 8)
+			dst.Pix[off+1] = uint8(g / n >> 8)
+			dst.Pix[off+2] = uint8(bl / n >> 8)
+			dst.Pix[off+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
+
+// FitSize calcula el tamano final manteniendo proporcion y sin agrandar
+func FitSize(sw, sh, width, height int) (int, int) {
+	if width <= 0 && height <= 0 {
+		return sw, sh
+	}
+
+	scale := 1.0
+	if width > 0 && width < sw {
+		scale = float64(width) / float64(sw)
+	}
+	if height > 0 && height < sh {
+		if hs := float64(height) / float64(sh); hs < scale {
+			scale = hs
+		}
+	}
+
+	dw := int(float64(sw)*scale + 0.5)
+	dh := int(float64(sh)*scale + 0.5)
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	return dw, dh
+}
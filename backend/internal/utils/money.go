@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CurrencyCOP es la moneda en la que se cobra; las demas son solo estimados
+const CurrencyCOP = "COP"
+
+// Currency dice como se redondea y se escribe una moneda
+type Currency struct {
+	Code       string
+	Symbol     string
+	Decimals   int
+	Thousands  string
+	DecimalSep string
+}
+
+// currencies son las que sabemos mostrar; pa agregar una basta con ponerla aca y darle tasa
+var currencies = map[string]Currency{
+	"COP": {Code: "COP", Symbol: "$", Decimals: 0, Thousands: ".", DecimalSep: ","},
+	"USD": {Code: "USD", Symbol: "US$", Decimals: 2, Thousands: ",", DecimalSep: "."},
+	"EUR": {Code: "EUR", Symbol: "€", Decimals: 2, Thousands: ".", DecimalSep: ","},
+}
+
+// LookupCurrency busca la moneda por codigo ISO (sin importar mayusculas)
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	return currency, ok
+}
+
+// RoundMoney redondea a los decimales de la moneda, mitad pa arriba (lejos de cero).
+// COP va a pesos enteros, USD/EUR a centavos.
+func RoundMoney(amount float64, code string) float64 {
+	currency, ok := LookupCurrency(code)
+	if !ok {
+		currency = currencies[CurrencyCOP]
+	}
+	factor := math.Pow10(currency.Decimals)
+	return math.Round(amount*factor) / factor
+}
+
+// FormatMoney escribe el monto ya redondeado con simbolo y separadores de la moneda.
+// Ej: FormatMoney(129900, "COP") -> "$129.900", FormatMoney(32.5, "USD") -> "US$32.50"
+func FormatMoney(amount float64, code string) string {
+	currency, ok := LookupCurrency(code)
+	if !ok {
+		currency = currencies[CurrencyCOP]
+	}
+
+	amount = RoundMoney(amount, currency.Code)
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	raw := strconv.FormatFloat(amount, 'f', currency.Decimals, 64)
+	whole, fraction, _ := strings.Cut(raw, ".")
+
+	var parts []string
+	for len(whole) > 3 {
+		parts = append([]string{whole[len(whole)-3:]}, parts...)
+		whole = whole[:len(whole)-3]
+	}
+	parts = append([]string{whole}, parts...)
+
+	result := sign + currency.Symbol + strings.Join(parts, currency.Thousands)
+	if fraction != "" {
+		result += currency.DecimalSep + fraction
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrEmptySlug sale cuando el texto no tiene ni letras ni numeros pa armar el slug
+var ErrEmptySlug = errors.New("slug must contain at least one letter or digit")
+
+// letterFolder cubre las letras que NFD no separa en base + tilde
+var letterFolder = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ð", "d", "ł", "l", "þ", "th", "ı", "i",
+)
+
+// Slugify convierte un texto en slug: minusculas, sin tildes y con guiones
+// Ej: "Vestido Azul Lentejuelas" -> "vestido-azul-lentejuelas", "Crème Brûlée" -> "creme-brulee"
+func Slugify(s string) string {
+	// NFD separa "é" en "e" + tilde combinante; la tilde (Mn) se bota abajo
+	s = norm.NFD.String(letterFolder.Replace(strings.ToLower(strings.TrimSpace(s))))
+
+	var b strings.Builder
+	lastDash := true
+	for _, r := range s {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			lastDash = false
+			continue
+		}
+		if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NewSlug es Slugify pa slugs que se guardan: si queda vacio es error, no un slug vacio
+func NewSlug(s string) (string, error) {
+	slug := Slugify(s)
+	if slug == "" {
+		return "", ErrEmptySlug
+	}
+	return slug, nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductAffinity dice que tan seguido se compran dos productos juntos.
+// Se recalcula completa desde los pedidos pagados, no se edita a mano.
+type ProductAffinity struct {
+	ProductID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"product_id"`
+	RelatedProductID uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"related_product_id"`
+	Orders           int       `gorm:"type:integer;not null" json:"orders"` // pedidos donde salen juntos
+	Score            float64   `gorm:"not null;index" json:"score"`
+	UpdatedAt        time.Time `json:"updated_at"`
+
+	Product        Product `gorm:"foreignKey:ProductID" json:"-"`
+	RelatedProduct Product `gorm:"foreignKey:RelatedProductID" json:"-"`
+}
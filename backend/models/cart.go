@@ -26,16 +26,18 @@ func (c *Cart) BeforeCreate(tx *gorm.DB) error {
 }
 
 type CartItem struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	CartID    uuid.UUID `gorm:"type:uuid;not null;index" json:"cart_id"`
-	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
-	Quantity  int       `gorm:"type:integer;not null;default:1" json:"quantity"`
-	Size      string    `gorm:"type:varchar(10)" json:"size"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-
-	Cart    Cart    `gorm:"foreignKey:CartID" json:"-"`
-	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CartID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"cart_id"`
+	ProductID uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID *uuid.UUID `gorm:"type:uuid;index" json:"variant_id"`
+	Quantity  int        `gorm:"type:integer;not null;default:1" json:"quantity"`
+	Size      string     `gorm:"type:varchar(10)" json:"size"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Cart    Cart            `gorm:"foreignKey:CartID" json:"-"`
+	Product Product         `gorm:"foreignKey:ProductID" json:"-"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"-"`
 }
 
 func (ci *CartItem) BeforeCreate(tx *gorm.DB) error {
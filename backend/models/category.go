@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Category es un nodo del arbol de categorias de una tienda (Vestidos > Largos)
+type Category struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID   uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_categories_store_slug" json:"store_id"`
+	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id"`
+	Slug      string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_categories_store_slug" json:"slug"`
+	Name      string     `gorm:"type:varchar(100);not null" json:"name"`
+	NameEN    string     `gorm:"type:varchar(100)" json:"name_en"` // vacio = se muestra el de espanol
+	SortOrder int        `gorm:"type:integer;not null;default:0" json:"sort_order"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Store    Store      `gorm:"foreignKey:StoreID" json:"-"`
+	Parent   *Category  `gorm:"foreignKey:ParentID" json:"-"`
+	Children []Category `gorm:"foreignKey:ParentID" json:"-"`
+	Products []Product  `gorm:"foreignKey:CategoryID" json:"-"`
+}
+
+func (c *Category) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
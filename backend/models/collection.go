@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NewArrivalsSlug es el slug de la coleccion automatica de lo nuevo; nadie la puede crear a mano
+const NewArrivalsSlug = "nuevo"
+
+// Collection es una seleccion curada de productos de una tienda (home, temporadas, campanas)
+type Collection struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_collections_store_slug" json:"store_id"`
+	Slug        string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_collections_store_slug" json:"slug"`
+	Name        string     `gorm:"type:varchar(150);not null" json:"name"`
+	Description string     `gorm:"type:text" json:"description"`
+	BannerPath  string     `gorm:"type:varchar(255)" json:"banner_path"`
+	StartsAt    *time.Time `gorm:"index" json:"starts_at"` // nil = desde siempre
+	EndsAt      *time.Time `gorm:"index" json:"ends_at"`   // nil = sin fin
+	SortOrder   int        `gorm:"type:integer;not null;default:0" json:"sort_order"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	Store    Store               `gorm:"foreignKey:StoreID" json:"-"`
+	Products []CollectionProduct `gorm:"foreignKey:CollectionID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (c *Collection) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// ActiveAt dice si la coleccion se muestra en ese momento
+func (c *Collection) ActiveAt(t time.Time) bool {
+	if c.StartsAt != nil && t.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && !t.Before(*c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// CollectionProduct es un producto dentro de una coleccion, en el orden que eligieron
+type CollectionProduct struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	CollectionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_collection_products_product" json:"collection_id"`
+	ProductID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_collection_products_product;index" json:"product_id"`
+	Position     int       `gorm:"type:integer;not null;default:0" json:"position"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	Collection Collection `gorm:"foreignKey:CollectionID" json:"-"`
+	Product    Product    `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (cp *CollectionProduct) BeforeCreate(tx *gorm.DB) error {
+	if cp.ID == uuid.Nil {
+		cp.ID = uuid.New()
+	}
+	return nil
+}
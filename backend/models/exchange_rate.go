@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExchangeRate es la tasa que puso el admin pa una moneda de referencia.
+// Rate = cuantos COP vale 1 unidad; manda sobre la de EXCHANGE_RATES.
+type ExchangeRate struct {
+	Code      string     `gorm:"type:varchar(3);primaryKey" json:"code"`
+	Rate      float64    `gorm:"type:decimal(14,4);not null" json:"rate"`
+	UpdatedBy *uuid.UUID `gorm:"type:uuid" json:"updated_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
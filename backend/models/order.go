@@ -11,7 +11,10 @@ type Order struct {
 	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
 	UserID             *uuid.UUID `gorm:"type:uuid;index" json:"user_id"`
 	SessionID          *string    `gorm:"type:varchar(255);index" json:"session_id"`
+	SubtotalAmount     float64    `gorm:"type:decimal(10,2);not null;default:0" json:"subtotal_amount"` // antes de descuentos
+	DiscountAmount     float64    `gorm:"type:decimal(10,2);not null;default:0" json:"discount_amount"`
 	TotalAmount        float64    `gorm:"type:decimal(10,2);not null" json:"total_amount"`
+	Currency           string     `gorm:"type:varchar(3);not null;default:'COP'" json:"currency"`   // siempre se cobra en COP
 	Status             string     `gorm:"type:varchar(50);default:'pending'" json:"status"`         // pending, confirmed, shipped, delivered
 	PaymentStatus      string     `gorm:"type:varchar(50);default:'pending'" json:"payment_status"` // pending, completed, failed
 	ShippingName       string     `gorm:"type:varchar(255)" json:"shipping_name"`
@@ -25,8 +28,9 @@ type Order struct {
 	CreatedAt          time.Time  `json:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at"`
 
-	User       *User       `gorm:"foreignKey:UserID" json:"-"`
-	OrderItems []OrderItem `gorm:"foreignKey:OrderID" json:"-"`
+	User       *User           `gorm:"foreignKey:UserID" json:"-"`
+	OrderItems []OrderItem     `gorm:"foreignKey:OrderID" json:"-"`
+	Discounts  []OrderDiscount `gorm:"foreignKey:OrderID" json:"-"`
 }
 
 func (o *Order) BeforeCreate(tx *gorm.DB) error {
@@ -37,16 +41,20 @@ func (o *Order) BeforeCreate(tx *gorm.DB) error {
 }
 
 type OrderItem struct {
-	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	OrderID   uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
-	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
-	Quantity  int       `gorm:"type:integer;not null" json:"quantity"`
-	Size      string    `gorm:"type:varchar(10)" json:"size"`
-	UnitPrice float64   `gorm:"type:decimal(10,2);not null" json:"unit_price"`
-	CreatedAt time.Time `json:"created_at"`
-
-	Order   Order   `gorm:"foreignKey:OrderID" json:"-"`
-	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	OrderID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	ProductID uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID *uuid.UUID `gorm:"type:uuid;index" json:"variant_id"`
+	Quantity  int        `gorm:"type:integer;not null" json:"quantity"`
+	Size      string     `gorm:"type:varchar(10)" json:"size"`
+	Color     string     `gorm:"type:varchar(50)" json:"color"`
+	SKU       string     `gorm:"type:varchar(100)" json:"sku"`
+	UnitPrice float64    `gorm:"type:decimal(10,2);not null" json:"unit_price"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	Order   Order           `gorm:"foreignKey:OrderID" json:"-"`
+	Product Product         `gorm:"foreignKey:ProductID" json:"-"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"-"`
 }
 
 func (oi *OrderItem) BeforeCreate(tx *gorm.DB) error {
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Origen de un cambio de precio
+const (
+	PriceSourceInitial  = "initial" // precio que ya tenia antes del historial
+	PriceSourceAdmin    = "admin"
+	PriceSourceCatalog  = "catalog"
+	PriceSourceSchedule = "schedule"
+)
+
+// Estados de un precio programado
+const (
+	PriceSchedulePending   = "pending"
+	PriceScheduleActive    = "active"
+	PriceScheduleFinished  = "finished"
+	PriceScheduleCancelled = "cancelled"
+	PriceScheduleExpired   = "expired" // se paso la ventana sin que el scheduler corriera
+)
+
+// PriceHistory es un precio que tuvo el producto desde EffectiveAt hasta el siguiente registro
+type PriceHistory struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID      uuid.UUID  `gorm:"type:uuid;not null;index:idx_price_histories_product_at" json:"product_id"`
+	Price          float64    `gorm:"type:decimal(10,2);not null" json:"price"`
+	CompareAtPrice *float64   `gorm:"type:decimal(10,2)" json:"compare_at_price"`
+	Source         string     `gorm:"type:varchar(20);not null" json:"source"`
+	ScheduleID     *uuid.UUID `gorm:"type:uuid;index" json:"schedule_id"`
+	EffectiveAt    time.Time  `gorm:"not null;index:idx_price_histories_product_at" json:"effective_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (ph *PriceHistory) BeforeCreate(tx *gorm.DB) error {
+	if ph.ID == uuid.Nil {
+		ph.ID = uuid.New()
+	}
+	return nil
+}
+
+// PriceSchedule es un precio temporal (rebaja) que se aplica y se quita solo
+type PriceSchedule struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	Price             float64    `gorm:"type:decimal(10,2);not null" json:"price"`
+	CompareAtPrice    *float64   `gorm:"type:decimal(10,2)" json:"compare_at_price"` // nil = el precio de antes si es rebaja
+	StartsAt          time.Time  `gorm:"not null;index" json:"starts_at"`
+	EndsAt            *time.Time `gorm:"index" json:"ends_at"` // nil = se queda
+	Status            string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	OriginalPrice     *float64   `gorm:"type:decimal(10,2)" json:"original_price"` // precio regular pa restaurar al final
+	OriginalCompareAt *float64   `gorm:"type:decimal(10,2)" json:"original_compare_at"`
+	ManualOverride    bool       `gorm:"not null;default:false" json:"manual_override"` // cambiaron el precio a mano: al final no se restaura
+	CreatedBy         *uuid.UUID `gorm:"type:uuid" json:"created_by"`
+	AppliedAt         *time.Time `json:"applied_at"`
+	EndedAt           *time.Time `json:"ended_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (ps *PriceSchedule) BeforeCreate(tx *gorm.DB) error {
+	if ps.ID == uuid.Nil {
+		ps.ID = uuid.New()
+	}
+	return nil
+}
@@ -9,28 +9,86 @@ import (
 	"gorm.io/gorm"
 )
 
+// Estados del producto; solo published (o scheduled ya vencido) sale al publico
+const (
+	ProductDraft     = "draft"
+	ProductScheduled = "scheduled"
+	ProductPublished = "published"
+	ProductArchived  = "archived"
+)
+
+// productTransitions dice a que estado se puede pasar desde cada uno
+var productTransitions = map[string][]string{
+	ProductDraft:     {ProductScheduled, ProductPublished, ProductArchived},
+	ProductScheduled: {ProductDraft, ProductScheduled, ProductPublished, ProductArchived}, // scheduled -> scheduled = reprogramar
+	ProductPublished: {ProductDraft, ProductArchived},
+	ProductArchived:  {ProductDraft, ProductPublished},
+}
+
+// ValidProductStatus revisa que el estado exista
+func ValidProductStatus(status string) bool {
+	_, ok := productTransitions[status]
+	return ok
+}
+
+// CanTransitionProduct dice si se puede pasar de un estado al otro
+func CanTransitionProduct(from, to string) bool {
+	for _, allowed := range productTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Product struct {
-	ID             uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
-	StoreID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"store_id"`
-	Name           string         `gorm:"type:varchar(255);not null" json:"name"`
-	Description    string         `gorm:"type:text" json:"description"`
-	Category       string         `gorm:"type:varchar(100)" json:"category"`
-	Price          float64        `gorm:"type:decimal(10,2);not null" json:"price"`
-	AvailableUnits int            `gorm:"type:integer;default:0" json:"available_units"`
-	ImagePath      string         `gorm:"type:varchar(255)" json:"image_path"`
-	Sizes          datatypes.JSON `gorm:"type:jsonb" json:"sizes"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-
-	Store      Store       `gorm:"foreignKey:StoreID" json:"-"`
-	CartItems  []CartItem  `gorm:"foreignKey:ProductID" json:"-"`
-	OrderItems []OrderItem `gorm:"foreignKey:ProductID" json:"-"`
+	ID                uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID           uuid.UUID      `gorm:"type:uuid;not null;index;uniqueIndex:idx_products_store_key;uniqueIndex:idx_products_store_slug" json:"store_id"`
+	ExternalKey       *string        `gorm:"type:varchar(100);uniqueIndex:idx_products_store_key" json:"external_key"` // key estable del archivo de catalogo
+	Name              string         `gorm:"type:varchar(255);not null" json:"name"`
+	NameEN            string         `gorm:"type:varchar(255)" json:"name_en"`                                  // vacio = se muestra el de espanol
+	Slug              string         `gorm:"type:varchar(255);uniqueIndex:idx_products_store_slug" json:"slug"` // unico por tienda, sale del nombre
+	Description       string         `gorm:"type:text" json:"description"`
+	DescriptionEN     string         `gorm:"type:text" json:"description_en"`
+	Category          string         `gorm:"type:varchar(100)" json:"category"`    // copia del nombre de la categoria
+	CategoryEN        string         `gorm:"type:varchar(100)" json:"category_en"` // copia del name_en de la categoria
+	CategoryID        *uuid.UUID     `gorm:"type:uuid;index" json:"category_id"`
+	Price             float64        `gorm:"type:decimal(10,2);not null" json:"price"`
+	CompareAtPrice    *float64       `gorm:"type:decimal(10,2)" json:"compare_at_price"` // "antes $X", nil si no hay rebaja
+	AvailableUnits    int            `gorm:"type:integer;default:0" json:"available_units"`
+	LowStockThreshold *int           `gorm:"type:integer" json:"low_stock_threshold"` // nil = el de la tienda
+	ImagePath         string         `gorm:"type:varchar(255)" json:"image_path"`     // copia de la imagen principal
+	Sizes             datatypes.JSON `gorm:"type:jsonb" json:"sizes"`
+	RatingAverage     float64        `gorm:"type:decimal(3,2);not null;default:0" json:"rating_average"` // solo resenas aprobadas
+	ReviewCount       int            `gorm:"type:integer;not null;default:0" json:"review_count"`
+	Version           int            `gorm:"not null;default:1" json:"version"`                                 // pa concurrencia optimista
+	Status            string         `gorm:"type:varchar(20);not null;default:'published';index" json:"status"` // draft, scheduled, published, archived
+	PublishedAt       *time.Time     `gorm:"index" json:"published_at"`                                         // desde cuando sale (y cuenta como nuevo); en scheduled es la fecha programada
+	ArchivedAt        *time.Time     `gorm:"index" json:"archived_at"`
+	ArchivedBySync    bool           `gorm:"not null;default:false" json:"archived_by_sync"` // lo archivo el sync (no el staff), el archivo lo puede restaurar
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+
+	Store      Store            `gorm:"foreignKey:StoreID" json:"-"`
+	Taxonomy   *Category        `gorm:"foreignKey:CategoryID" json:"-"`
+	Variants   []ProductVariant `gorm:"foreignKey:ProductID" json:"-"`
+	Images     []ProductImage   `gorm:"foreignKey:ProductID" json:"-"`
+	CartItems  []CartItem       `gorm:"foreignKey:ProductID" json:"-"`
+	OrderItems []OrderItem      `gorm:"foreignKey:ProductID" json:"-"`
+	Reviews    []Review         `gorm:"foreignKey:ProductID" json:"-"`
 }
 
 func (p *Product) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
 	}
+	if p.Status == "" {
+		p.Status = ProductPublished
+	}
+	if p.PublishedAt == nil && p.Status == ProductPublished {
+		now := time.Now()
+		p.PublishedAt = &now
+	}
 	return nil
 }
 
@@ -48,3 +106,43 @@ func (p *Product) GetSizes() ([]string, error) {
 	err := json.Unmarshal(p.Sizes, &sizes)
 	return sizes, err
 }
+
+// ProductImage es una foto de la galeria del producto (frente, espalda, detalle...)
+type ProductImage struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	Path      string    `gorm:"type:varchar(255);not null;index" json:"path"`
+	Position  int       `gorm:"type:integer;not null;default:0" json:"position"`
+	AltES     string    `gorm:"type:varchar(255)" json:"alt_es"`
+	AltEN     string    `gorm:"type:varchar(255)" json:"alt_en"`
+	IsPrimary bool      `gorm:"default:false" json:"is_primary"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (pi *ProductImage) BeforeCreate(tx *gorm.DB) error {
+	if pi.ID == uuid.Nil {
+		pi.ID = uuid.New()
+	}
+	return nil
+}
+
+// ProductSlug guarda los slugs viejos pa que los links compartidos sigan sirviendo
+type ProductSlug struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	StoreID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_product_slugs_store_slug" json:"store_id"`
+	Slug      string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_product_slugs_store_slug" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (ps *ProductSlug) BeforeCreate(tx *gorm.DB) error {
+	if ps.ID == uuid.Nil {
+		ps.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipos de descuento
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+// Alcance de la promo: toda la tienda, una categoria (con sus hijas) o un producto
+const (
+	PromotionScopeStore    = "store"
+	PromotionScopeCategory = "category"
+	PromotionScopeProduct  = "product"
+)
+
+// Promotion es un descuento automatico o, si tiene Code, un cupon
+type Promotion struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"store_id"`
+	Name             string     `gorm:"type:varchar(150);not null" json:"name"`
+	Description      string     `gorm:"type:text" json:"description"`
+	Code             *string    `gorm:"type:varchar(50);uniqueIndex" json:"code"` // nil = se aplica solita; siempre en mayusculas
+	DiscountType     string     `gorm:"type:varchar(20);not null" json:"discount_type"`
+	Value            float64    `gorm:"type:decimal(10,2);not null" json:"value"` // porcentaje (0-100) o monto fijo
+	Scope            string     `gorm:"type:varchar(20);not null;default:'store'" json:"scope"`
+	CategoryID       *uuid.UUID `gorm:"type:uuid;index" json:"category_id"`
+	ProductID        *uuid.UUID `gorm:"type:uuid;index" json:"product_id"`
+	MinSpend         float64    `gorm:"type:decimal(10,2);not null;default:0" json:"min_spend"`    // sobre lo que entra en la promo
+	UsageLimit       int        `gorm:"type:integer;not null;default:0" json:"usage_limit"`        // 0 = sin limite
+	PerCustomerLimit int        `gorm:"type:integer;not null;default:0" json:"per_customer_limit"` // 0 = sin limite
+	UsageCount       int        `gorm:"type:integer;not null;default:0" json:"usage_count"`
+	Stackable        bool       `gorm:"not null;default:true" json:"stackable"`          // false = no se combina con nada
+	Priority         int        `gorm:"type:integer;not null;default:0" json:"priority"` // mayor va primero
+	StartsAt         *time.Time `gorm:"index" json:"starts_at"`
+	EndsAt           *time.Time `gorm:"index" json:"ends_at"`
+	Active           bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	Store    Store     `gorm:"foreignKey:StoreID" json:"-"`
+	Category *Category `gorm:"foreignKey:CategoryID" json:"-"`
+	Product  *Product  `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (p *Promotion) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// ActiveAt dice si la promo corre en ese momento
+func (p *Promotion) ActiveAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// CartCoupon es un cupon que el cliente metio en su carrito
+type CartCoupon struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	CartID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cart_coupons_promotion" json:"cart_id"`
+	PromotionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cart_coupons_promotion" json:"promotion_id"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Cart      Cart      `gorm:"foreignKey:CartID" json:"-"`
+	Promotion Promotion `gorm:"foreignKey:PromotionID" json:"-"`
+}
+
+func (cc *CartCoupon) BeforeCreate(tx *gorm.DB) error {
+	if cc.ID == uuid.Nil {
+		cc.ID = uuid.New()
+	}
+	return nil
+}
+
+// PromotionRedemption registra cada uso de una promo con limite, pa contar por cliente
+type PromotionRedemption struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	PromotionID uuid.UUID `gorm:"type:uuid;not null;index:idx_redemptions_customer" json:"promotion_id"`
+	OrderID     uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	CustomerKey string    `gorm:"type:varchar(300);not null;index:idx_redemptions_customer" json:"customer_key"` // user:, email: o session:
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (pr *PromotionRedemption) BeforeCreate(tx *gorm.DB) error {
+	if pr.ID == uuid.Nil {
+		pr.ID = uuid.New()
+	}
+	return nil
+}
+
+// OrderDiscount es una linea del desglose: cuanto desconto cada promo en cada item
+type OrderDiscount struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	OrderID     uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	OrderItemID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	PromotionID uuid.UUID `gorm:"type:uuid;not null;index" json:"promotion_id"`
+	Name        string    `gorm:"type:varchar(150);not null" json:"name"` // copia, por si la promo cambia despues
+	Code        string    `gorm:"type:varchar(50)" json:"code"`
+	Amount      float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (od *OrderDiscount) BeforeCreate(tx *gorm.DB) error {
+	if od.ID == uuid.Nil {
+		od.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Estados de moderacion de una resena
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Como le quedo la talla al cliente
+const (
+	FitSmall = "small"
+	FitTrue  = "true"
+	FitLarge = "large"
+)
+
+// Review es una resena de un producto; solo las aprobadas salen al publico
+type Review struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user" json:"product_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user;index" json:"user_id"`
+	OrderItemID    *uuid.UUID `gorm:"type:uuid;index" json:"order_item_id"` // si hay compra pagada: "compra verificada"
+	Rating         int        `gorm:"type:integer;not null" json:"rating"`
+	Title          string     `gorm:"type:varchar(150)" json:"title"`
+	Body           string     `gorm:"type:text" json:"body"`
+	Fit            string     `gorm:"type:varchar(10)" json:"fit"` // small, true, large
+	Size           string     `gorm:"type:varchar(10)" json:"size"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ModeratedBy    *uuid.UUID `gorm:"type:uuid" json:"moderated_by"`
+	ModeratedAt    *time.Time `json:"moderated_at"`
+	ModerationNote string     `gorm:"type:text" json:"moderation_note"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Product   Product       `gorm:"foreignKey:ProductID" json:"-"`
+	User      User          `gorm:"foreignKey:UserID" json:"-"`
+	OrderItem *OrderItem    `gorm:"foreignKey:OrderItemID" json:"-"`
+	Photos    []ReviewPhoto `gorm:"foreignKey:ReviewID" json:"-"`
+}
+
+func (r *Review) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// VerifiedPurchase dice si la resena viene de una compra pagada
+func (r *Review) VerifiedPurchase() bool {
+	return r.OrderItemID != nil
+}
+
+// ReviewPhoto es una foto que subio el cliente con la resena
+type ReviewPhoto struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ReviewID  uuid.UUID `gorm:"type:uuid;not null;index" json:"review_id"`
+	Path      string    `gorm:"type:varchar(255);not null" json:"path"`
+	Position  int       `gorm:"type:integer;not null;default:0" json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Review Review `gorm:"foreignKey:ReviewID" json:"-"`
+}
+
+func (rp *ReviewPhoto) BeforeCreate(tx *gorm.DB) error {
+	if rp.ID == uuid.Nil {
+		rp.ID = uuid.New()
+	}
+	return nil
+}
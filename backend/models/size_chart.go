@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Medidas que puede llevar una guia de tallas, todas en cm
+var SizeMeasurements = []string{"bust", "waist", "hips", "shoulders", "length", "inseam", "height"}
+
+// SizeRange es el rango en cm de una medida pa una talla (min y max incluidos)
+type SizeRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// SizeChart es la guia de tallas de una tienda; con CategoryID aplica a esa categoria
+// y sus hijas, sin CategoryID es la de toda la tienda. Celeste y Mewave cortan distinto.
+type SizeChart struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"store_id"`
+	CategoryID *uuid.UUID `gorm:"type:uuid;index" json:"category_id"` // nil = toda la tienda
+	Name       string     `gorm:"type:varchar(150);not null" json:"name"`
+	Notes      string     `gorm:"type:text" json:"notes"` // como medirse, etc
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	Store    Store          `gorm:"foreignKey:StoreID" json:"-"`
+	Category *Category      `gorm:"foreignKey:CategoryID" json:"-"`
+	Rows     []SizeChartRow `gorm:"foreignKey:SizeChartID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (sc *SizeChart) BeforeCreate(tx *gorm.DB) error {
+	if sc.ID == uuid.Nil {
+		sc.ID = uuid.New()
+	}
+	return nil
+}
+
+// SizeChartRow es una talla de la guia con sus medidas; Position va de la mas chica a la mas grande
+type SizeChartRow struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	SizeChartID  uuid.UUID      `gorm:"type:uuid;not null;index" json:"size_chart_id"`
+	Size         string         `gorm:"type:varchar(10);not null" json:"size"`
+	Position     int            `gorm:"type:integer;not null;default:0" json:"position"`
+	Measurements datatypes.JSON `gorm:"type:jsonb" json:"measurements"` // {"bust": {"min": 84, "max": 88}}
+
+	SizeChart SizeChart `gorm:"foreignKey:SizeChartID" json:"-"`
+}
+
+func (r *SizeChartRow) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *SizeChartRow) SetMeasurements(measurements map[string]SizeRange) error {
+	data, err := json.Marshal(measurements)
+	if err != nil {
+		return err
+	}
+	r.Measurements = data
+	return nil
+}
+
+func (r *SizeChartRow) GetMeasurements() (map[string]SizeRange, error) {
+	measurements := make(map[string]SizeRange)
+	if len(r.Measurements) == 0 {
+		return measurements, nil
+	}
+	err := json.Unmarshal(r.Measurements, &measurements)
+	return measurements, err
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Niveles de alerta de stock
+const (
+	StockLevelLow     = "low"
+	StockLevelSoldOut = "sold_out"
+)
+
+// StockAlert es un cruce de umbral de un producto (o una variante si VariantID viene).
+// Queda abierta hasta que el stock se recupere; asi se avisa una sola vez por cruce.
+type StockAlert struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"store_id"`
+	ProductID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	VariantID  *uuid.UUID `gorm:"type:uuid;index" json:"variant_id"`
+	Level      string     `gorm:"type:varchar(20);not null" json:"level"` // low o sold_out
+	Stock      int        `gorm:"type:integer;not null" json:"stock"`
+	Threshold  int        `gorm:"type:integer;not null" json:"threshold"`
+	NotifiedAt *time.Time `gorm:"index" json:"notified_at"` // nil = falta mandarla en el digest
+	ResolvedAt *time.Time `gorm:"index" json:"resolved_at"` // nil = sigue abierta
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	Store   Store           `gorm:"foreignKey:StoreID" json:"-"`
+	Product Product         `gorm:"foreignKey:ProductID" json:"-"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"-"`
+}
+
+func (sa *StockAlert) BeforeCreate(tx *gorm.DB) error {
+	if sa.ID == uuid.Nil {
+		sa.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockSubscription es un "avisame cuando vuelva" de un producto agotado (o una talla).
+// Se avisa una sola vez; si se vuelve a agotar hay que suscribirse otra vez.
+type StockSubscription struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"product_id"`
+	Size       string     `gorm:"type:varchar(10)" json:"size"` // vacio = cualquier talla
+	Email      string     `gorm:"type:varchar(255);not null;index" json:"email"`
+	UserID     *uuid.UUID `gorm:"type:uuid;index" json:"user_id"` // nil = invitado
+	NotifiedAt *time.Time `gorm:"index" json:"notified_at"`       // nil = sigue esperando
+	CreatedAt  time.Time  `json:"created_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (ss *StockSubscription) BeforeCreate(tx *gorm.DB) error {
+	if ss.ID == uuid.Nil {
+		ss.ID = uuid.New()
+	}
+	return nil
+}
+
+// RestockWatermark guarda por producto/talla el ultimo stock que vio el notificador
+// y cuantos avisos quedan de la reposicion, asi el mismo stock no avisa dos veces
+type RestockWatermark struct {
+	ProductID uuid.UUID `gorm:"type:uuid;primaryKey" json:"product_id"`
+	Size      string    `gorm:"type:varchar(10);primaryKey" json:"size"` // en minuscula, vacio = cualquier talla
+	Stock     int       `gorm:"not null" json:"stock"`
+	Remaining int       `gorm:"not null" json:"remaining"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,19 +1,38 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+// Store es una marca de la casa (Celeste, Mewave...) con su branding y sus datos de contacto
 type Store struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Name        string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
-	Description string    `gorm:"type:text" json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	Name               string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
+	Slug               string         `gorm:"type:varchar(100);uniqueIndex;not null" json:"slug"` // pa las URLs: /api/stores/celeste
+	Description        string         `gorm:"type:text" json:"description"`
+	LogoPath           string         `gorm:"type:varchar(255)" json:"logo_path"`
+	Banners            datatypes.JSON `gorm:"type:jsonb" json:"banners"` // paths en el orden del carrusel
+	ContactEmail       string         `gorm:"type:varchar(255)" json:"contact_email"`
+	ContactPhone       string         `gorm:"type:varchar(20)" json:"contact_phone"`
+	WhatsApp           string         `gorm:"column:whatsapp;type:varchar(20)" json:"whatsapp"`
+	SocialLinks        datatypes.JSON `gorm:"type:jsonb" json:"social_links"` // {"instagram": "https://..."}
+	ShippingOriginCity string         `gorm:"type:varchar(100)" json:"shipping_origin_city"`
+	ShippingPolicy     string         `gorm:"type:text" json:"shipping_policy"`
+	ReturnPolicy       string         `gorm:"type:text" json:"return_policy"`
+	SortOrder          int            `gorm:"type:integer;not null;default:0" json:"sort_order"`
+	LowStockThreshold  *int           `gorm:"type:integer" json:"low_stock_threshold"` // nil = el de la config
+	Version            int            `gorm:"not null;default:1" json:"version"`
+	ArchivedAt         *time.Time     `gorm:"index" json:"archived_at"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
 
-	Products []Product `gorm:"foreignKey:StoreID" json:"-"`
+	Products []Product    `gorm:"foreignKey:StoreID" json:"-"`
+	Staff    []StoreStaff `gorm:"foreignKey:StoreID" json:"-"`
 }
 
 func (s *Store) BeforeCreate(tx *gorm.DB) error {
@@ -22,3 +41,57 @@ func (s *Store) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+func (s *Store) SetBanners(banners []string) error {
+	data, err := json.Marshal(banners)
+	if err != nil {
+		return err
+	}
+	s.Banners = data
+	return nil
+}
+
+func (s *Store) GetBanners() ([]string, error) {
+	banners := []string{}
+	if len(s.Banners) == 0 {
+		return banners, nil
+	}
+	err := json.Unmarshal(s.Banners, &banners)
+	return banners, err
+}
+
+func (s *Store) SetSocialLinks(links map[string]string) error {
+	data, err := json.Marshal(links)
+	if err != nil {
+		return err
+	}
+	s.SocialLinks = data
+	return nil
+}
+
+func (s *Store) GetSocialLinks() (map[string]string, error) {
+	links := make(map[string]string)
+	if len(s.SocialLinks) == 0 {
+		return links, nil
+	}
+	err := json.Unmarshal(s.SocialLinks, &links)
+	return links, err
+}
+
+// StoreStaff conecta un user staff con la tienda que puede administrar
+type StoreStaff struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	StoreID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_store_staff_store_user" json:"store_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_store_staff_store_user;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Store Store `gorm:"foreignKey:StoreID" json:"-"`
+	User  User  `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (ss *StoreStaff) BeforeCreate(tx *gorm.DB) error {
+	if ss.ID == uuid.Nil {
+		ss.ID = uuid.New()
+	}
+	return nil
+}
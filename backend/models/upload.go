@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductUpload registra cada imagen subida pa un producto, asi sabemos que limpiar
+type ProductUpload struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID   uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	UploadedBy  uuid.UUID `gorm:"type:uuid" json:"uploaded_by"`
+	Path        string    `gorm:"type:varchar(255);not null;index" json:"path"` // relativo a UploadDir
+	ContentType string    `gorm:"type:varchar(50)" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (pu *ProductUpload) BeforeCreate(tx *gorm.DB) error {
+	if pu.ID == uuid.Nil {
+		pu.ID = uuid.New()
+	}
+	return nil
+}
@@ -15,14 +15,23 @@ type User struct {
 	LastName     string    `gorm:"type:varchar(255);not null" json:"last_name"`
 	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
 	IsRegistered bool      `gorm:"default:true" json:"is_registered"`
+	Role         string    `gorm:"type:varchar(20);default:'customer'" json:"role"` // customer, staff, admin
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 
 	Carts          []Cart          `gorm:"foreignKey:UserID" json:"-"`
 	Orders         []Order         `gorm:"foreignKey:UserID" json:"-"`
 	PasswordResets []PasswordReset `gorm:"foreignKey:UserID" json:"-"`
+	StoreStaff     []StoreStaff    `gorm:"foreignKey:UserID" json:"-"`
 }
 
+// Roles del user
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
 		u.ID = uuid.New()
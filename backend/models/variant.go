@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductVariant es una combinacion talla/color con su propio stock
+type ProductVariant struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	ProductID uuid.UUID `gorm:"type:uuid;not null;index" json:"product_id"`
+	Size      string    `gorm:"type:varchar(10)" json:"size"`
+	Color     string    `gorm:"type:varchar(50)" json:"color"`
+	SKU       string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"sku"`
+	Barcode   string    `gorm:"type:varchar(100)" json:"barcode"`
+	Stock     int       `gorm:"type:integer;not null;default:0" json:"stock"`
+	Price     *float64  `gorm:"type:decimal(10,2)" json:"price"` // si viene, pisa el precio del producto
+	Active    bool      `gorm:"not null" json:"active"`          // sin default, si no GORM se salta el false al crear
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// ImportedStock es el ultimo stock que trajo el archivo del catalogo (nil = nunca vino de
+	// archivo). El sync solo pisa Stock cuando el archivo cambia, asi no deshace las ventas.
+	ImportedStock *int `gorm:"type:integer" json:"-"`
+
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (v *ProductVariant) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
+
+// EffectivePrice devuelve el precio de la variante o el del producto
+func (v *ProductVariant) EffectivePrice(productPrice float64) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return productPrice
+}
@@ -3,11 +3,21 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/leunameek/celestexmewave/handlers"
+	"github.com/leunameek/celestexmewave/internal/config"
 	"github.com/leunameek/celestexmewave/internal/middleware"
 )
 
 func SetupRouter() *gin.Engine {
 	router := gin.Default()
+	cfg := config.Get()
+
+	// Politicas de cache por grupo: catalogo publico cacheable, lo demas no
+	catalogCache := middleware.CachePolicy{
+		Public:               true,
+		MaxAge:               cfg.CatalogCacheMaxAge,
+		StaleWhileRevalidate: cfg.CatalogCacheSWR,
+	}
+	noStore := middleware.CachePolicy{NoStore: true}
 
 	router.Use(middleware.CORSMiddleware())
 	router.Use(middleware.ErrorHandlingMiddleware())
@@ -16,9 +26,13 @@ func SetupRouter() *gin.Engine {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Sitemap en la raiz, donde lo buscan los crawlers
+	router.GET("/sitemap.xml", middleware.CacheControl(catalogCache), handlers.GetSitemap)
+
 	api := router.Group("/api")
 	{
 		auth := api.Group("/auth")
+		auth.Use(middleware.CacheControl(noStore))
 		{
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
@@ -29,7 +43,7 @@ func SetupRouter() *gin.Engine {
 		}
 
 		users := api.Group("/users")
-		users.Use(middleware.AuthMiddleware())
+		users.Use(middleware.AuthMiddleware(), middleware.CacheControl(noStore))
 		{
 			users.GET("/profile", handlers.GetProfile)
 			users.PUT("/profile", handlers.UpdateProfile)
@@ -38,26 +52,70 @@ func SetupRouter() *gin.Engine {
 		}
 
 		products := api.Group("/products")
+		products.Use(middleware.CacheControl(catalogCache))
 		{
 			products.GET("", handlers.GetAllProducts)
 			products.GET("/:id", handlers.GetProductByID)
+			products.GET("/slug/:store/:slug", handlers.GetProductBySlug)
+			products.GET("/:id/related", handlers.GetRelatedProducts)
+			products.GET("/:id/reviews", handlers.GetProductReviews)
+			products.POST("/:id/reviews", middleware.AuthMiddleware(), handlers.CreateReview)
+			products.POST("/:id/stock-subscriptions", middleware.OptionalAuthMiddleware(), handlers.SubscribeBackInStock)
+			products.POST("/:id/size-recommendation", handlers.RecommendProductSize)
 			products.GET("/store/:store_id", handlers.GetProductsByStore)
 			products.GET("/category/:category", handlers.GetProductsByCategory)
 			products.GET("/images/*filename", handlers.ServeImage)
 		}
 
+		stores := api.Group("/stores")
+		stores.Use(middleware.CacheControl(catalogCache))
+		{
+			stores.GET("", handlers.GetStores)
+			stores.GET("/:slug", handlers.GetStore)
+		}
+
+		categories := api.Group("/categories")
+		categories.Use(middleware.CacheControl(catalogCache))
+		{
+			categories.GET("", handlers.GetCategoryTree)
+		}
+
+		collections := api.Group("/collections")
+		collections.Use(middleware.CacheControl(catalogCache))
+		{
+			collections.GET("", handlers.GetCollections)
+			collections.GET("/:store_id/:slug", handlers.GetCollection)
+		}
+
+		promotions := api.Group("/promotions")
+		promotions.Use(middleware.CacheControl(catalogCache))
+		{
+			promotions.GET("", handlers.GetPromotions)
+		}
+
+		api.GET("/currencies", middleware.CacheControl(catalogCache), handlers.GetCurrencies)
+
+		feeds := api.Group("/feeds")
+		feeds.Use(middleware.CacheControl(catalogCache))
+		{
+			feeds.GET("/products.xml", handlers.GetProductFeedXML)
+			feeds.GET("/products.csv", handlers.GetProductFeedCSV)
+		}
+
 		cart := api.Group("/cart")
-		cart.Use(middleware.OptionalAuthMiddleware())
+		cart.Use(middleware.OptionalAuthMiddleware(), middleware.CacheControl(noStore))
 		{
 			cart.GET("", handlers.GetCart)
 			cart.POST("/items", handlers.AddItem)
 			cart.PUT("/items/:item_id", handlers.UpdateItem)
 			cart.DELETE("/items/:item_id", handlers.RemoveItem)
 			cart.DELETE("", handlers.ClearCart)
+			cart.POST("/coupons", handlers.ApplyCoupon)
+			cart.DELETE("/coupons/:code", handlers.RemoveCoupon)
 		}
 
 		orders := api.Group("/orders")
-		orders.Use(middleware.OptionalAuthMiddleware())
+		orders.Use(middleware.OptionalAuthMiddleware(), middleware.CacheControl(noStore))
 		{
 			orders.POST("", handlers.CreateOrder)
 			orders.GET("/:id", handlers.GetOrder)
@@ -65,6 +123,68 @@ func SetupRouter() *gin.Engine {
 			orders.POST("/:id/payment", handlers.ProcessPayment)
 			orders.GET("/:id/confirmation", handlers.GetConfirmation)
 		}
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.StaffMiddleware(), middleware.CacheControl(noStore))
+		{
+			admin.GET("/products", handlers.AdminListProducts)
+			admin.POST("/products", handlers.AdminCreateProduct)
+			admin.GET("/products/:id", handlers.AdminGetProduct)
+			admin.PUT("/products/:id", handlers.AdminUpdateProduct)
+			admin.POST("/products/:id/archive", handlers.AdminArchiveProduct)
+			admin.POST("/products/:id/restore", handlers.AdminRestoreProduct)
+			admin.POST("/products/:id/status", handlers.AdminSetProductStatus)
+			admin.POST("/products/:id/images", handlers.AdminUploadProductImage)
+			admin.PUT("/products/:id/images", handlers.AdminReorderProductImages)
+			admin.DELETE("/products/:id/images/:image_id", handlers.AdminDeleteProductImage)
+			admin.GET("/products/:id/prices", handlers.AdminGetPriceHistory)
+			admin.GET("/products/:id/price-schedules", handlers.AdminListPriceSchedules)
+			admin.POST("/products/:id/price-schedules", handlers.AdminCreatePriceSchedule)
+			admin.DELETE("/products/:id/price-schedules/:schedule_id", handlers.AdminCancelPriceSchedule)
+
+			admin.GET("/stores", handlers.AdminListStores)
+			admin.POST("/stores", handlers.AdminCreateStore)
+			admin.GET("/stores/:id", handlers.AdminGetStore)
+			admin.PUT("/stores/:id", handlers.AdminUpdateStore)
+			admin.DELETE("/stores/:id", handlers.AdminDeleteStore)
+			admin.POST("/stores/:id/archive", handlers.AdminArchiveStore)
+			admin.POST("/stores/:id/restore", handlers.AdminRestoreStore)
+
+			admin.POST("/categories", handlers.AdminCreateCategory)
+			admin.PUT("/categories/:id", handlers.AdminUpdateCategory)
+			admin.DELETE("/categories/:id", handlers.AdminDeleteCategory)
+
+			admin.GET("/collections", handlers.AdminListCollections)
+			admin.POST("/collections", handlers.AdminCreateCollection)
+			admin.GET("/collections/:id", handlers.AdminGetCollection)
+			admin.PUT("/collections/:id", handlers.AdminUpdateCollection)
+			admin.DELETE("/collections/:id", handlers.AdminDeleteCollection)
+			admin.POST("/collections/:id/banner", handlers.AdminUploadCollectionBanner)
+
+			admin.GET("/promotions", handlers.AdminListPromotions)
+			admin.POST("/promotions", handlers.AdminCreatePromotion)
+			admin.GET("/promotions/:id", handlers.AdminGetPromotion)
+			admin.PUT("/promotions/:id", handlers.AdminUpdatePromotion)
+			admin.DELETE("/promotions/:id", handlers.AdminDeletePromotion)
+
+			admin.GET("/reviews", handlers.AdminListReviews)
+			admin.POST("/reviews/:id/approve", handlers.AdminApproveReview)
+			admin.POST("/reviews/:id/reject", handlers.AdminRejectReview)
+
+			admin.POST("/staff", handlers.AdminAssignStaff)
+
+			admin.GET("/catalog/status", handlers.AdminCatalogStatus)
+
+			admin.GET("/stock/low", handlers.AdminListLowStock)
+
+			admin.GET("/size-charts", handlers.AdminListSizeCharts)
+			admin.POST("/size-charts", handlers.AdminCreateSizeChart)
+			admin.PUT("/size-charts/:id", handlers.AdminUpdateSizeChart)
+			admin.DELETE("/size-charts/:id", handlers.AdminDeleteSizeChart)
+
+			admin.PUT("/currencies/:code", handlers.AdminSetExchangeRate)
+			admin.DELETE("/currencies/:code", handlers.AdminDeleteExchangeRate)
+		}
 	}
 
 	return router